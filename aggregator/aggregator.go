@@ -30,6 +30,12 @@ type Aggregator struct {
 	// DB instance
 	DB core.DB
 
+	// policy deciding when pending txs are sealed into a batch
+	policy SealingPolicy
+
+	// scheduler deciding which tx types get a batch in a round
+	scheduler *TypeScheduler
+
 	// header listener subscription
 	cancelAggregating context.CancelFunc
 }
@@ -50,6 +56,8 @@ func NewAggregator() *Aggregator {
 	}
 	aggregator.DB = DB
 	aggregator.LoadedBazooka = LoadedBazooka
+	aggregator.policy = NewSealingPolicy(config.GlobalCfg)
+	aggregator.scheduler = NewTypeScheduler(core.TxTypes)
 	return aggregator
 }
 
@@ -80,8 +88,8 @@ func (a *Aggregator) startAggregating(ctx context.Context, interval time.Duratio
 	for {
 		select {
 		case <-ticker.C:
-			a.pickBatch()
-			// pick batch from DB
+			// pick batches from DB
+			a.aggregateRound()
 		case <-ctx.Done():
 			ticker.Stop()
 			return
@@ -89,78 +97,170 @@ func (a *Aggregator) startAggregating(ctx context.Context, interval time.Duratio
 	}
 }
 
-func (a *Aggregator) pickBatch() {
-	txs, err := a.DB.PopTxs()
+// aggregateRound builds at most one batch per tx type with pending txs, serving the types
+// round-robin and stopping once the configured number of batches per round is reached
+func (a *Aggregator) aggregateRound() {
+	// batches built while catching up would be on stale state
+	synced, err := a.DB.IsSynced()
 	if err != nil {
-		fmt.Println("Error while popping txs from mempool", "Error", err)
+		a.Logger.Error("Error while fetching sync state", "error", err)
+		return
 	}
-
-	// Step-2
-	err = a.ProcessTx(txs)
-	if err != nil {
-		fmt.Println("Error while processing tx", "error", err)
+	if !synced {
+		a.Logger.Info("Syncer isn't synced, not aggregating")
 		return
 	}
 
-	// Step-3
-	// Finally create a merkel root of all updated leafs and push batch on-chain
-	rootAcc, err := a.DB.GetRoot()
+	pending, err := a.DB.GetPendingTxCountPerType(core.TxTypes)
 	if err != nil {
-		fmt.Println("Error while getting root", "error", err)
+		a.Logger.Error("Error while fetching pending tx count", "error", err)
 		return
 	}
-	err = a.LoadedBazooka.SubmitBatch(rootAcc.HashToByteArray(), txs)
-	if err != nil {
-		fmt.Println("Error while submitting batch", "error", err)
-		return
+
+	var batches uint64
+	for _, txType := range a.scheduler.Order(pending) {
+		if config.GlobalCfg.MaxBatchesPerRound != 0 && batches >= config.GlobalCfg.MaxBatchesPerRound {
+			return
+		}
+		if a.pickBatch(txType) {
+			a.scheduler.Served(txType)
+			batches++
+		}
 	}
 }
 
-// ProcessTx fetches all the data required to validate tx from smart contact
-// and calls the proccess tx function to return the updated balance root and accounts
-func (a *Aggregator) ProcessTx(txs []core.Tx) error {
-	for _, tx := range txs {
-		rootAcc, err := a.DB.GetRoot()
-		if err != nil {
-			return err
-		}
+// pickBatch seals, processes and submits a batch of the given tx type
+// returns true if a batch was submitted
+func (a *Aggregator) pickBatch(txType uint64) bool {
+	pendingTxs, err := a.DB.GetPendingTxs(txType, a.policy.MaxTxs)
+	if err != nil {
+		a.Logger.Error("Error while fetching txs from mempool", "error", err)
+		return false
+	}
 
-		a.Logger.Debug("Latest root", "root", rootAcc.Hash)
+	txs, reason, ok := a.policy.Seal(pendingTxs, time.Now())
+	if !ok {
+		a.Logger.Debug("Not sealing batch yet", "txType", txType, "pendingTxs", len(pendingTxs))
+		return false
+	}
+	a.Logger.Info("Sealing batch", "reason", reason, "txType", txType, "txs", len(txs))
 
-		currentRoot, err := core.HexToByteArray(rootAcc.Hash)
-		if err != nil {
-			return err
-		}
+	// the syncer mustn't change the state until the batch is submitted or reverted
+	core.StateLock.Lock()
+	defer core.StateLock.Unlock()
 
-		pdaRoot, err := a.DB.GetPDARoot()
-		if err != nil {
-			return err
-		}
-		currentAccountTreeRoot := pdaRoot.HashToByteArray()
-		fromAccProof, toAccProof, PDAproof, err := tx.GetVerificationData()
-		if err != nil {
-			a.Logger.Error("Unable to create verification data", "error", err)
-			return err
-		}
+	rejection, err := a.preStateRejection()
+	if err != nil {
+		a.Logger.Error("Unable to check the local state against the chain", "error", err)
+		return false
+	}
+	if rejection != "" {
+		a.Logger.Info("Not sealing batch on the local state", "reason", rejection)
+		return false
+	}
 
-		a.Logger.Debug("Fetched latest account proofs", "tx", tx.String(), "fromMP", fromAccProof, "toMP", toAccProof, "PDAProof", PDAproof)
+	err = a.DB.MarkTxsAsProcessing(txs)
+	if err != nil {
+		a.Logger.Error("Error while popping txs from mempool", "error", err)
+		return false
+	}
 
-		updatedRoot, updatedFrom, updatedTo, err := a.LoadedBazooka.ProcessTx(currentRoot, currentAccountTreeRoot, tx, fromAccProof, toAccProof, PDAproof)
-		if err != nil {
-			err := tx.UpdateStatus(core.TX_STATUS_REVERTED)
-			if err != nil {
-				a.Logger.Error("Unable to update transaction status", "tx", tx.String())
-				return err
+	// Step-2
+	batch, err := a.ProcessTx(txs)
+	if err != nil {
+		// nothing was applied, the txs are batched again later
+		a.Logger.Error("Error while processing txs, returning them to the mempool", "error", err)
+		for _, tx := range txs {
+			if err := tx.UpdateStatus(core.TX_STATUS_PENDING); err != nil {
+				a.Logger.Error("Unable to update transaction status", "tx", tx.String(), "error", err)
 			}
 		}
+		return false
+	}
+	if len(batch.Txs) == 0 {
+		a.Logger.Info("No valid txs left in batch", "txType", txType)
+		return false
+	}
 
-		// if the transactions is valid, apply it
-		err = tx.Apply(updatedFrom, updatedTo)
-		if err != nil {
-			return err
+	// Step-3
+	// Finally verify the root the batch leads to against the contract and push batch on-chain
+	rejection, err = a.verifyBatch(batch, batch.PostStateRoot)
+	if err != nil {
+		// the contract couldn't be asked, the txs are batched again later
+		a.Logger.Error("Unable to verify batch, returning its txs to the mempool", "error", err)
+		if err := a.revertBatch(batch, core.TX_STATUS_PENDING); err != nil {
+			a.Logger.Error("Unable to revert batch", "error", err)
+		}
+		return false
+	}
+	if rejection != "" {
+		a.Logger.Error("Batch failed self verification, reverting it", "reason", rejection)
+		if err := a.revertBatch(batch, core.TX_STATUS_REVERTED); err != nil {
+			a.Logger.Error("Unable to revert batch", "error", err)
 		}
+		return false
+	}
+	err = a.LoadedBazooka.SubmitBatch(batch.PostStateRoot, batch.Txs, txType)
+	if err != nil {
+		a.Logger.Error("Unable to submit batch, returning its txs to the mempool", "error", err)
+		if err := a.revertBatch(batch, core.TX_STATUS_PENDING); err != nil {
+			a.Logger.Error("Unable to revert batch", "error", err)
+		}
+		return false
+	}
+	return true
+}
+
+// verifyBatch calls processBatch on the contract against the pre-batch root and checks that the
+// batch is valid and leads to the locally computed root, a batch failing this would get us slashed
+// returns why the batch is rejected, empty if it is accepted, errors are failures to call the contract
+func (a *Aggregator) verifyBatch(batch core.ExecutedBatch, localRoot core.ByteArray) (string, error) {
+	newRoot, isValid, err := a.LoadedBazooka.VerifyBatch(batch.PreStateRoot, batch.AccountsRoot, batch.ABITxs, batch.Proofs)
+	if err != nil {
+		return "", err
+	}
+	if !isValid {
+		return "batch is invalid", nil
+	}
+	if newRoot != localRoot {
+		return fmt.Sprintf("state root mismatch, contract: %v local: %v", newRoot.String(), localRoot.String()), nil
+	}
+	return "", nil
+}
 
-		currentRoot = updatedRoot
+// revertBatch undoes the txs of the batch and moves them to the given status,
+// back to pending if they can be batched again or reverted if they can't
+func (a *Aggregator) revertBatch(batch core.ExecutedBatch, status uint64) error {
+	if err := batch.Revert(); err != nil {
+		return err
+	}
+	for _, tx := range batch.Txs {
+		if err := tx.UpdateStatus(status); err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+// ProcessTx validates the txs with the contract and applies the valid ones
+// Invalid txs are marked as reverted and left out of the returned batch
+func (a *Aggregator) ProcessTx(txs []core.Tx) (batch core.ExecutedBatch, err error) {
+	executed, err := a.LoadedBazooka.ExecuteTxs(a.DB, txs)
+	if err != nil {
+		return batch, err
+	}
+	for i, tx := range executed.Txs {
+		if executed.Valid[i] {
+			continue
+		}
+		a.Logger.Error("Tx is invalid, leaving it out of the batch", "tx", tx.String())
+		if err := tx.UpdateStatus(core.TX_STATUS_REVERTED); err != nil {
+			a.Logger.Error("Unable to update transaction status", "tx", tx.String())
+			if revertErr := executed.Revert(); revertErr != nil {
+				a.Logger.Error("Unable to revert batch", "error", revertErr)
+			}
+			return batch, err
+		}
+	}
+	return executed.OnlyValid(), nil
+}
@@ -0,0 +1,70 @@
+package aggregator
+
+import (
+	"time"
+
+	"github.com/BOPR/config"
+	"github.com/BOPR/core"
+)
+
+// SealingPolicy decides when the pending txs are worth posting on-chain as a batch
+// Every batch locks the stake and costs gas, so we avoid posting nearly empty batches
+// There is no fee condition, the txs the contract accepts carry no fee the sender signs
+type SealingPolicy struct {
+	// MinTxs is the number of txs after which a batch is sealed
+	MinTxs uint64
+
+	// MaxTxs is the maximum number of txs included in a batch, 0 means no limit
+	MaxTxs uint64
+
+	// MaxWait is the maximum time a tx waits in the mempool before a batch is sealed, 0 disables it
+	MaxWait time.Duration
+
+	// MaxCalldataSize is the calldata budget of a batch in bytes, 0 means no limit
+	MaxCalldataSize uint64
+}
+
+// NewSealingPolicy creates the sealing policy from the configuration
+func NewSealingPolicy(cfg config.Configuration) SealingPolicy {
+	return SealingPolicy{
+		MinTxs:          cfg.MinTxsPerBatch,
+		MaxTxs:          cfg.TxsPerBatch,
+		MaxWait:         cfg.MaxBatchWait,
+		MaxCalldataSize: cfg.MaxBatchCalldataSize,
+	}
+}
+
+// Seal picks the txs that fit in a batch out of the pending txs, which are expected oldest first
+// and returns them along with the reason if the batch should be sealed now
+func (p SealingPolicy) Seal(pending []core.Tx, now time.Time) (batch []core.Tx, reason string, ok bool) {
+	var calldataSize uint64
+	full := false
+	for _, tx := range pending {
+		if p.MaxTxs != 0 && uint64(len(batch)) >= p.MaxTxs {
+			full = true
+			break
+		}
+		size := tx.CalldataSize()
+		// a tx larger than the whole budget is sent on its own so that it doesn't block the mempool
+		if p.MaxCalldataSize != 0 && calldataSize+size > p.MaxCalldataSize && len(batch) != 0 {
+			full = true
+			break
+		}
+		calldataSize += size
+		batch = append(batch, tx)
+	}
+
+	if len(batch) == 0 {
+		return nil, "", false
+	}
+
+	switch {
+	case full || (p.MaxTxs != 0 && uint64(len(batch)) >= p.MaxTxs):
+		return batch, "batch full", true
+	case uint64(len(batch)) >= p.MinTxs:
+		return batch, "minimum txs reached", true
+	case p.MaxWait != 0 && now.Sub(batch[0].CreatedAt) >= p.MaxWait:
+		return batch, "maximum wait reached", true
+	}
+	return batch, "", false
+}
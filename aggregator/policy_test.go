@@ -0,0 +1,60 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BOPR/core"
+	"github.com/stretchr/testify/require"
+)
+
+func pendingTxs(count int, createdAt time.Time) (txs []core.Tx) {
+	for i := 0; i < count; i++ {
+		tx := core.NewPendingTx(2, 3, core.TX_TRANSFER_TYPE, "0x1ad4", []byte{byte(i)})
+		tx.CreatedAt = createdAt
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+func TestSealingPolicy(t *testing.T) {
+	now := time.Now()
+	policy := SealingPolicy{MinTxs: 4, MaxTxs: 8, MaxWait: time.Minute}
+
+	_, _, ok := policy.Seal(nil, now)
+	require.False(t, ok, "empty batch should never be sealed")
+
+	_, _, ok = policy.Seal(pendingTxs(2, now), now)
+	require.False(t, ok, "batch below every threshold should wait")
+
+	batch, reason, ok := policy.Seal(pendingTxs(4, now), now)
+	require.True(t, ok)
+	require.Equal(t, "minimum txs reached", reason)
+	require.Len(t, batch, 4)
+
+	batch, reason, ok = policy.Seal(pendingTxs(20, now), now)
+	require.True(t, ok)
+	require.Equal(t, "batch full", reason)
+	require.Len(t, batch, 8, "batch should be capped at max txs")
+
+	_, reason, ok = policy.Seal(pendingTxs(1, now.Add(-2*time.Minute)), now)
+	require.True(t, ok)
+	require.Equal(t, "maximum wait reached", reason)
+}
+
+func TestSealingPolicyCalldataBudget(t *testing.T) {
+	now := time.Now()
+	txs := pendingTxs(5, now)
+	size := txs[0].CalldataSize()
+	policy := SealingPolicy{MinTxs: 10, MaxTxs: 10, MaxCalldataSize: 3 * size}
+
+	batch, reason, ok := policy.Seal(txs, now)
+	require.True(t, ok)
+	require.Equal(t, "batch full", reason)
+	require.Len(t, batch, 3, "batch should stop at the calldata budget")
+
+	policy.MaxCalldataSize = size - 1
+	batch, _, ok = policy.Seal(txs, now)
+	require.True(t, ok, "a tx larger than the budget should not block the mempool")
+	require.Len(t, batch, 1)
+}
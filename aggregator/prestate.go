@@ -0,0 +1,75 @@
+package aggregator
+
+import (
+	"fmt"
+
+	"github.com/BOPR/core"
+)
+
+// checkPreState returns why a batch can't be built on the local state yet, empty if it can
+// The local state has to be the state on chain followed by our batches which haven't landed yet,
+// a batch built on anything else gets us slashed. before is the batch preceding our oldest batch in flight.
+func checkPreState(onChainRoot, localRoot string, before core.Batch, inFlight []core.Batch) string {
+	if len(inFlight) == 0 {
+		if localRoot != onChainRoot {
+			return fmt.Sprintf("local root %v isn't the one on chain %v", localRoot, onChainRoot)
+		}
+		return ""
+	}
+
+	// the roots the chain goes through as our batches land
+	roots := []string{before.StateRoot}
+	for _, batch := range inFlight {
+		if batch.BatchType == core.BATCH_TYPE_DEPOSIT_FINALISATION {
+			return fmt.Sprintf("deposit finalisation %v hasn't landed, its root isn't known", batch.BatchID)
+		}
+		roots = append(roots, batch.StateRoot)
+	}
+	landed := false
+	for _, root := range roots {
+		if root == onChainRoot {
+			landed = true
+			break
+		}
+	}
+	if !landed {
+		return fmt.Sprintf("root on chain %v isn't one our batches in flight build on", onChainRoot)
+	}
+	if latest := roots[len(roots)-1]; localRoot != latest {
+		return fmt.Sprintf("local root %v isn't the one of our latest batch %v", localRoot, latest)
+	}
+	return ""
+}
+
+// preStateRejection checks the local state against the chain before a batch is sealed on it
+// returns why a batch can't be built on it, empty if it can
+func (a *Aggregator) preStateRejection() (string, error) {
+	// the syncer doesn't apply a batch until it is confirmed
+	unsafe, err := a.DB.HasForeignUnsafeBatch()
+	if err != nil {
+		return "", err
+	}
+	if unsafe {
+		return "a batch of someone else isn't confirmed yet", nil
+	}
+
+	onChainRoot, err := a.LoadedBazooka.FetchBalanceTreeRoot()
+	if err != nil {
+		return "", err
+	}
+	localRoot, err := a.DB.GetRoot()
+	if err != nil {
+		return "", err
+	}
+	inFlight, err := a.DB.GetBroadcastedBatches()
+	if err != nil {
+		return "", err
+	}
+	var before core.Batch
+	if len(inFlight) != 0 {
+		if before, err = a.DB.GetBatchByIndex(inFlight[0].BatchID - 1); err != nil {
+			return "", err
+		}
+	}
+	return checkPreState(onChainRoot.String(), localRoot.Hash, before, inFlight), nil
+}
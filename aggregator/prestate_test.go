@@ -0,0 +1,35 @@
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/BOPR/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPreState(t *testing.T) {
+	before := core.Batch{BatchID: 4, StateRoot: "0x04"}
+	inFlight := []core.Batch{
+		{BatchID: 5, StateRoot: "0x05", BatchType: core.TX_TRANSFER_TYPE, Status: core.BATCH_BROADCASTED},
+		{BatchID: 6, StateRoot: "0x06", BatchType: core.TX_TRANSFER_TYPE, Status: core.BATCH_BROADCASTED},
+	}
+
+	// nothing in flight, the local state is the one on chain
+	require.Empty(t, checkPreState("0x04", "0x04", core.Batch{}, nil))
+	require.NotEmpty(t, checkPreState("0x04", "0x03", core.Batch{}, nil))
+
+	// our batches in flight build on the chain, whether they landed already or not
+	require.Empty(t, checkPreState("0x04", "0x06", before, inFlight))
+	require.Empty(t, checkPreState("0x05", "0x06", before, inFlight))
+	require.Empty(t, checkPreState("0x06", "0x06", before, inFlight))
+
+	// someone else committed a batch the syncer hasn't applied
+	require.NotEmpty(t, checkPreState("0xff", "0x06", before, inFlight))
+
+	// the local state isn't where our latest batch left it
+	require.NotEmpty(t, checkPreState("0x04", "0x05", before, inFlight))
+
+	// the root of a deposit finalisation is only known once it lands
+	deposit := core.Batch{BatchID: 7, BatchType: core.BATCH_TYPE_DEPOSIT_FINALISATION, Status: core.BATCH_BROADCASTED}
+	require.NotEmpty(t, checkPreState("0x04", "0x06", before, append(inFlight, deposit)))
+}
@@ -0,0 +1,36 @@
+package aggregator
+
+// TypeScheduler decides in which order tx types get a batch during an aggregation round
+// Types are served round-robin starting after the last type that got a batch, so a type
+// with a deep mempool can't starve the others when the number of batches per round is capped
+type TypeScheduler struct {
+	types []uint64
+	next  int
+}
+
+// NewTypeScheduler returns a scheduler rotating over the given tx types
+func NewTypeScheduler(types []uint64) *TypeScheduler {
+	return &TypeScheduler{types: types}
+}
+
+// Order returns the tx types that have pending txs, in the order they should be served this round
+func (s *TypeScheduler) Order(pending map[uint64]uint64) []uint64 {
+	var order []uint64
+	for i := range s.types {
+		txType := s.types[(s.next+i)%len(s.types)]
+		if pending[txType] > 0 {
+			order = append(order, txType)
+		}
+	}
+	return order
+}
+
+// Served records that a batch was built for the tx type, the next round starts after it
+func (s *TypeScheduler) Served(txType uint64) {
+	for i, t := range s.types {
+		if t == txType {
+			s.next = (i + 1) % len(s.types)
+			return
+		}
+	}
+}
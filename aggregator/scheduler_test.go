@@ -0,0 +1,63 @@
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runRound mimics an aggregation round where every scheduled type seals a batch
+func runRound(s *TypeScheduler, pending map[uint64]uint64, maxBatches int) []uint64 {
+	var served []uint64
+	for _, txType := range s.Order(pending) {
+		if maxBatches != 0 && len(served) >= maxBatches {
+			break
+		}
+		served = append(served, txType)
+		pending[txType]--
+		s.Served(txType)
+	}
+	return served
+}
+
+func TestTypeSchedulerOneBatchPerType(t *testing.T) {
+	s := NewTypeScheduler([]uint64{1, 2, 3})
+	pending := map[uint64]uint64{1: 10, 2: 0, 3: 4}
+	require.Equal(t, []uint64{1, 3}, runRound(s, pending, 0))
+	require.Equal(t, []uint64{1, 3}, runRound(s, pending, 0))
+}
+
+func TestTypeSchedulerNoStarvation(t *testing.T) {
+	s := NewTypeScheduler([]uint64{1, 2, 3})
+	// type 1 always has a full mempool, the other types only a few txs
+	pending := map[uint64]uint64{1: 1000, 2: 1, 3: 1}
+
+	require.Equal(t, []uint64{1}, runRound(s, pending, 1))
+	require.Equal(t, []uint64{2}, runRound(s, pending, 1))
+	require.Equal(t, []uint64{3}, runRound(s, pending, 1))
+	require.Equal(t, []uint64{1}, runRound(s, pending, 1))
+	require.Equal(t, []uint64{1}, runRound(s, pending, 1))
+}
+
+func TestTypeSchedulerFairness(t *testing.T) {
+	s := NewTypeScheduler([]uint64{1, 2, 3})
+	pending := map[uint64]uint64{1: 1000, 2: 1000, 3: 1000}
+	counts := make(map[uint64]int)
+	for round := 0; round < 30; round++ {
+		for _, txType := range runRound(s, pending, 2) {
+			counts[txType]++
+		}
+	}
+	require.Equal(t, map[uint64]int{1: 20, 2: 20, 3: 20}, counts)
+}
+
+func TestTypeSchedulerUnservedTypeKeepsTurn(t *testing.T) {
+	s := NewTypeScheduler([]uint64{1, 2})
+	require.Equal(t, []uint64{1, 2}, s.Order(map[uint64]uint64{1: 1, 2: 1}))
+	// nothing was served, the next round starts from the same type
+	require.Equal(t, []uint64{1, 2}, s.Order(map[uint64]uint64{1: 1, 2: 1}))
+	s.Served(2)
+	require.Equal(t, []uint64{1, 2}, s.Order(map[uint64]uint64{1: 1, 2: 1}))
+	s.Served(1)
+	require.Equal(t, []uint64{2, 1}, s.Order(map[uint64]uint64{1: 1, 2: 1}))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/BOPR/core"
+	"github.com/BOPR/listener"
+	"github.com/spf13/cobra"
+)
+
+// DeadLettersCmd inspects and replays the events the syncer gave up on
+func DeadLettersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dead-letters",
+		Short: "Inspect and replay the events the syncer gave up applying",
+	}
+	cmd.AddCommand(listDeadLettersCmd(), replayDeadLetterCmd())
+	return cmd
+}
+
+func listDeadLettersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Prints the dead letters, in the order the events were emitted",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			all, err := cmd.Flags().GetBool(FlagAll)
+			if err != nil {
+				return err
+			}
+
+			ReadAndInitGlobalConfig()
+			InitGlobalDBInstance()
+			defer core.DBInstance.Close()
+
+			deadLetters, err := core.DBInstance.GetDeadLetters(all)
+			if err != nil {
+				return err
+			}
+			if deadLetters == nil {
+				deadLetters = []core.DeadLetter{}
+			}
+			bz, err := json.MarshalIndent(deadLetters, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(bz))
+			return nil
+		},
+	}
+	cmd.Flags().Bool(FlagAll, false, "--all to include the dead letters replayed already")
+	return cmd
+}
+
+func replayDeadLetterCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Applies the event of a dead letter on top of the current state",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := cmd.Flags().GetUint64(FlagDeadLetterID)
+			if err != nil {
+				return err
+			}
+
+			ReadAndInitGlobalConfig()
+			InitGlobalDBInstance()
+			defer core.DBInstance.Close()
+			InitGlobalBazooka()
+
+			syncer := listener.NewSyncer()
+			defer syncer.DBInstance.Close()
+			if err := syncer.ReplayDeadLetter(id); err != nil {
+				return err
+			}
+			fmt.Println("Dead letter replayed", id)
+			return nil
+		},
+	}
+	cmd.Flags().Uint64(FlagDeadLetterID, 0, "--id=<dead-letter-id>")
+	cmd.MarkFlagRequired(FlagDeadLetterID)
+	return cmd
+}
@@ -10,4 +10,11 @@ const (
 	FlagTokenID       = "token"
 	FlagDatabaseName  = "dbname"
 	FlagNumberOfUsers = "count"
+	FlagBatchID       = "batch"
+	FlagAll           = "all"
+	FlagDeadLetterID  = "id"
+	FlagToBlock       = "to-block"
+	FlagFromFile      = "from-file"
+	FlagRecord        = "record"
+	FlagForce         = "force"
 )
@@ -53,6 +53,10 @@ func main() {
 	)
 	rootCmd.AddCommand(InitCmd())
 	rootCmd.AddCommand(StartCmd())
+	rootCmd.AddCommand(WatchCmd())
+	rootCmd.AddCommand(ProofCmd())
+	rootCmd.AddCommand(DeadLettersCmd())
+	rootCmd.AddCommand(ReplayCmd())
 	rootCmd.AddCommand(ResetCmd())
 	rootCmd.AddCommand(StartSimulatorCmd())
 	rootCmd.AddCommand(AddGenesisAcccountsCmd())
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BOPR/contracts/rollup"
+	"github.com/BOPR/core"
+	"github.com/BOPR/fraudproof"
+	"github.com/spf13/cobra"
+)
+
+// accountProofJSON is the printable version of an account merkle proof
+type accountProofJSON struct {
+	Path      string   `json:"path"`
+	ID        string   `json:"id"`
+	Balance   string   `json:"balance"`
+	Nonce     string   `json:"nonce"`
+	TokenType string   `json:"tokenType"`
+	Siblings  []string `json:"siblings"`
+}
+
+// pdaProofJSON is the printable version of a PDA merkle proof
+type pdaProofJSON struct {
+	Path     string   `json:"path"`
+	Pubkey   string   `json:"pubkey"`
+	Siblings []string `json:"siblings"`
+}
+
+type txProofJSON struct {
+	TxHash string           `json:"txHash"`
+	From   accountProofJSON `json:"from"`
+	To     accountProofJSON `json:"to"`
+	PDA    pdaProofJSON     `json:"pda"`
+}
+
+type batchProofsJSON struct {
+	BatchID      uint64        `json:"batchID"`
+	PreStateRoot string        `json:"preStateRoot"`
+	Txs          []txProofJSON `json:"txs"`
+}
+
+// ProofCmd prints the proofs of a batch
+func ProofCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "proof",
+		Short: "Prints the processBatch/disputeBatch proofs of a batch, built from historical state",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			batchID, err := cmd.Flags().GetUint64(FlagBatchID)
+			if err != nil {
+				return err
+			}
+
+			ReadAndInitGlobalConfig()
+			InitGlobalDBInstance()
+			defer core.DBInstance.Close()
+			// accounts are decoded by the contracts
+			InitGlobalBazooka()
+
+			proofs, err := fraudproof.BuildFromDB(core.DBInstance, batchID)
+			if err != nil {
+				return err
+			}
+			abiProofs, err := proofs.ToABIVersion()
+			if err != nil {
+				return err
+			}
+
+			output := batchProofsJSON{BatchID: proofs.BatchID, PreStateRoot: proofs.PreStateRoot.String(), Txs: []txProofJSON{}}
+			for i, tx := range proofs.Txs {
+				output.Txs = append(output.Txs, txProofJSON{
+					TxHash: tx.TxHash,
+					From:   accountProofToJSON(abiProofs.AccountProofs[i].From),
+					To:     accountProofToJSON(abiProofs.AccountProofs[i].To),
+					PDA:    pdaProofToJSON(abiProofs.PdaProof[i]),
+				})
+			}
+			bz, err := json.MarshalIndent(output, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(bz))
+			return nil
+		},
+	}
+	cmd.Flags().Uint64(FlagBatchID, 0, "--batch=<batch-id>")
+	cmd.MarkFlagRequired(FlagBatchID)
+	return cmd
+}
+
+func accountProofToJSON(proof rollup.TypesAccountMerkleProof) accountProofJSON {
+	account := proof.AccountIP.Account
+	return accountProofJSON{
+		Path:      proof.AccountIP.PathToAccount.String(),
+		ID:        account.ID.String(),
+		Balance:   account.Balance.String(),
+		Nonce:     account.Nonce.String(),
+		TokenType: account.TokenType.String(),
+		Siblings:  siblingsToJSON(proof.Siblings),
+	}
+}
+
+func pdaProofToJSON(proof rollup.TypesPDAMerkleProof) pdaProofJSON {
+	return pdaProofJSON{
+		Path:     proof.Pda.PathToPubkey.String(),
+		Pubkey:   hex.EncodeToString(proof.Pda.PubkeyLeaf.Pubkey),
+		Siblings: siblingsToJSON(proof.Siblings),
+	}
+}
+
+func siblingsToJSON(siblings [][32]byte) []string {
+	hashes := []string{}
+	for _, sibling := range siblings {
+		hashes = append(hashes, core.ByteArray(sibling).String())
+	}
+	return hashes
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/BOPR/config"
+	"github.com/BOPR/core"
+	"github.com/BOPR/listener"
+	"github.com/BOPR/migrations"
+	"github.com/jinzhu/gorm"
+	"github.com/spf13/cobra"
+)
+
+// replayResult is where the state ends up once the events are replayed
+type replayResult struct {
+	LastEthBlock   uint64 `json:"lastEthBlock"`
+	LastBatch      uint64 `json:"lastBatch"`
+	BatchStateRoot string `json:"batchStateRoot"`
+	BalanceRoot    string `json:"balanceRoot"`
+	PDARoot        string `json:"pdaRoot"`
+}
+
+// ReplayCmd rebuilds the state from scratch by replaying the events of the logger contract
+func ReplayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Rebuilds the state from the main chain events in a separate database, without sending anything",
+		Long: `Wipes the database, loads genesis and applies every event from the genesis start block up to the target block.
+No deposit finalisations or batches are sent. Events are read from the main chain, or from a recording of an earlier
+replay with --from-file. The batch txs are still executed through the contracts, the main chain has to be reachable.
+The replay goes to the database given with --dbname, created if needed. Replaying into the database of the node
+wipes it and has to be asked for with --force. Replays don't run while the node is running.
+
+The governance params (stake amount, finalisation time and deposit subtree height) are read once, as they are now,
+also when replaying a recording. Batches committed while other params were in force are replayed with the current
+stake and finalisation block, so their stake and finality may differ from the ones the node recorded.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			toBlock, err := cmd.Flags().GetUint64(FlagToBlock)
+			if err != nil {
+				return err
+			}
+			fromFile, err := cmd.Flags().GetString(FlagFromFile)
+			if err != nil {
+				return err
+			}
+			recordTo, err := cmd.Flags().GetString(FlagRecord)
+			if err != nil {
+				return err
+			}
+			dbName, err := cmd.Flags().GetString(FlagDatabaseName)
+			if err != nil {
+				return err
+			}
+			force, err := cmd.Flags().GetBool(FlagForce)
+			if err != nil {
+				return err
+			}
+
+			ReadAndInitGlobalConfig()
+
+			// the node's database stays locked during the replay, so that the node isn't started meanwhile
+			nodeDB, err := core.NewDB()
+			if err != nil {
+				return err
+			}
+			defer nodeDB.Close()
+			nodeDBName := config.DBName(config.GlobalCfg.FormattedDBURL())
+			nodeLock, err := nodeDB.LockDatabase()
+			if err == core.ErrDatabaseInUse {
+				return fmt.Errorf("the node is running against database %v, stop it before replaying", nodeDBName)
+			} else if err != nil {
+				return err
+			}
+			defer nodeLock.Release()
+
+			separate := dbName != "" && dbName != nodeDBName
+			if !separate && !force {
+				return fmt.Errorf("replaying wipes database %v, pass --%v=<database> to replay into a separate one or --%v to wipe it", nodeDBName, FlagDatabaseName, FlagForce)
+			}
+			if separate {
+				if err := createDatabaseIfMissing(dbName); err != nil {
+					return err
+				}
+				config.DBNameOverride = dbName
+			}
+
+			InitGlobalDBInstance()
+			defer core.DBInstance.Close()
+			if separate {
+				replayLock, err := core.DBInstance.LockDatabase()
+				if err == core.ErrDatabaseInUse {
+					return fmt.Errorf("database %v is in use, is a node or another replay running against it?", dbName)
+				} else if err != nil {
+					return err
+				}
+				defer replayLock.Release()
+			}
+			InitGlobalBazooka()
+
+			// read the events from the main chain unless they were recorded
+			var chain listener.ChainReader = core.LoadedBazooka.EthClient
+			var fetcher listener.CalldataFetcher = &core.LoadedBazooka
+			var recorder *listener.Recorder
+			if fromFile != "" {
+				recording, err := listener.ReadRecording(fromFile)
+				if err != nil {
+					return err
+				}
+				chain, fetcher = recording, recording
+				if toBlock == 0 {
+					toBlock = recording.ToBlock
+				}
+			} else if recordTo != "" {
+				recorder = listener.NewRecorder(chain, fetcher)
+				chain, fetcher = recorder, recorder
+			}
+			if toBlock == 0 {
+				head, err := chain.HeaderByNumber(context.Background(), nil)
+				if err != nil {
+					return err
+				}
+				if head.Number.Uint64() <= config.GlobalCfg.ConfirmationBlocks {
+					return fmt.Errorf("no confirmed blocks to replay, head is %v", head.Number)
+				}
+				toBlock = head.Number.Uint64() - config.GlobalCfg.ConfirmationBlocks
+			}
+
+			if err := resetDatabase(core.DBInstance); err != nil {
+				return err
+			}
+			genesis, err := config.ReadGenesisFile()
+			if err != nil {
+				return err
+			}
+			if genesis.StartEthBlock > toBlock {
+				return fmt.Errorf("target block %v is before the genesis start block %v", toBlock, genesis.StartEthBlock)
+			}
+			LoadGenesisData(genesis)
+			// the params in force at the replayed blocks aren't known, see the command help
+			if _, err := core.LoadedBazooka.SyncGovernanceParams(core.DBInstance); err != nil {
+				return err
+			}
+
+			replayer := listener.NewReplayer(chain, fetcher)
+			defer replayer.DBInstance.Close()
+			replayErr := replayer.Replay(toBlock)
+
+			// the recording is written even if the replay failed, so that the failure can be looked into offline
+			if recorder != nil {
+				recording := recorder.Recording()
+				recording.ToBlock = toBlock
+				if err := recording.Write(recordTo); err != nil {
+					return err
+				}
+			}
+			if replayErr != nil {
+				return replayErr
+			}
+
+			result, err := getReplayResult(core.DBInstance)
+			if err != nil {
+				return err
+			}
+			bz, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(bz))
+			return nil
+		},
+	}
+	cmd.Flags().Uint64(FlagToBlock, 0, "--to-block=<block-number> (default latest confirmed block, or the last block of the recording)")
+	cmd.Flags().String(FlagFromFile, "", "--from-file=<recording> to replay the events of a recording instead of the main chain")
+	cmd.Flags().String(FlagRecord, "", "--record=<recording> to record the events read from the main chain")
+	cmd.Flags().String(FlagDatabaseName, "", "--dbname=<database> to replay into, created if needed")
+	cmd.Flags().Bool(FlagForce, false, "--force to replay into the database of the node, wiping it")
+	return cmd
+}
+
+// createDatabaseIfMissing creates the database on the server of the configured DB URL
+func createDatabaseIfMissing(name string) error {
+	splitStrings := strings.Split(config.GlobalCfg.FormattedDBURL(), "/")
+	connectionString := []string{splitStrings[0], "/"}
+	dbNew, err := sql.Open("mysql", strings.Join(connectionString, ""))
+	if err != nil {
+		return err
+	}
+	defer dbNew.Close()
+	_, err = dbNew.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%v`", name))
+	return err
+}
+
+// resetDatabase drops everything and re-creates the tables
+func resetDatabase(db core.DB) error {
+	m := migrations.NewGormigrate(db.Instance, migrations.DefaultOptions, migrations.GetMigrations())
+
+	// bring the schema up to date first so that every migration can be rolled back
+	if err := m.Migrate(); err != nil {
+		return err
+	}
+	for {
+		err := m.RollbackLast()
+		if err == migrations.ErrNoRunnedMigration {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	return m.Migrate()
+}
+
+func getReplayResult(db core.DB) (result replayResult, err error) {
+	syncStatus, err := db.GetSyncStatus()
+	if err != nil {
+		return result, err
+	}
+	result.LastEthBlock = syncStatus.LastEthBlockRecorded
+	result.LastBatch = syncStatus.LastBatchRecorded
+
+	batch, err := db.GetLatestBatch()
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return result, err
+	} else if err == nil {
+		result.BatchStateRoot = batch.StateRoot
+	}
+
+	balanceRoot, err := db.GetRoot()
+	if err != nil {
+		return result, err
+	}
+	result.BalanceRoot = balanceRoot.Hash
+
+	pdaRoot, err := db.GetPDARoot()
+	if err != nil {
+		return result, err
+	}
+	result.PDARoot = pdaRoot.Hash
+	return result, nil
+}
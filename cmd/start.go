@@ -13,8 +13,11 @@ import (
 
 	agg "github.com/BOPR/aggregator"
 	"github.com/BOPR/core"
+	"github.com/BOPR/jsonrpc"
 	"github.com/BOPR/listener"
 	"github.com/BOPR/rest"
+	"github.com/BOPR/stakemanager"
+	"github.com/BOPR/watcher"
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
 	"github.com/spf13/cobra"
@@ -28,12 +31,7 @@ func StartCmd() *cobra.Command {
 		Short: "Starts hubble daemon",
 		Run: func(cmd *cobra.Command, args []string) {
 			var err error
-			// populate global config object
-			ReadAndInitGlobalConfig()
-
-			InitGlobalDBInstance()
-
-			InitGlobalBazooka()
+			InitNode()
 
 			logger := common.Logger.With("module", "hubble")
 
@@ -47,24 +45,24 @@ func StartCmd() *cobra.Command {
 			// create the syncer service
 			syncer := listener.NewSyncer()
 
-			// if no row is found then we are starting the node for the first time
+			// create the watcher service
+			watcher := watcher.NewWatcher()
+
+			// create the stake manager service
+			stakeManager := stakemanager.NewStakeManager()
+
 			syncStatus, err := core.DBInstance.GetSyncStatus()
-			if err != nil && gorm.IsRecordNotFoundError(err) {
-				// read genesis file
-				genesis, err := config.ReadGenesisFile()
-				common.PanicIfError(err)
-
-				// loads genesis data to the database
-				LoadGenesisData(genesis)
-			} else if err != nil && !gorm.IsRecordNotFoundError(err) {
-				logger.Error("Error connecting to database", "error", err)
-				common.PanicIfError(err)
-			}
+			common.PanicIfError(err)
 
 			logger.Info("Starting coordinator with sync and aggregator enabled", "lastSyncedEthBlock",
 				syncStatus.LastEthBlockBigInt().String(),
 				"lastSyncedBatch", syncStatus.LastBatchRecorded)
 
+			// the REST handlers and the JSON-RPC methods read the node state the same way
+			service := rest.NewService(&core.DBInstance, &core.DBInstance, &core.DBInstance, rest.NewDBProofStore(&core.DBInstance), &core.LoadedBazooka)
+			rpcServer, err := jsonrpc.NewServer(service)
+			common.PanicIfError(err)
+
 			// go routine to catch signal
 			catchSignal := make(chan os.Signal, 1)
 			signal.Notify(catchSignal, os.Interrupt)
@@ -72,7 +70,11 @@ func StartCmd() *cobra.Command {
 				// sig is a ^C, handle it
 				for range catchSignal {
 					aggregator.Stop()
+					watcher.Stop()
+					stakeManager.Stop()
 					syncer.Stop()
+					rpcServer.Stop()
+					core.L1TxManager.Stop()
 					core.DBInstance.Close()
 
 					// exit
@@ -81,17 +83,46 @@ func StartCmd() *cobra.Command {
 			}()
 
 			r := mux.NewRouter()
-			r.HandleFunc("/tx", rest.TxReceiverHandler).Methods("POST")
-			r.HandleFunc("/account", rest.GetAccountHandler).Methods("GET")
+			r.HandleFunc("/tx", service.TxReceiverHandler).Methods("POST")
+			r.HandleFunc("/accounts/{id}", service.GetAccountHandler).Methods("GET")
+			r.HandleFunc("/operator/nonce", rest.GetOperatorNonceHandler).Methods("GET")
+			r.HandleFunc("/stake", rest.GetStakeHandler).Methods("GET")
+			r.HandleFunc("/events/unsafe", rest.GetUnsafeEventsHandler).Methods("GET")
+			r.HandleFunc("/status", rest.GetStatusHandler).Methods("GET")
+			r.HandleFunc("/tokens", service.GetTokensHandler).Methods("GET")
+			r.HandleFunc("/tokens/{id}", service.GetTokenHandler).Methods("GET")
+			r.HandleFunc("/batches", service.GetBatchesHandler).Methods("GET")
+			r.HandleFunc("/batches/{id}", service.GetBatchHandler).Methods("GET")
+			r.HandleFunc("/proofs/account/{id}", service.GetAccountProofHandler).Methods("GET")
+			r.HandleFunc("/proofs/pubkey/{id}", service.GetPubkeyProofHandler).Methods("GET")
 			http.Handle("/", r)
 
+			if err := core.L1TxManager.Start(); err != nil {
+				log.Fatalln("Unable to start L1 tx manager", "error", err)
+			}
+
 			if err := syncer.Start(); err != nil {
 				log.Fatalln("Unable to start syncer", "error")
 			}
 
+			if err := watcher.Start(); err != nil {
+				log.Fatalln("Unable to start watcher", "error", err)
+			}
+
+			if err := stakeManager.Start(); err != nil {
+				log.Fatalln("Unable to start stake manager", "error", err)
+			}
+
 			if err := aggregator.Start(); err != nil {
 				log.Fatalln("Unable to start aggregator", "error", err)
 			}
+
+			go func() {
+				logger.Info("Starting JSON-RPC server", "port", config.GlobalCfg.GetRPCPort())
+				if err := http.ListenAndServe(":"+config.GlobalCfg.GetRPCPort(), rpcServer); err != nil {
+					log.Fatalln("Unable to start JSON-RPC server", "error", err)
+				}
+			}()
 			// TODO replace this with port from config
 			err = http.ListenAndServe(":3000", r)
 			if err != nil {
@@ -102,6 +133,47 @@ func StartCmd() *cobra.Command {
 	}
 }
 
+// nodeDBLock keeps other nodes and replays off the database of the running node
+var nodeDBLock *core.DatabaseLock
+
+// InitNode initialises the globals shared by all services and loads genesis data
+// if the node is starting for the first time
+func InitNode() {
+	// populate global config object
+	ReadAndInitGlobalConfig()
+
+	InitGlobalDBInstance()
+
+	// held for as long as the node runs, the process exiting releases it
+	var err error
+	if nodeDBLock, err = core.DBInstance.LockDatabase(); err != nil {
+		common.Logger.Error("Unable to lock the database, is another node or a replay running against it?", "error", err)
+		common.PanicIfError(err)
+	}
+
+	InitGlobalBazooka()
+
+	InitGlobalTxManager()
+
+	// if no row is found then we are starting the node for the first time
+	_, err = core.DBInstance.GetSyncStatus()
+	if err != nil && gorm.IsRecordNotFoundError(err) {
+		// read genesis file
+		genesis, err := config.ReadGenesisFile()
+		common.PanicIfError(err)
+
+		// loads genesis data to the database
+		LoadGenesisData(genesis)
+	} else if err != nil && !gorm.IsRecordNotFoundError(err) {
+		common.Logger.Error("Error connecting to database", "error", err)
+		common.PanicIfError(err)
+	}
+
+	// stake amount, finalisation time and deposit subtree height come from the governance contract
+	_, err = core.LoadedBazooka.SyncGovernanceParams(core.DBInstance)
+	common.PanicIfError(err)
+}
+
 func ReadAndInitGlobalConfig() {
 	// create viper object
 	viperObj := viper.New()
@@ -146,6 +218,13 @@ func InitGlobalBazooka() {
 	common.PanicIfError(err)
 }
 
+func InitGlobalTxManager() {
+	// all operator txs are sent to the main chain via the global tx manager
+	// which takes nonces from the global operator nonce manager
+	core.OperatorNonceManager = core.NewOperatorNonceManager(core.LoadedBazooka.EthClient)
+	core.L1TxManager = core.NewTxManager(core.LoadedBazooka.EthClient, core.OperatorNonceManager)
+}
+
 // LoadGenesisData helps load the genesis data into the DB
 func LoadGenesisData(genesis config.Genesis) {
 	err := genesis.Validate()
@@ -189,14 +268,14 @@ func LoadGenesisData(genesis config.Genesis) {
 	err = core.DBInstance.InitPDATree(genesis.MaxTreeDepth, allPDALeaf)
 	common.PanicIfError(err)
 
-	// load params
-	newParams := core.Params{StakeAmount: genesis.StakeAmount, MaxDepth: genesis.MaxTreeDepth, MaxDepositSubTreeHeight: genesis.MaxDepositSubTreeHeight}
-	core.DBInstance.UpdateStakeAmount(newParams.StakeAmount)
-	core.DBInstance.UpdateMaxDepth(newParams.MaxDepth)
-	core.DBInstance.UpdateDepositSubTreeHeight(newParams.MaxDepositSubTreeHeight)
-	core.DBInstance.UpdateFinalisationTimePerBatch(40320)
+	// load params, the rest are read from the governance contract
+	core.DBInstance.UpdateMaxDepth(genesis.MaxTreeDepth)
 
-	// load sync status
-	core.DBInstance.UpdateSyncStatusWithBlockNumber(genesis.StartEthBlock)
+	// load sync status, the syncer starts from the block after the last one recorded
+	lastEthBlock := genesis.StartEthBlock
+	if lastEthBlock > 0 {
+		lastEthBlock--
+	}
+	core.DBInstance.UpdateSyncStatusWithBlockNumber(lastEthBlock)
 	core.DBInstance.UpdateSyncStatusWithBatchNumber(0)
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/BOPR/common"
+	"github.com/BOPR/core"
+	"github.com/BOPR/listener"
+	"github.com/BOPR/watcher"
+	"github.com/spf13/cobra"
+)
+
+// WatchCmd starts the daemon in watcher mode
+// The node follows the chain, re-executes every batch and disputes invalid ones
+// without aggregating txs itself
+func WatchCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "watch",
+		Short: "Starts hubble daemon in watcher mode, disputing invalid batches",
+		Run: func(cmd *cobra.Command, args []string) {
+			InitNode()
+
+			logger := common.Logger.With("module", "hubble")
+
+			// create the syncer service
+			syncer := listener.NewSyncer()
+
+			// create the watcher service
+			watcher := watcher.NewWatcher()
+
+			syncStatus, err := core.DBInstance.GetSyncStatus()
+			common.PanicIfError(err)
+
+			logger.Info("Starting coordinator in watcher mode", "lastSyncedEthBlock",
+				syncStatus.LastEthBlockBigInt().String(),
+				"lastSyncedBatch", syncStatus.LastBatchRecorded)
+
+			// go routine to catch signal
+			catchSignal := make(chan os.Signal, 1)
+			signal.Notify(catchSignal, os.Interrupt)
+			go func() {
+				// sig is a ^C, handle it
+				for range catchSignal {
+					watcher.Stop()
+					syncer.Stop()
+					core.L1TxManager.Stop()
+					core.DBInstance.Close()
+
+					// exit
+					os.Exit(1)
+				}
+			}()
+
+			if err := core.L1TxManager.Start(); err != nil {
+				log.Fatalln("Unable to start L1 tx manager", "error", err)
+			}
+
+			if err := syncer.Start(); err != nil {
+				log.Fatalln("Unable to start syncer", "error", err)
+			}
+
+			if err := watcher.Start(); err != nil {
+				log.Fatalln("Unable to start watcher", "error", err)
+			}
+
+			// block until interrupted
+			select {}
+		},
+	}
+}
@@ -23,9 +23,32 @@ const (
 	DefaultEthRPC               = "http://localhost:8545"
 	DefaultPollingInterval      = 5 * time.Second
 	DefaultSeverPort            = "8080"
+	DefaultRPCPort              = "3001"
 	DefaultConfirmationBlocks   = 5
+	DefaultMinTxsPerBatch       = 2
+	DefaultMaxBatchWait         = 5 * time.Minute
+	DefaultMaxBatchCalldataSize = 100000
+	DefaultMaxGasPrice          = 100000000000 // 100 gwei
+	DefaultGasPriceBumpPercent  = 20
+	DefaultStuckTxTimeout       = 3 * time.Minute
 	DefaultDepositSubTreeHeight = 4
 	DefaultMaxDepth             = 2
+	DefaultRootMismatchPolicy   = RootMismatchDispute
+
+	// the governance contract emits no event when its params change
+	DefaultGovernanceSyncInterval = 10 * time.Minute
+)
+
+// What the syncer does when the state root it computes for a batch isn't the one committed on chain
+const (
+	// stop applying events until the node is restarted
+	RootMismatchHalt = "halt"
+
+	// treat the batch as invalid and dispute it
+	RootMismatchDispute = "dispute"
+
+	// unwind to the last synced block and apply the events again, disputing the batch if it mismatches again
+	RootMismatchResync = "resync"
 )
 
 var GlobalCfg Configuration
@@ -43,10 +66,22 @@ type Configuration struct {
 
 	EthRPC             string        `mapstructure:"eth_RPC_URL"`
 	PollingInterval    time.Duration `mapstructure:"polling_interval"`
-	TxsPerBatch        uint64        `mapstructure:"txs_per_batch"`
+	TxsPerBatch        uint64        `mapstructure:"txs_per_batch"` // Maximum number of txs in a batch
 	ServerPort         string        `mapstructure:"server_port"`
+	RPCPort            string        `mapstructure:"rpc_port"`            // Port the JSON-RPC server listens on
 	ConfirmationBlocks uint64        `mapstructure:"confirmation_blocks"` // Number of blocks for confirmation
 
+	// Batch sealing policy, a batch is sealed as soon as one of the conditions is met
+	MinTxsPerBatch       uint64        `mapstructure:"min_txs_per_batch"`       // Seal once these many txs are pending
+	MaxBatchWait         time.Duration `mapstructure:"max_batch_wait"`          // Seal once the oldest pending tx has waited this long
+	MaxBatchCalldataSize uint64        `mapstructure:"max_batch_calldata_size"` // Calldata budget in bytes, seal once it is used up
+	MaxBatchesPerRound   uint64        `mapstructure:"max_batches_per_round"`   // Batches built per aggregation round, 0 means one per tx type
+
+	// L1 tx settings, a tx not mined within the timeout is rebroadcast with the same nonce and a bumped gas price
+	MaxGasPrice         uint64        `mapstructure:"max_gas_price"`          // Gas price ceiling in wei
+	GasPriceBumpPercent uint64        `mapstructure:"gas_price_bump_percent"` // Gas price increase for a replacement tx
+	StuckTxTimeout      time.Duration `mapstructure:"stuck_tx_timeout"`       // Time after which a tx is considered stuck
+
 	RollupAddress      string `mapstructure:"rollup_address"`
 	LoggerAddress      string `mapstructure:"logger_address"`
 	FraudProofAddress  string `mapstructure:"fraud_proof_address"`
@@ -55,27 +90,42 @@ type Configuration struct {
 	OperatorKey       string `mapstructure:"operator_key"`
 	OperatorAddress   string `mapstructure:"operator_address"`
 	LastRecordedBlock string `mapstructure:"last_recorded_block"`
+
+	// Syncer settings, see the RootMismatch constants
+	RootMismatchPolicy     string        `mapstructure:"root_mismatch_policy"`
+	GovernanceSyncInterval time.Duration `mapstructure:"governance_sync_interval"` // Time after which the governance params are read again
 }
 
 // GetDefaultConfig returns the default configration options
 func GetDefaultConfig() Configuration {
 	return Configuration{
-		DB:                 DefaultDB,
-		DBURL:              GetDBURL(),
-		Trace:              false,
-		DBLogMode:          true,
-		EthRPC:             DefaultEthRPC,
-		TxsPerBatch:        2,
-		PollingInterval:    DefaultPollingInterval,
-		ServerPort:         DefaultSeverPort,
-		ConfirmationBlocks: DefaultConfirmationBlocks,
-		RollupAddress:      ethCmn.Address{}.String(),
-		LoggerAddress:      ethCmn.Address{}.String(),
-		FraudProofAddress:  ethCmn.Address{}.String(),
-		RollupUtilsAddress: ethCmn.Address{}.String(),
-		OperatorKey:        "",
-		OperatorAddress:    "",
-		LastRecordedBlock:  "0",
+		DB:                   DefaultDB,
+		DBURL:                GetDBURL(),
+		Trace:                false,
+		DBLogMode:            true,
+		EthRPC:               DefaultEthRPC,
+		TxsPerBatch:          2,
+		MinTxsPerBatch:       DefaultMinTxsPerBatch,
+		MaxBatchWait:         DefaultMaxBatchWait,
+		MaxBatchCalldataSize: DefaultMaxBatchCalldataSize,
+		MaxBatchesPerRound:   0,
+		PollingInterval:      DefaultPollingInterval,
+		ServerPort:           DefaultSeverPort,
+		RPCPort:              DefaultRPCPort,
+		ConfirmationBlocks:   DefaultConfirmationBlocks,
+		MaxGasPrice:          DefaultMaxGasPrice,
+		GasPriceBumpPercent:  DefaultGasPriceBumpPercent,
+		StuckTxTimeout:       DefaultStuckTxTimeout,
+		RollupAddress:        ethCmn.Address{}.String(),
+		LoggerAddress:        ethCmn.Address{}.String(),
+		FraudProofAddress:    ethCmn.Address{}.String(),
+		RollupUtilsAddress:   ethCmn.Address{}.String(),
+		OperatorKey:          "",
+		OperatorAddress:      "",
+		LastRecordedBlock:    "0",
+		RootMismatchPolicy:   DefaultRootMismatchPolicy,
+
+		GovernanceSyncInterval: DefaultGovernanceSyncInterval,
 	}
 }
 
@@ -108,11 +158,68 @@ func ParseAndInitGlobalConfig(path string) error {
 	return nil
 }
 
+// GetRPCPort returns the port the JSON-RPC server listens on, the default one if it isn't set
+func (c *Configuration) GetRPCPort() string {
+	if c.RPCPort == "" {
+		return DefaultRPCPort
+	}
+	return c.RPCPort
+}
+
+// GetGovernanceSyncInterval returns the time after which the governance params are read again, the default if it isn't set
+func (c *Configuration) GetGovernanceSyncInterval() time.Duration {
+	if c.GovernanceSyncInterval == 0 {
+		return DefaultGovernanceSyncInterval
+	}
+	return c.GovernanceSyncInterval
+}
+
+// GetRootMismatchPolicy returns the root mismatch policy, the default one if it isn't set
+// Unknown policies halt the syncer, we can't tell what was meant
+func (c *Configuration) GetRootMismatchPolicy() string {
+	switch c.RootMismatchPolicy {
+	case "":
+		return DefaultRootMismatchPolicy
+	case RootMismatchHalt, RootMismatchDispute, RootMismatchResync:
+		return c.RootMismatchPolicy
+	default:
+		return RootMismatchHalt
+	}
+}
+
+// DBNameOverride replaces the database of the configured DB URL when set
+// It outlives the config being parsed again, so that every connection of the process uses it
+var DBNameOverride string
+
 // FormattedDBURL returns formatted db url
 func (c *Configuration) FormattedDBURL() string {
 	re := regexp.MustCompile(`[a-z0-9]+://`)
 	tokens := re.Split(c.DBURL, 2)
-	return strings.Join(tokens, "")
+	url := strings.Join(tokens, "")
+	if DBNameOverride != "" {
+		url = WithDBName(url, DBNameOverride)
+	}
+	return url
+}
+
+// DBName returns the name of the database the DB URL points to
+func DBName(url string) string {
+	name := url[strings.LastIndex(url, "/")+1:]
+	if i := strings.Index(name, "?"); i != -1 {
+		name = name[:i]
+	}
+	return name
+}
+
+// WithDBName returns the DB URL pointing to the given database, connection params are kept
+func WithDBName(url, name string) string {
+	i := strings.LastIndex(url, "/")
+	rest := url[i+1:]
+	params := ""
+	if j := strings.Index(rest, "?"); j != -1 {
+		params = rest[j:]
+	}
+	return url[:i+1] + name + params
 }
 
 // WriteConfigFile renders config using the template and writes it to
@@ -9,11 +9,9 @@ import (
 )
 
 type Genesis struct {
-	StartEthBlock           uint64 `json:"startEthBlock"`
-	MaxTreeDepth            uint64 `json:"maxTreeDepth"`
-	MaxDepositSubTreeHeight uint64 `json:"maxDepositSubTreeHeight"`
-	StakeAmount             uint64 `json:"stakeAmount"`
-	GenesisAccountCount     uint64 `json:"genesisAccountCount"`
+	StartEthBlock       uint64 `json:"startEthBlock"`
+	MaxTreeDepth        uint64 `json:"maxTreeDepth"`
+	GenesisAccountCount uint64 `json:"genesisAccountCount"`
 	// GenesisAccounts         GenesisAccounts `json:"genesisAccounts"`
 }
 
@@ -87,11 +85,9 @@ func DefaultGenesisAccounts() GenesisAccounts {
 
 func DefaultGenesis() Genesis {
 	return Genesis{
-		StartEthBlock:           0,
-		MaxTreeDepth:            common.DEFAULT_DEPTH,
-		MaxDepositSubTreeHeight: common.DEFAULT_DEPTH,
-		StakeAmount:             32,
-		GenesisAccountCount:     2,
+		StartEthBlock:       0,
+		MaxTreeDepth:        common.DEFAULT_DEPTH,
+		GenesisAccountCount: 2,
 		// GenesisAccounts:         DefaultGenesisAccounts(),
 	}
 }
@@ -20,9 +20,26 @@ db_log_mode = "{{ .DBLogMode }}"
 
 ##### Server configrations #####
 server_port = "{{ .ServerPort }}"
+# port of the JSON-RPC 2.0 server
+rpc_port = "{{ .RPCPort }}"
 polling_interval = "{{ .PollingInterval }}"
 txs_per_batch = "{{ .TxsPerBatch }}"
 
+##### Batch sealing policy #####
+# a batch is sealed as soon as any one of these is met
+min_txs_per_batch = "{{ .MinTxsPerBatch }}"
+max_batch_wait = "{{ .MaxBatchWait }}"
+max_batch_calldata_size = "{{ .MaxBatchCalldataSize }}"
+# batches built per round, tx types are served round-robin, 0 means one batch per tx type
+max_batches_per_round = "{{ .MaxBatchesPerRound }}"
+
+#### L1 tx settings #####
+# gas price ceiling in wei
+max_gas_price = "{{ .MaxGasPrice }}"
+# a tx not mined within stuck_tx_timeout is rebroadcast with the same nonce and a bumped gas price
+gas_price_bump_percent = "{{ .GasPriceBumpPercent }}"
+stuck_tx_timeout = "{{ .StuckTxTimeout }}"
+
 #### Keystore #####
 operator_key = "{{ .OperatorKey }}"
 operator_address = "{{ .OperatorAddress }}"
@@ -30,6 +47,10 @@ operator_address = "{{ .OperatorAddress }}"
 #### Syncer settings #####
 last_recorded_block = "{{ .LastRecordedBlock }}"
 confirmation_blocks = "{{ .ConfirmationBlocks }}"
+# what to do when the state root computed for a batch isn't the one committed: halt, dispute or resync
+root_mismatch_policy = "{{ .RootMismatchPolicy }}"
+# the governance contract emits no event when its params change, they are read again after this long
+governance_sync_interval = "{{ .GovernanceSyncInterval }}"
 
 ##### Contract Addresses #####
 rollup_address = "{{ .RollupAddress }}"
@@ -0,0 +1,303 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package governance
+
+import (
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = abi.U256
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// GovernanceABI is the input ABI used to generate the binding from.
+const GovernanceABI = "[{\"constant\":true,\"inputs\":[],\"name\":\"MAX_DEPTH\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"MAX_DEPOSIT_SUBTREE\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"STAKE_AMOUNT\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"TIME_TO_FINALISE\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"MAX_TXS_PER_BATCH\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"}]"
+
+// Governance is an auto generated Go binding around an Ethereum contract.
+type Governance struct {
+	GovernanceCaller     // Read-only binding to the contract
+	GovernanceTransactor // Write-only binding to the contract
+	GovernanceFilterer   // Log filterer for contract events
+}
+
+// GovernanceCaller is an auto generated read-only Go binding around an Ethereum contract.
+type GovernanceCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// GovernanceTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type GovernanceTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// GovernanceFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type GovernanceFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// GovernanceSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type GovernanceSession struct {
+	Contract     *Governance       // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// GovernanceCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type GovernanceCallerSession struct {
+	Contract *GovernanceCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts     // Call options to use throughout this session
+}
+
+// GovernanceTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type GovernanceTransactorSession struct {
+	Contract     *GovernanceTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts     // Transaction auth options to use throughout this session
+}
+
+// GovernanceRaw is an auto generated low-level Go binding around an Ethereum contract.
+type GovernanceRaw struct {
+	Contract *Governance // Generic contract binding to access the raw methods on
+}
+
+// GovernanceCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type GovernanceCallerRaw struct {
+	Contract *GovernanceCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// GovernanceTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type GovernanceTransactorRaw struct {
+	Contract *GovernanceTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewGovernance creates a new instance of Governance, bound to a specific deployed contract.
+func NewGovernance(address common.Address, backend bind.ContractBackend) (*Governance, error) {
+	contract, err := bindGovernance(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &Governance{GovernanceCaller: GovernanceCaller{contract: contract}, GovernanceTransactor: GovernanceTransactor{contract: contract}, GovernanceFilterer: GovernanceFilterer{contract: contract}}, nil
+}
+
+// NewGovernanceCaller creates a new read-only instance of Governance, bound to a specific deployed contract.
+func NewGovernanceCaller(address common.Address, caller bind.ContractCaller) (*GovernanceCaller, error) {
+	contract, err := bindGovernance(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &GovernanceCaller{contract: contract}, nil
+}
+
+// NewGovernanceTransactor creates a new write-only instance of Governance, bound to a specific deployed contract.
+func NewGovernanceTransactor(address common.Address, transactor bind.ContractTransactor) (*GovernanceTransactor, error) {
+	contract, err := bindGovernance(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &GovernanceTransactor{contract: contract}, nil
+}
+
+// NewGovernanceFilterer creates a new log filterer instance of Governance, bound to a specific deployed contract.
+func NewGovernanceFilterer(address common.Address, filterer bind.ContractFilterer) (*GovernanceFilterer, error) {
+	contract, err := bindGovernance(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &GovernanceFilterer{contract: contract}, nil
+}
+
+// bindGovernance binds a generic wrapper to an already deployed contract.
+func bindGovernance(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(GovernanceABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Governance *GovernanceRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _Governance.Contract.GovernanceCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Governance *GovernanceRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Governance.Contract.GovernanceTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Governance *GovernanceRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Governance.Contract.GovernanceTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Governance *GovernanceCallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _Governance.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Governance *GovernanceTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Governance.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Governance *GovernanceTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Governance.Contract.contract.Transact(opts, method, params...)
+}
+
+// MAXDEPOSITSUBTREE is a free data retrieval call binding the contract method 0x554056e4.
+//
+// Solidity: function MAX_DEPOSIT_SUBTREE() constant returns(uint256)
+func (_Governance *GovernanceCaller) MAXDEPOSITSUBTREE(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _Governance.contract.Call(opts, out, "MAX_DEPOSIT_SUBTREE")
+	return *ret0, err
+}
+
+// MAXDEPOSITSUBTREE is a free data retrieval call binding the contract method 0x554056e4.
+//
+// Solidity: function MAX_DEPOSIT_SUBTREE() constant returns(uint256)
+func (_Governance *GovernanceSession) MAXDEPOSITSUBTREE() (*big.Int, error) {
+	return _Governance.Contract.MAXDEPOSITSUBTREE(&_Governance.CallOpts)
+}
+
+// MAXDEPOSITSUBTREE is a free data retrieval call binding the contract method 0x554056e4.
+//
+// Solidity: function MAX_DEPOSIT_SUBTREE() constant returns(uint256)
+func (_Governance *GovernanceCallerSession) MAXDEPOSITSUBTREE() (*big.Int, error) {
+	return _Governance.Contract.MAXDEPOSITSUBTREE(&_Governance.CallOpts)
+}
+
+// MAXDEPTH is a free data retrieval call binding the contract method 0xa27154ba.
+//
+// Solidity: function MAX_DEPTH() constant returns(uint256)
+func (_Governance *GovernanceCaller) MAXDEPTH(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _Governance.contract.Call(opts, out, "MAX_DEPTH")
+	return *ret0, err
+}
+
+// MAXDEPTH is a free data retrieval call binding the contract method 0xa27154ba.
+//
+// Solidity: function MAX_DEPTH() constant returns(uint256)
+func (_Governance *GovernanceSession) MAXDEPTH() (*big.Int, error) {
+	return _Governance.Contract.MAXDEPTH(&_Governance.CallOpts)
+}
+
+// MAXDEPTH is a free data retrieval call binding the contract method 0xa27154ba.
+//
+// Solidity: function MAX_DEPTH() constant returns(uint256)
+func (_Governance *GovernanceCallerSession) MAXDEPTH() (*big.Int, error) {
+	return _Governance.Contract.MAXDEPTH(&_Governance.CallOpts)
+}
+
+// MAXTXSPERBATCH is a free data retrieval call binding the contract method 0x4a1eb591.
+//
+// Solidity: function MAX_TXS_PER_BATCH() constant returns(uint256)
+func (_Governance *GovernanceCaller) MAXTXSPERBATCH(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _Governance.contract.Call(opts, out, "MAX_TXS_PER_BATCH")
+	return *ret0, err
+}
+
+// MAXTXSPERBATCH is a free data retrieval call binding the contract method 0x4a1eb591.
+//
+// Solidity: function MAX_TXS_PER_BATCH() constant returns(uint256)
+func (_Governance *GovernanceSession) MAXTXSPERBATCH() (*big.Int, error) {
+	return _Governance.Contract.MAXTXSPERBATCH(&_Governance.CallOpts)
+}
+
+// MAXTXSPERBATCH is a free data retrieval call binding the contract method 0x4a1eb591.
+//
+// Solidity: function MAX_TXS_PER_BATCH() constant returns(uint256)
+func (_Governance *GovernanceCallerSession) MAXTXSPERBATCH() (*big.Int, error) {
+	return _Governance.Contract.MAXTXSPERBATCH(&_Governance.CallOpts)
+}
+
+// STAKEAMOUNT is a free data retrieval call binding the contract method 0xfaf5625f.
+//
+// Solidity: function STAKE_AMOUNT() constant returns(uint256)
+func (_Governance *GovernanceCaller) STAKEAMOUNT(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _Governance.contract.Call(opts, out, "STAKE_AMOUNT")
+	return *ret0, err
+}
+
+// STAKEAMOUNT is a free data retrieval call binding the contract method 0xfaf5625f.
+//
+// Solidity: function STAKE_AMOUNT() constant returns(uint256)
+func (_Governance *GovernanceSession) STAKEAMOUNT() (*big.Int, error) {
+	return _Governance.Contract.STAKEAMOUNT(&_Governance.CallOpts)
+}
+
+// STAKEAMOUNT is a free data retrieval call binding the contract method 0xfaf5625f.
+//
+// Solidity: function STAKE_AMOUNT() constant returns(uint256)
+func (_Governance *GovernanceCallerSession) STAKEAMOUNT() (*big.Int, error) {
+	return _Governance.Contract.STAKEAMOUNT(&_Governance.CallOpts)
+}
+
+// TIMETOFINALISE is a free data retrieval call binding the contract method 0x5d4cbd0d.
+//
+// Solidity: function TIME_TO_FINALISE() constant returns(uint256)
+func (_Governance *GovernanceCaller) TIMETOFINALISE(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _Governance.contract.Call(opts, out, "TIME_TO_FINALISE")
+	return *ret0, err
+}
+
+// TIMETOFINALISE is a free data retrieval call binding the contract method 0x5d4cbd0d.
+//
+// Solidity: function TIME_TO_FINALISE() constant returns(uint256)
+func (_Governance *GovernanceSession) TIMETOFINALISE() (*big.Int, error) {
+	return _Governance.Contract.TIMETOFINALISE(&_Governance.CallOpts)
+}
+
+// TIMETOFINALISE is a free data retrieval call binding the contract method 0x5d4cbd0d.
+//
+// Solidity: function TIME_TO_FINALISE() constant returns(uint256)
+func (_Governance *GovernanceCallerSession) TIMETOFINALISE() (*big.Int, error) {
+	return _Governance.Contract.TIMETOFINALISE(&_Governance.CallOpts)
+}
@@ -77,7 +77,11 @@ func (db *DB) FinaliseDeposits(pathToDepositSubTree uint64, depositRoot ByteArra
 		acc.Status = STATUS_ACTIVE
 		acc.UpdatePath(terminalNodes[i])
 		acc.CreateAccountHash()
-		err := db.UpdateAccount(acc)
+		err := db.journalLeaf(acc.Path, "")
+		if err != nil {
+			return err
+		}
+		err = db.UpdateAccount(acc)
 		if err != nil {
 			return err
 		}
@@ -121,3 +125,24 @@ func (db *DB) GetAllTerminalNodes(pathToDepositSubTree string) (terminalNodes []
 	}
 	return
 }
+
+// HasReadyDepositSubTree returns true if there are deposits attached to a subtree which wasn't finalised yet
+func (db *DB) HasReadyDepositSubTree() (bool, error) {
+	var count int
+	err := db.Instance.Model(&UserAccount{}).Where("status = ? AND created_by_deposit_sub_tree != ?", STATUS_PENDING, "").Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// HasDepositFinalisationInFlight returns true if a deposit finalisation we sent isn't on chain yet
+// Finalisations which failed are left out, they won't land
+func (db *DB) HasDepositFinalisationInFlight() (bool, error) {
+	var count int
+	err := db.Instance.Model(&Batch{}).Where("status = ? AND batch_type = ? AND submission_status != ?", BATCH_BROADCASTED, BATCH_TYPE_DEPOSIT_FINALISATION, SUBMISSION_FAILED).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
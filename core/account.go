@@ -395,6 +395,13 @@ func (db *DB) GetAccountByID(ID uint64) (UserAccount, error) {
 	return account, nil
 }
 
+// GetAccountLeafByID returns the leaf of the account, active or still pending deposit
+func (db *DB) GetAccountLeafByID(ID uint64) (account UserAccount, err error) {
+	statuses := []uint64{STATUS_ACTIVE, STATUS_PENDING}
+	err = db.Instance.Where("account_id = ? AND type = ? AND status IN (?)", ID, TYPE_TERMINAL, statuses).Order("status desc").First(&account).Error
+	return account, err
+}
+
 func (db *DB) GetDepositSubTreeRoot(hash string, level uint64) (UserAccount, error) {
 	var account UserAccount
 	err := db.Instance.Where("level = ? AND hash = ?", level, hash).First(&account).Error
@@ -1,7 +1,11 @@
 package core
 
 import (
+	"database/sql/driver"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
 
 	ethCmn "github.com/ethereum/go-ethereum/common"
 )
@@ -33,3 +37,69 @@ func BytesToByteArray(bz []byte) ByteArray {
 	copy(temp[:], bz)
 	return temp
 }
+
+// BigInt is a big.Int that can be stored in the DB and marshalled to JSON,
+// it is persisted as a decimal string so that wei values don't overflow
+type BigInt struct {
+	big.Int
+}
+
+func NewBigInt(i *big.Int) BigInt {
+	var b BigInt
+	if i != nil {
+		b.Set(i)
+	}
+	return b
+}
+
+// BigInt returns a copy of the value as a *big.Int
+func (b BigInt) BigInt() *big.Int {
+	return new(big.Int).Set(&b.Int)
+}
+
+func (b BigInt) String() string {
+	return b.Int.String()
+}
+
+func (b BigInt) Value() (driver.Value, error) {
+	return b.Int.String(), nil
+}
+
+func (b *BigInt) Scan(src interface{}) error {
+	var str string
+	switch v := src.(type) {
+	case []byte:
+		str = string(v)
+	case string:
+		str = v
+	case int64:
+		b.SetInt64(v)
+		return nil
+	case nil:
+		b.SetInt64(0)
+		return nil
+	default:
+		return fmt.Errorf("unable to scan %T into BigInt", src)
+	}
+	if str == "" {
+		b.SetInt64(0)
+		return nil
+	}
+	if _, ok := b.SetString(str, 10); !ok {
+		return fmt.Errorf("invalid BigInt %v", str)
+	}
+	return nil
+}
+
+func (b BigInt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.Int.String())
+}
+
+func (b *BigInt) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		// accept plain numbers as well
+		str = string(data)
+	}
+	return b.Scan(str)
+}
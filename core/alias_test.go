@@ -0,0 +1,34 @@
+package core
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBigIntRoundTrip(t *testing.T) {
+	stake, ok := new(big.Int).SetString("32000000000000000000", 10)
+	require.True(t, ok)
+	value := NewBigInt(stake)
+
+	// DB
+	dbValue, err := value.Value()
+	require.NoError(t, err)
+	require.Equal(t, "32000000000000000000", dbValue)
+	var scanned BigInt
+	require.NoError(t, scanned.Scan([]byte("32000000000000000000")))
+	require.Equal(t, 0, scanned.Cmp(stake))
+
+	// JSON
+	bz, err := json.Marshal(Params{StakeAmount: value})
+	require.NoError(t, err)
+	var params Params
+	require.NoError(t, json.Unmarshal(bz, &params))
+	require.Equal(t, stake, params.StakeAmount.BigInt())
+
+	// returned value is a copy
+	params.StakeAmount.BigInt().SetInt64(1)
+	require.Equal(t, stake, params.StakeAmount.BigInt())
+}
@@ -1,7 +1,9 @@
 package core
 
 import (
-	"math/big"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
 )
 
 // Batch is the batches that need to be submitted on-chain periodically
@@ -10,12 +12,86 @@ type Batch struct {
 	StateRoot            string
 	Committer            string
 	TxRoot               string
-	StakeAmount          uint64
-	FinalisesOn          big.Int
+	StakeAmount          BigInt `gorm:"type:varchar(78)"`
+	FinalisesOn          uint64
 	SubmissionHash       string
 	TransactionsIncluded []byte `gorm:"size:1000000"`
 	BatchType            uint64
 	Status               uint64
+
+	// latest journal entry once the batch was applied locally, the journal is kept back to it while needed
+	JournalSeq uint64
+
+	// L1 submission details, only set for batches submitted by this node
+	SubmissionNonce    uint64
+	SubmissionGasPrice string
+	SubmissionAttempts uint64
+	SubmissionStatus   uint64
+	IncludedInBlock    uint64
+}
+
+// BatchSubmissionHash is a main chain tx sent to submit one of our batches
+// every version of a submission is kept as any of them can be the one mined, the nonce ties them to the batch
+type BatchSubmissionHash struct {
+	TxHash string `gorm:"primary_key"`
+	Nonce  uint64 `gorm:"index:Nonce"`
+}
+
+// IsFinal returns true if the batch can't be disputed anymore as of the given block
+// FinalisesOn is the main chain block the batch finalises on, 0 until it is known
+func (b *Batch) IsFinal(currentBlock uint64) bool {
+	return b.FinalisesOn != 0 && b.FinalisesOn <= currentBlock
+}
+
+// BatchStatusName returns the printable name of the batch status
+func BatchStatusName(status uint64) string {
+	switch status {
+	case BATCH_BROADCASTED:
+		return "broadcasted"
+	case BATCH_COMMITTED:
+		return "committed"
+	case BATCH_DISPUTED:
+		return "disputed"
+	case BATCH_ROLLED_BACK:
+		return "rolled_back"
+	case BATCH_FINALISED:
+		return "finalised"
+	case BATCH_STAKE_WITHDRAWN:
+		return "stake_withdrawn"
+	default:
+		return "unknown"
+	}
+}
+
+// BatchFilter selects batches, zero values match any batch
+type BatchFilter struct {
+	Committer string
+	Status    uint64
+
+	// deposit finalisations are type 0, so the type is only matched if set
+	BatchType *uint64
+}
+
+// GetBatches returns at most limit batches matching the filter, newest first, skipping the first offset ones
+// along with the total number of batches matching the filter
+func (db *DB) GetBatches(filter BatchFilter, offset, limit uint64) (batches []Batch, total uint64, err error) {
+	query := db.Instance.Model(&Batch{})
+	if filter.Committer != "" {
+		query = query.Where("committer = ?", filter.Committer)
+	}
+	if filter.Status != 0 {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.BatchType != nil {
+		query = query.Where("batch_type = ?", *filter.BatchType)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return batches, total, err
+	}
+	if err := query.Order("batch_id desc").Offset(offset).Limit(limit).Find(&batches).Error; err != nil {
+		return batches, total, err
+	}
+	return batches, total, nil
 }
 
 func (db *DB) GetAllBatches() (batches []Batch, err error) {
@@ -45,6 +121,60 @@ func (db *DB) AddNewBatch(batch Batch) error {
 	return db.Instance.Create(batch).Error
 }
 
+// DeleteBroadcastedBatch drops a batch we failed to send along with the link of its txs to it,
+// so that its ID goes to the next batch
+func (db *DB) DeleteBroadcastedBatch(batchID uint64) error {
+	return db.Transaction(func(tx DB) error {
+		if err := tx.Instance.Where("batch_id = ? AND status = ?", batchID, BATCH_BROADCASTED).Delete(&Batch{}).Error; err != nil {
+			return err
+		}
+		return tx.Instance.Model(&Tx{}).Where("batch_id = ?", batchID).Update("batch_id", 0).Error
+	})
+}
+
+// DropFailedBatch undoes one of our batches whose submission failed and deletes it, its txs go back to pending
+// our batches sealed after it were built on top of it, their txs are undone too and left to be applied if they land
+func (db *DB) DropFailedBatch(txHash string) error {
+	return db.Transaction(func(tx DB) error {
+		batch, err := tx.GetBatchBySubmissionHash(txHash)
+		if err != nil {
+			return err
+		}
+		if batch.Status != BATCH_BROADCASTED {
+			return nil
+		}
+		var broadcastedBatchIDs []uint64
+		err = tx.Instance.Model(&Batch{}).Where("batch_id >= ? AND status = ?", batch.BatchID, BATCH_BROADCASTED).Pluck("batch_id", &broadcastedBatchIDs).Error
+		if err != nil {
+			return err
+		}
+		var txHashes []string
+		err = tx.Instance.Model(&Tx{}).Where("batch_id IN (?) AND status = ?", broadcastedBatchIDs, TX_STATUS_PROCESSED).Pluck("tx_hash", &txHashes).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.RevertTxs(txHashes); err != nil {
+			return err
+		}
+		err = tx.Instance.Model(&Tx{}).Where("batch_id IN (?) AND status = ?", broadcastedBatchIDs, TX_STATUS_PROCESSED).Update("status", TX_STATUS_PROCESSING).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Instance.Model(&Tx{}).Where("batch_id = ?", batch.BatchID).Update("status", TX_STATUS_PENDING).Error; err != nil {
+			return err
+		}
+		return tx.DeleteBroadcastedBatch(batch.BatchID)
+	})
+}
+
+// GetBroadcastedBatches returns our batches which haven't been seen on chain yet, oldest first
+func (db *DB) GetBroadcastedBatches() (batches []Batch, err error) {
+	if err := db.Instance.Where("status = ?", BATCH_BROADCASTED).Order("batch_id asc").Find(&batches).Error; err != nil {
+		return batches, err
+	}
+	return batches, nil
+}
+
 func (db *DB) GetBatchByIndex(index uint64) (batch Batch, err error) {
 	if err := db.Instance.Where("batch_id = ?", index).Find(&batch).Error; err != nil {
 		return batch, err
@@ -52,6 +182,206 @@ func (db *DB) GetBatchByIndex(index uint64) (batch Batch, err error) {
 	return batch, nil
 }
 
+// BatchTransitions lists the statuses a batch can move to from each status
+var BatchTransitions = map[uint64][]uint64{
+	// broadcasted by us, waiting for the NewBatch event
+	// disputed if it lands on top of a disputed batch once a reorg unwound its txs
+	BATCH_BROADCASTED: {BATCH_COMMITTED, BATCH_DISPUTED},
+	// rolled back when a dispute against it, or an earlier batch, succeeds
+	BATCH_COMMITTED: {BATCH_ROLLED_BACK, BATCH_FINALISED},
+	BATCH_DISPUTED:  {BATCH_ROLLED_BACK},
+	BATCH_FINALISED: {BATCH_STAKE_WITHDRAWN},
+}
+
+// GetBatchBySubmissionHash returns the batch committed by the main chain tx
+// our batches are also found by any version of their submission, not only the latest one
+func (db *DB) GetBatchBySubmissionHash(txHash string) (batch Batch, err error) {
+	err = db.Instance.Where("submission_hash = ?", txHash).First(&batch).Error
+	if !gorm.IsRecordNotFoundError(err) {
+		return batch, err
+	}
+	var sent BatchSubmissionHash
+	if err := db.Instance.Where("tx_hash = ?", txHash).First(&sent).Error; err != nil {
+		return batch, err
+	}
+	return db.getBatchBySubmissionNonce(sent.Nonce)
+}
+
+// getBatchBySubmissionNonce returns the batch we submitted with the given nonce
+func (db *DB) getBatchBySubmissionNonce(nonce uint64) (batch Batch, err error) {
+	if err := db.Instance.Where("submission_attempts > 0 AND submission_nonce = ?", nonce).First(&batch).Error; err != nil {
+		return batch, err
+	}
+	return batch, nil
+}
+
+// MoveBroadcastedBatch moves one of our batches to the index it landed at, along with its txs
+// an other batch of ours expected at that index takes the old index of the batch
+func (db *DB) MoveBroadcastedBatch(from, to uint64) error {
+	return db.Transaction(func(tx DB) error {
+		var occupied []Batch
+		if err := tx.Instance.Where("batch_id = ?", to).Find(&occupied).Error; err != nil {
+			return err
+		}
+		for _, batch := range occupied {
+			if batch.Status != BATCH_BROADCASTED {
+				return fmt.Errorf("batch %v can't move to index %v, taken by a %v batch", from, to, BatchStatusName(batch.Status))
+			}
+		}
+		swap := gorm.Expr("CASE batch_id WHEN ? THEN ? ELSE ? END", from, to, from)
+		err := tx.Instance.Model(&Batch{}).Where("batch_id IN (?) AND status = ?", []uint64{from, to}, BATCH_BROADCASTED).Update("batch_id", swap).Error
+		if err != nil {
+			return err
+		}
+		return tx.Instance.Model(&Tx{}).Where("batch_id IN (?)", []uint64{from, to}).Update("batch_id", swap).Error
+	})
+}
+
+// DisplaceBroadcastedBatches makes room for a batch someone else committed at the index of one of our batches
+// which hasn't landed yet, our batches from that index on move one index up and their txs are undone
+// the txs are applied again if the batches land
+func (db *DB) DisplaceBroadcastedBatches(from uint64) error {
+	return db.Transaction(func(tx DB) error {
+		var batchIDs []uint64
+		if err := tx.Instance.Model(&Batch{}).Where("batch_id >= ? AND status = ?", from, BATCH_BROADCASTED).Pluck("batch_id", &batchIDs).Error; err != nil {
+			return err
+		}
+		if len(batchIDs) == 0 {
+			return nil
+		}
+		var txHashes []string
+		err := tx.Instance.Model(&Tx{}).Where("batch_id IN (?) AND status = ?", batchIDs, TX_STATUS_PROCESSED).Pluck("tx_hash", &txHashes).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.RevertTxs(txHashes); err != nil {
+			return err
+		}
+		err = tx.Instance.Model(&Tx{}).Where("batch_id IN (?) AND status = ?", batchIDs, TX_STATUS_PROCESSED).Update("status", TX_STATUS_PROCESSING).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Instance.Model(&Tx{}).Where("batch_id IN (?)", batchIDs).Update("batch_id", gorm.Expr("batch_id + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Instance.Model(&Batch{}).Where("batch_id IN (?) AND status = ?", batchIDs, BATCH_BROADCASTED).Update("batch_id", gorm.Expr("batch_id + 1")).Error
+	})
+}
+
+// CanTransitionBatch returns true if a batch can move from one status to the other
+func CanTransitionBatch(from, to uint64) bool {
+	for _, status := range BatchTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateBatchStatus moves the batch to the given status, if the transition is allowed
+func (db *DB) UpdateBatchStatus(batchID uint64, status uint64) error {
+	batch, err := db.GetBatchByIndex(batchID)
+	if err != nil {
+		return err
+	}
+	if !CanTransitionBatch(batch.Status, status) {
+		return fmt.Errorf("batch %v can't move from status %v to %v", batchID, batch.Status, status)
+	}
+	return db.Instance.Model(&Batch{}).Where("batch_id = ? AND status = ?", batchID, batch.Status).Update("status", status).Error
+}
+
+// CommitBatch updates a batch we broadcasted with the details from the NewBatch event
+// The batch is committed unless it carries another status
 func (db *DB) CommitBatch(batch Batch) error {
-	return db.Instance.Update(batch).Error
+	stored, err := db.GetBatchByIndex(batch.BatchID)
+	if err != nil {
+		return err
+	}
+	if batch.Status == 0 {
+		batch.Status = BATCH_COMMITTED
+	}
+	if !CanTransitionBatch(stored.Status, batch.Status) {
+		return fmt.Errorf("batch %v can't move from status %v to %v", batch.BatchID, stored.Status, batch.Status)
+	}
+	return db.Instance.Model(&Batch{}).Where("batch_id = ?", batch.BatchID).Updates(batch).Error
+}
+
+// FinaliseBatches marks the committed batches which are final as of the given block as finalised,
+// along with the txs they include, returns the IDs of the finalised batches
+func (db *DB) FinaliseBatches(currentBlock uint64) (batchIDs []uint64, err error) {
+	var batches []Batch
+	err = db.Instance.Where("status = ? AND finalises_on != 0 AND finalises_on <= ?", BATCH_COMMITTED, currentBlock).Find(&batches).Error
+	if err != nil {
+		return batchIDs, err
+	}
+	for _, batch := range batches {
+		batchIDs = append(batchIDs, batch.BatchID)
+	}
+	if len(batchIDs) == 0 {
+		return batchIDs, nil
+	}
+
+	mysqlTx := db.Instance.Begin()
+	// finalising depends on the block, it is undone if the block is reorged away
+	for _, batchID := range batchIDs {
+		if err := mysqlTx.Create(&BatchStatusUndo{BlockNumber: currentBlock, BatchID: batchID, Status: BATCH_COMMITTED}).Error; err != nil {
+			mysqlTx.Rollback()
+			return batchIDs, err
+		}
+	}
+	err = mysqlTx.Model(&Batch{}).Where("batch_id IN (?) AND status = ?", batchIDs, BATCH_COMMITTED).Update("status", BATCH_FINALISED).Error
+	if err != nil {
+		mysqlTx.Rollback()
+		return batchIDs, err
+	}
+	err = mysqlTx.Model(&Tx{}).Where("batch_id IN (?) AND status = ?", batchIDs, TX_STATUS_PROCESSED).Update("status", TX_STATUS_FINALISED).Error
+	if err != nil {
+		mysqlTx.Rollback()
+		return batchIDs, err
+	}
+	return batchIDs, mysqlTx.Commit().Error
+}
+
+// UpdateBatchSubmission records a broadcast of the batch on the main chain
+// the batch is found by its index when first broadcast, by the nonce of the submission afterwards
+// as the syncer moves our batches when they land at another index than expected
+func (db *DB) UpdateBatchSubmission(batchID uint64, txHash string, nonce uint64, gasPrice string, attempts uint64) error {
+	return db.Transaction(func(tx DB) error {
+		if err := tx.Instance.Create(&BatchSubmissionHash{TxHash: txHash, Nonce: nonce}).Error; err != nil {
+			return err
+		}
+		query := tx.Instance.Model(&Batch{}).Where("batch_id = ? AND status = ?", batchID, BATCH_BROADCASTED)
+		if attempts > 1 {
+			query = tx.Instance.Model(&Batch{}).Where("submission_attempts > 0 AND submission_nonce = ?", nonce)
+		}
+		return query.Updates(map[string]interface{}{
+			"submission_hash":      txHash,
+			"submission_nonce":     nonce,
+			"submission_gas_price": gasPrice,
+			"submission_attempts":  attempts,
+			"submission_status":    SUBMISSION_PENDING,
+		}).Error
+	})
+}
+
+// UpdateBatchSubmissionOutcome records the final outcome of the batch submission sent in the given tx
+// includedInBlock is 0 if the submission never made it on chain
+func (db *DB) UpdateBatchSubmissionOutcome(txHash string, status uint64, includedInBlock uint64) error {
+	var sent BatchSubmissionHash
+	if err := db.Instance.Where("tx_hash = ?", txHash).First(&sent).Error; err != nil {
+		return err
+	}
+	return db.Instance.Model(&Batch{}).Where("submission_attempts > 0 AND submission_nonce = ?", sent.Nonce).Updates(map[string]interface{}{
+		"submission_hash":   txHash,
+		"submission_status": status,
+		"included_in_block": includedInBlock,
+	}).Error
+}
+
+// GetBatchesBySubmissionStatus returns all batches with the given L1 submission status
+func (db *DB) GetBatchesBySubmissionStatus(status uint64) (batches []Batch, err error) {
+	if err := db.Instance.Where("submission_status = ?", status).Find(&batches).Error; err != nil {
+		return batches, err
+	}
+	return batches, nil
 }
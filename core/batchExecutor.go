@@ -0,0 +1,142 @@
+package core
+
+import (
+	"github.com/BOPR/contracts/rollup"
+)
+
+// ExecutedBatch is the result of running a list of txs against the local state
+// It holds everything needed to check the batch with the contract's processBatch
+// or to dispute it, along with where to rewind the journal to undo it locally
+type ExecutedBatch struct {
+	// all txs, in the order they were executed
+	Txs []Tx
+
+	// Valid is false for the txs rejected by the contract, those weren't applied
+	Valid []bool
+
+	// roots before the first tx was executed
+	PreStateRoot ByteArray
+	AccountsRoot ByteArray
+
+	// state root once the valid txs were applied
+	PostStateRoot ByteArray
+
+	// processBatch and disputeBatch inputs
+	ABITxs []rollup.TypesTransaction
+	Proofs rollup.TypesBatchValidationProofs
+
+	// DB the txs were applied to
+	db DB
+}
+
+// ExecuteTxs validates each tx with the contract against the current state and applies the valid ones
+// Proofs are collected for every tx, invalid ones included, so that the batch can be disputed
+// Only txs the contract rejects are invalid, failing to call the contract is returned as an error
+// in which case the txs applied so far are undone
+func (b *Bazooka) ExecuteTxs(db DB, txs []Tx) (batch ExecutedBatch, err error) {
+	batch.db = db
+	defer func() {
+		if err == nil {
+			return
+		}
+		if revertErr := batch.Revert(); revertErr != nil {
+			b.log.Error("Unable to undo partially executed txs", "error", revertErr)
+		}
+	}()
+	for i, tx := range txs {
+		rootAcc, err := db.GetRoot()
+		if err != nil {
+			return batch, err
+		}
+		currentRoot, err := HexToByteArray(rootAcc.Hash)
+		if err != nil {
+			return batch, err
+		}
+		pdaRoot, err := db.GetPDARoot()
+		if err != nil {
+			return batch, err
+		}
+		currentAccountTreeRoot := pdaRoot.HashToByteArray()
+		if i == 0 {
+			batch.PreStateRoot = currentRoot
+			batch.AccountsRoot = currentAccountTreeRoot
+		}
+
+		fromAccProof, toAccProof, PDAproof, err := tx.GetVerificationData(db)
+		if err != nil {
+			return batch, err
+		}
+		abiTx, err := b.TxToABIVersion(tx)
+		if err != nil {
+			return batch, err
+		}
+		fromMP, err := fromAccProof.ToABIVersion()
+		if err != nil {
+			return batch, err
+		}
+		toMP, err := toAccProof.ToABIVersion()
+		if err != nil {
+			return batch, err
+		}
+
+		_, updatedFrom, updatedTo, processErr := b.ProcessTx(currentRoot, currentAccountTreeRoot, tx, fromAccProof, toAccProof, PDAproof)
+		if processErr != nil && processErr != ErrInvalidTx {
+			return batch, processErr
+		} else if processErr != nil {
+			b.log.Info("Tx is invalid", "tx", tx.String())
+		} else if err := tx.Apply(db, updatedFrom, updatedTo); err != nil {
+			return batch, err
+		}
+
+		batch.Txs = append(batch.Txs, tx)
+		batch.Valid = append(batch.Valid, processErr == nil)
+		batch.ABITxs = append(batch.ABITxs, abiTx)
+		batch.Proofs.AccountProofs = append(batch.Proofs.AccountProofs, rollup.TypesAccountProofs{From: fromMP, To: toMP})
+		batch.Proofs.PdaProof = append(batch.Proofs.PdaProof, PDAproof.ToABIVersion())
+	}
+	rootAcc, err := db.GetRoot()
+	if err != nil {
+		return batch, err
+	}
+	if batch.PostStateRoot, err = HexToByteArray(rootAcc.Hash); err != nil {
+		return batch, err
+	}
+	return batch, nil
+}
+
+// FirstInvalid returns the index of the first invalid tx, -1 if all txs are valid
+func (batch *ExecutedBatch) FirstInvalid() int {
+	for i, valid := range batch.Valid {
+		if !valid {
+			return i
+		}
+	}
+	return -1
+}
+
+// OnlyValid returns the batch without the invalid txs
+// invalid txs didn't change the state so the proofs of the remaining txs still hold
+func (batch *ExecutedBatch) OnlyValid() ExecutedBatch {
+	validBatch := ExecutedBatch{PreStateRoot: batch.PreStateRoot, AccountsRoot: batch.AccountsRoot, PostStateRoot: batch.PostStateRoot, db: batch.db}
+	for i, valid := range batch.Valid {
+		if !valid {
+			continue
+		}
+		validBatch.Txs = append(validBatch.Txs, batch.Txs[i])
+		validBatch.Valid = append(validBatch.Valid, true)
+		validBatch.ABITxs = append(validBatch.ABITxs, batch.ABITxs[i])
+		validBatch.Proofs.AccountProofs = append(validBatch.Proofs.AccountProofs, batch.Proofs.AccountProofs[i])
+		validBatch.Proofs.PdaProof = append(validBatch.Proofs.PdaProof, batch.Proofs.PdaProof[i])
+	}
+	return validBatch
+}
+
+// Revert undoes the applied txs of the batch in the DB they were applied to
+// Only the updates journaled for the txs are undone, see RevertTxs
+func (batch *ExecutedBatch) Revert() error {
+	var txHashes []string
+	for _, tx := range batch.Txs {
+		txHashes = append(txHashes, tx.TxHash)
+	}
+	return batch.db.RevertTxs(txHashes)
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchLifecycle(t *testing.T) {
+	// happy path of a batch submitted by us
+	require.True(t, CanTransitionBatch(BATCH_BROADCASTED, BATCH_COMMITTED))
+	require.True(t, CanTransitionBatch(BATCH_COMMITTED, BATCH_FINALISED))
+	require.True(t, CanTransitionBatch(BATCH_FINALISED, BATCH_STAKE_WITHDRAWN))
+
+	// disputes
+	require.True(t, CanTransitionBatch(BATCH_COMMITTED, BATCH_ROLLED_BACK))
+	require.True(t, CanTransitionBatch(BATCH_DISPUTED, BATCH_ROLLED_BACK))
+	// our batch lands invalid once a reorg unwound its txs
+	require.True(t, CanTransitionBatch(BATCH_BROADCASTED, BATCH_DISPUTED))
+	require.False(t, CanTransitionBatch(BATCH_DISPUTED, BATCH_FINALISED))
+
+	// final states
+	require.False(t, CanTransitionBatch(BATCH_FINALISED, BATCH_ROLLED_BACK))
+	require.False(t, CanTransitionBatch(BATCH_ROLLED_BACK, BATCH_COMMITTED))
+	require.False(t, CanTransitionBatch(BATCH_STAKE_WITHDRAWN, BATCH_FINALISED))
+
+	// stake is only withdrawn once final
+	require.False(t, CanTransitionBatch(BATCH_COMMITTED, BATCH_STAKE_WITHDRAWN))
+}
+
+func TestBatchIsFinal(t *testing.T) {
+	batch := Batch{}
+	require.False(t, batch.IsFinal(100), "unknown finalisation block")
+
+	batch.FinalisesOn = 50
+	require.False(t, batch.IsFinal(49))
+	require.True(t, batch.IsFinal(50))
+}
@@ -15,7 +15,9 @@ import (
 	ethTypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
 
+	"github.com/BOPR/contracts/erc20"
 	"github.com/BOPR/contracts/fraudproof"
+	"github.com/BOPR/contracts/governance"
 	"github.com/BOPR/contracts/logger"
 	"github.com/BOPR/contracts/rollup"
 	"github.com/BOPR/contracts/rolluputils"
@@ -49,6 +51,7 @@ type Bazooka struct {
 	EventLogger    *logger.Logger
 	RollupUtils    *rolluputils.Rolluputils
 	FraudProof     *fraudproof.Fraudproof
+	Governance     *governance.Governance
 }
 
 // NewContractCaller contract caller
@@ -109,6 +112,15 @@ func NewPreLoadedBazooka() (bazooka Bazooka, err error) {
 		return bazooka, err
 	}
 
+	// governance contract holds the rollup parameters, its address is read from the rollup contract
+	governanceAddr, err := bazooka.RollupContract.Governance(nil)
+	if err != nil {
+		return bazooka, err
+	}
+	if bazooka.Governance, err = governance.NewGovernance(governanceAddr, bazooka.EthClient); err != nil {
+		return bazooka, err
+	}
+
 	bazooka.log = common.Logger.With("module", "bazooka")
 
 	return bazooka, nil
@@ -123,6 +135,57 @@ func (b *Bazooka) GetMainChainBlock(blockNum *big.Int) (header *ethTypes.Header,
 	return latestBlock, nil
 }
 
+// FetchGovernanceParams reads the rollup parameters from the governance contract
+func (b *Bazooka) FetchGovernanceParams() (stakeAmount *big.Int, finalisationTime, maxDepositSubTreeHeight uint64, err error) {
+	opts := bind.CallOpts{From: config.OperatorAddress}
+	stakeAmount, err = b.Governance.STAKEAMOUNT(&opts)
+	if err != nil {
+		return
+	}
+	timeToFinalise, err := b.Governance.TIMETOFINALISE(&opts)
+	if err != nil {
+		return
+	}
+	maxDepositSubTree, err := b.Governance.MAXDEPOSITSUBTREE(&opts)
+	if err != nil {
+		return
+	}
+	return stakeAmount, timeToFinalise.Uint64(), maxDepositSubTree.Uint64(), nil
+}
+
+// SyncGovernanceParams reads the rollup parameters from the governance contract and stores them
+// returns true if any of them changed
+func (b *Bazooka) SyncGovernanceParams(db DB) (bool, error) {
+	stakeAmount, finalisationTime, maxDepositSubTreeHeight, err := b.FetchGovernanceParams()
+	if err != nil {
+		return false, err
+	}
+	params, err := db.GetParams()
+	if err == nil &&
+		params.StakeAmount.Cmp(stakeAmount) == 0 &&
+		params.FinalisationTime == finalisationTime &&
+		params.MaxDepositSubTreeHeight == maxDepositSubTreeHeight {
+		return false, nil
+	}
+	b.log.Info("Updating governance params", "stakeAmount", stakeAmount, "finalisationTime", finalisationTime, "maxDepositSubTreeHeight", maxDepositSubTreeHeight)
+	return true, db.UpdateGovernanceParams(stakeAmount, finalisationTime, maxDepositSubTreeHeight)
+}
+
+// FetchTokenMetadata reads the symbol and decimals of an ERC20 token
+func (b *Bazooka) FetchTokenMetadata(address ethCmn.Address) (symbol string, decimals uint8, err error) {
+	token, err := erc20.NewERC20Caller(address, b.EthClient)
+	if err != nil {
+		return "", 0, err
+	}
+	if symbol, err = token.Symbol(nil); err != nil {
+		return "", 0, err
+	}
+	if decimals, err = token.Decimals(nil); err != nil {
+		return "", 0, err
+	}
+	return symbol, decimals, nil
+}
+
 // TotalBatches returns the total number of batches that have been submitted on chain
 func (b *Bazooka) TotalBatches() (uint64, error) {
 	totalBatches, err := b.RollupContract.NumOfBatchesSubmitted(nil)
@@ -167,7 +230,7 @@ func (b *Bazooka) FetchBatchInputData(txHash ethCmn.Hash) (txs [][]byte, err err
 }
 
 // ProcessTx calls the ProcessTx function on the contract to verify the tx
-// returns the updated accounts and the new balance root
+// returns the updated accounts and the new balance root, ErrInvalidTx if the contract rejects the tx
 func (b *Bazooka) ProcessTx(balanceTreeRoot, accountTreeRoot ByteArray, tx Tx, fromMerkleProof, toMerkleProof AccountMerkleProof, pdaProof PDAMerkleProof) (newBalanceRoot ByteArray, from, to []byte, err error) {
 	switch txType := tx.Type; txType {
 	case TX_TRANSFER_TYPE:
@@ -206,12 +269,158 @@ func (b *Bazooka) processTransferTx(balanceTreeRoot, accountTreeRoot ByteArray,
 
 	if !IsValidTx {
 		b.log.Error("Invalid transaction", "error_code", errCode)
-		return newBalanceRoot, from, to, errors.New("Tx is invalid")
+		return newBalanceRoot, from, to, ErrInvalidTx
 	}
 	newBalanceRoot = BytesToByteArray(updatedRoot[:])
 	return newBalanceRoot, newFromAccount, newToAccount, nil
 }
 
+// TxToABIVersion decodes the tx into the struct expected by the rollup contract
+func (b *Bazooka) TxToABIVersion(tx Tx) (abiTx rollup.TypesTransaction, err error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(tx.Signature, "0x"))
+	if err != nil {
+		return
+	}
+	switch txType := tx.Type; txType {
+	case TX_TRANSFER_TYPE:
+		from, to, token, nonce, decodedType, amount, err := b.DecodeTransferTx(tx.Data)
+		if err != nil {
+			return abiTx, err
+		}
+		return rollup.TypesTransaction{
+			FromIndex: from,
+			ToIndex:   to,
+			TokenType: token,
+			Nonce:     nonce,
+			TxType:    decodedType,
+			Amount:    amount,
+			Signature: sig,
+		}, nil
+	default:
+		return abiTx, errors.New("Didn't match any options")
+	}
+}
+
+// VerifyBatch runs the txs through the contract's own batch validation against the pre-batch root
+// and returns the resulting state root along with whether the contract considers the batch valid
+// An error means the contract couldn't be asked, it says nothing about the batch
+func (b *Bazooka) VerifyBatch(preStateRoot, accountsRoot ByteArray, txs []rollup.TypesTransaction, proofs rollup.TypesBatchValidationProofs) (newRoot ByteArray, isValid bool, err error) {
+	opts := bind.CallOpts{From: config.OperatorAddress}
+	txRoot, err := b.GenerateTxRoot(txs)
+	if err != nil {
+		return ByteArray{}, false, err
+	}
+	newRoot, _, isValid, err = b.RollupContract.ProcessBatch(&opts, preStateRoot, accountsRoot, txs, proofs, txRoot)
+	if err != nil {
+		return ByteArray{}, false, err
+	}
+	return newRoot, isValid, nil
+}
+
+// GenerateTxRoot returns the tx root the contract computes for the txs
+func (b *Bazooka) GenerateTxRoot(txs []rollup.TypesTransaction) (ByteArray, error) {
+	opts := bind.CallOpts{From: config.OperatorAddress}
+	var fraudProofTxs []fraudproof.TypesTransaction
+	for _, tx := range txs {
+		fraudProofTxs = append(fraudProofTxs, fraudproof.TypesTransaction(tx))
+	}
+	return b.FraudProof.GenerateTxRoot(&opts, fraudProofTxs)
+}
+
+// PackDisputeBatch packs the disputeBatch call for the batch
+func (b *Bazooka) PackDisputeBatch(batchID uint64, txs []rollup.TypesTransaction, proofs rollup.TypesBatchValidationProofs) ([]byte, error) {
+	return b.ContractABI[common.ROLLUP_CONTRACT_KEY].Pack("disputeBatch", new(big.Int).SetUint64(batchID), txs, proofs)
+}
+
+// EncodeAccountMerkleProof ABI encodes the proof the way the rollup contract takes it as an argument
+func (b *Bazooka) EncodeAccountMerkleProof(proof rollup.TypesAccountMerkleProof) ([]byte, error) {
+	return b.encodeArgument("ApplyTx", "_merkle_proof", proof)
+}
+
+// EncodePDAMerkleProof ABI encodes the proof the way the rollup contract takes it as an argument
+func (b *Bazooka) EncodePDAMerkleProof(proof rollup.TypesPDAMerkleProof) ([]byte, error) {
+	return b.encodeArgument("processTx", "_from_pda_proof", proof)
+}
+
+// EncodeAccountProof converts the proof to the contract types and ABI encodes it, see EncodeAccountMerkleProof
+func (b *Bazooka) EncodeAccountProof(proof AccountMerkleProof) ([]byte, error) {
+	abiProof, err := proof.ToABIVersion()
+	if err != nil {
+		return nil, err
+	}
+	return b.EncodeAccountMerkleProof(abiProof)
+}
+
+// EncodePubkeyProof converts the proof to the contract types and ABI encodes it, see EncodePDAMerkleProof
+func (b *Bazooka) EncodePubkeyProof(proof PDAMerkleProof) ([]byte, error) {
+	// ToABIVersion panics on keys which aren't hex
+	if _, err := hex.DecodeString(proof.PublicKey); err != nil {
+		return nil, fmt.Errorf("invalid pubkey %v: %v", proof.PublicKey, err)
+	}
+	return b.EncodePDAMerkleProof(proof.ToABIVersion())
+}
+
+// encodeArgument ABI encodes the value as the named argument of the rollup contract method
+func (b *Bazooka) encodeArgument(methodName, argName string, value interface{}) ([]byte, error) {
+	method, ok := b.ContractABI[common.ROLLUP_CONTRACT_KEY].Methods[methodName]
+	if !ok {
+		return nil, fmt.Errorf("rollup contract has no method %v", methodName)
+	}
+	for _, arg := range method.Inputs {
+		if arg.Name == argName {
+			return abi.Arguments{arg}.Pack(value)
+		}
+	}
+	return nil, fmt.Errorf("method %v has no argument %v", methodName, argName)
+}
+
+// SendDispute sends the packed disputeBatch call of the dispute to the rollup contract
+func (b *Bazooka) SendDispute(dispute Dispute) error {
+	b.log.Info("Disputing batch", "batchID", dispute.BatchID, "reason", dispute.Reason)
+	rollupAddress := ethCmn.HexToAddress(config.GlobalCfg.RollupAddress)
+	callMsg := ethereum.CallMsg{
+		To:   &rollupAddress,
+		Data: dispute.Calldata,
+	}
+	tx, err := sendL1Tx(DisputeSubmission{BatchID: dispute.BatchID}, callMsg)
+	if err != nil {
+		return err
+	}
+	b.log.Info("Sent dispute", "batchID", dispute.BatchID, "txHash", tx.Hash().String())
+	return nil
+}
+
+// FetchBatchFinalisesOn returns the main chain block the batch finalises on as recorded by the rollup contract
+func (b *Bazooka) FetchBatchFinalisesOn(batchID uint64) (uint64, error) {
+	opts := bind.CallOpts{From: config.OperatorAddress}
+	batch, err := b.RollupContract.Batches(&opts, new(big.Int).SetUint64(batchID))
+	if err != nil {
+		return 0, err
+	}
+	return batch.FinalisesOn.Uint64(), nil
+}
+
+// WithdrawStake sends a WithdrawStake call to the rollup contract for the stake locked by the batch
+func (b *Bazooka) WithdrawStake(batchID uint64) error {
+	b.log.Info("Withdrawing stake", "batchID", batchID)
+	data, err := b.ContractABI[common.ROLLUP_CONTRACT_KEY].Pack("WithdrawStake", new(big.Int).SetUint64(batchID))
+	if err != nil {
+		return err
+	}
+	rollupAddress := ethCmn.HexToAddress(config.GlobalCfg.RollupAddress)
+	callMsg := ethereum.CallMsg{
+		To:   &rollupAddress,
+		Data: data,
+	}
+	tx, err := sendL1Tx(StakeWithdrawalSubmission{BatchID: batchID}, callMsg)
+	if err != nil {
+		DBInstance.UpdateStakeWithdrawalSubmissionOutcome(batchID, "", SUBMISSION_FAILED, 0)
+		return err
+	}
+	b.log.Info("Sent stake withdrawal", "batchID", batchID, "txHash", tx.Hash().String())
+	return nil
+}
+
 func (b *Bazooka) ApplyTx(accountMP AccountMerkleProof, tx Tx) (updatedAccount []byte, updatedRoot ByteArray, err error) {
 	switch txType := tx.Type; txType {
 	case TX_TRANSFER_TYPE:
@@ -356,10 +565,13 @@ func (b *Bazooka) FireDepositFinalisation(TBreplaced UserAccount, siblings []Use
 		return
 	}
 
+	params, err := DBInstance.GetParams()
+	if err != nil {
+		return err
+	}
 	rollupAddress := ethCmn.HexToAddress(config.GlobalCfg.RollupAddress)
-	stakeAmount := big.NewInt(0)
-	stakeAmount.SetString("32000000000000000000", 10)
-	fmt.Println("Stake committed", stakeAmount)
+	stakeAmount := params.StakeAmount.BigInt()
+	b.log.Debug("Stake committed", "stakeAmount", stakeAmount)
 
 	// generate call msg
 	callMsg := ethereum.CallMsg{
@@ -368,14 +580,28 @@ func (b *Bazooka) FireDepositFinalisation(TBreplaced UserAccount, siblings []Use
 		Value: stakeAmount,
 	}
 
-	auth, err := b.GenerateAuthObj(b.EthClient, callMsg)
+	latestBatch, err := DBInstance.GetLatestBatch()
+	if err != nil {
+		return err
+	}
+
+	// finalising deposits creates a new batch on chain, track its submission
+	newBatch := Batch{
+		BatchID:     latestBatch.BatchID + 1,
+		Committer:   config.OperatorAddress.String(),
+		StakeAmount: params.StakeAmount,
+		BatchType:   BATCH_TYPE_DEPOSIT_FINALISATION,
+		Status:      BATCH_BROADCASTED,
+	}
+	err = DBInstance.AddNewBatch(newBatch)
 	if err != nil {
-		fmt.Println("here", err)
 		return err
 	}
+
 	b.log.Info("Broadcasting deposit finalisation transaction")
-	tx, err := b.RollupContract.FinaliseDepositsAndSubmitBatch(auth, depositSubTreeHeight, accountProof)
+	tx, err := sendL1Tx(BatchSubmission{BatchID: newBatch.BatchID}, callMsg)
 	if err != nil {
+		b.dropUnsentBatch(newBatch.BatchID)
 		return err
 	}
 	b.log.Info("Deposits successfully finalized!", "TxHash", tx.Hash())
@@ -383,13 +609,16 @@ func (b *Bazooka) FireDepositFinalisation(TBreplaced UserAccount, siblings []Use
 }
 
 // SubmitBatch submits the batch on chain with updated root and compressed transactions
-func (b *Bazooka) SubmitBatch(updatedRoot ByteArray, txs []Tx) error {
+// An error means the batch wasn't sent
+func (b *Bazooka) SubmitBatch(updatedRoot ByteArray, txs []Tx, batchType uint64) error {
 	b.log.Info(
 		"Attempting to submit a new batch",
 		"UpdatedRoot",
 		updatedRoot.String(),
 		"txs",
 		len(txs),
+		"batchType",
+		batchType,
 	)
 	if len(txs) == 0 {
 		b.log.Info("No transactions to submit, waiting....")
@@ -404,45 +633,56 @@ func (b *Bazooka) SubmitBatch(updatedRoot ByteArray, txs []Tx) error {
 		compressedTxs = append(compressedTxs, compressedTx)
 	}
 
-	data, err := b.ContractABI[common.ROLLUP_CONTRACT_KEY].Pack("submitBatch", compressedTxs, updatedRoot, uint8(txs[0].Type))
+	data, err := b.ContractABI[common.ROLLUP_CONTRACT_KEY].Pack("submitBatch", compressedTxs, updatedRoot, uint8(batchType))
 	if err != nil {
 		return err
 	}
 
+	params, err := DBInstance.GetParams()
+	if err != nil {
+		return err
+	}
 	rollupAddress := ethCmn.HexToAddress(config.GlobalCfg.RollupAddress)
-	stakeAmount := big.NewInt(0)
-	stakeAmount.SetString("32000000000000000000", 10)
 
 	// generate call msg
 	callMsg := ethereum.CallMsg{
 		To:    &rollupAddress,
 		Data:  data,
-		Value: stakeAmount,
+		Value: params.StakeAmount.BigInt(),
 	}
 
-	auth, err := b.GenerateAuthObj(b.EthClient, callMsg)
+	latestBatch, err := DBInstance.GetLatestBatch()
 	if err != nil {
 		return err
 	}
-
-	latestBatch, err := DBInstance.GetLatestBatch()
+	// the txs were applied already
+	journalSeq, err := DBInstance.LastJournalSeq()
 	if err != nil {
 		return err
 	}
 
 	newBatch := Batch{
-		BatchID:   latestBatch.BatchID + 1,
-		StateRoot: updatedRoot.String(),
-		Committer: config.OperatorAddress.String(),
-		Status:    BATCH_BROADCASTED,
+		BatchID:     latestBatch.BatchID + 1,
+		StateRoot:   updatedRoot.String(),
+		Committer:   config.OperatorAddress.String(),
+		StakeAmount: params.StakeAmount,
+		BatchType:   batchType,
+		Status:      BATCH_BROADCASTED,
+		JournalSeq:  journalSeq,
 	}
 	b.log.Info("Broadcasting a new batch", "newBatch", newBatch)
 	err = DBInstance.AddNewBatch(newBatch)
 	if err != nil {
 		return err
 	}
-	tx, err := b.RollupContract.SubmitBatch(auth, compressedTxs, updatedRoot, uint8(txs[0].Type))
+	err = DBInstance.AssignTxsToBatch(txs, newBatch.BatchID)
+	if err != nil {
+		b.dropUnsentBatch(newBatch.BatchID)
+		return err
+	}
+	tx, err := sendL1Tx(BatchSubmission{BatchID: newBatch.BatchID}, callMsg)
 	if err != nil {
+		b.dropUnsentBatch(newBatch.BatchID)
 		return err
 	}
 
@@ -450,35 +690,15 @@ func (b *Bazooka) SubmitBatch(updatedRoot ByteArray, txs []Tx) error {
 	return nil
 }
 
+// dropUnsentBatch deletes the row of a batch whose submission failed, so that its ID isn't taken
+// and a batch committed by someone else with that ID isn't mistaken for ours
+func (b *Bazooka) dropUnsentBatch(batchID uint64) {
+	if err := DBInstance.DeleteBroadcastedBatch(batchID); err != nil {
+		b.log.Error("Unable to drop unsent batch", "batchID", batchID, "error", err)
+	}
+}
+
 func GetTxsFromInput(input map[string]interface{}) (txs [][]byte) {
 	data := input["_txs"].([][]byte)
 	return data
 }
-
-func (b *Bazooka) GenerateAuthObj(client *ethclient.Client, callMsg ethereum.CallMsg) (auth *bind.TransactOpts, err error) {
-	// from address
-	fromAddress := config.OperatorAddress
-
-	// fetch gas price
-	gasprice, err := client.SuggestGasPrice(context.Background())
-	if err != nil {
-		return
-	}
-
-	// fetch nonce
-	nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
-	if err != nil {
-		return
-	}
-
-	// fetch gas limit
-	callMsg.From = fromAddress
-	gasLimit, err := client.EstimateGas(context.Background(), callMsg)
-
-	// create auth
-	auth = bind.NewKeyedTransactor(config.OperatorKey)
-	auth.GasPrice = gasprice
-	auth.Nonce = big.NewInt(int64(nonce))
-	auth.GasLimit = uint64(gasLimit) // uint64(gasLimit)
-	return
-}
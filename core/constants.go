@@ -19,10 +19,35 @@ const (
 	TX_STATUS_PROCESSING = 200
 	TX_STATUS_PROCESSED  = 300
 	TX_STATUS_REVERTED   = 400
+	TX_STATUS_FINALISED  = 500
 
+	// Batch status constants, see BatchTransitions for the lifecycle
 	BATCH_BROADCASTED = 100
 	BATCH_COMMITTED   = 200
+	// batch is invalid, or builds on an invalid batch, and wasn't applied locally
+	BATCH_DISPUTED        = 300
+	BATCH_ROLLED_BACK     = 400
+	BATCH_STAKE_WITHDRAWN = 500
+	// added after the other statuses, which keep their stored values
+	BATCH_FINALISED = 600
+
+	// L1 submission status constants
+	SUBMISSION_PENDING   = 100
+	SUBMISSION_CONFIRMED = 200
+	SUBMISSION_FAILED    = 300
+
+	// Sync state constants, on-chain actions are only taken once synced
+	SYNC_STATE_CATCHING_UP = 100
+	SYNC_STATE_SYNCED      = 200
+	// stuck on an event, unable to sync or not getting new heads
+	SYNC_STATE_DEGRADED = 300
 
 	BATCH_TYPE       = 1
 	TX_TRANSFER_TYPE = 1
+
+	// deposit finalisations we broadcast carry no batch type until the NewBatch event is seen
+	BATCH_TYPE_DEPOSIT_FINALISATION = 0
 )
+
+// TxTypes lists all the tx types that are batched by the aggregator
+var TxTypes = []uint64{TX_TRANSFER_TYPE}
@@ -1,6 +1,9 @@
 package core
 
 import (
+	"context"
+	"database/sql"
+
 	"github.com/tendermint/tendermint/libs/log"
 
 	"github.com/BOPR/common"
@@ -21,7 +24,8 @@ type IDB interface {
 
 	// Tx related functions
 	InsertTx(t *Tx) error
-	PopTxs() (txs []Tx, err error)
+	GetPendingTxs(txType uint64, limit uint64) (txs []Tx, err error)
+	MarkTxsAsProcessing(txs []Tx) error
 
 	// Batch related functions
 	InsertBatchInfo(root ByteArray, index uint64) error
@@ -63,3 +67,93 @@ func NewDB() (DB, error) {
 func (db *DB) Close() {
 	db.Instance.Close()
 }
+
+// DatabaseLock is held by the process using the database for as long as it runs, so that a node
+// and commands which rewrite the database, like replay, don't run against it at the same time
+type DatabaseLock struct {
+	conn *sql.Conn
+}
+
+// LockDatabase takes the lock of the database, ErrDatabaseInUse is returned if another process holds it
+// The lock lives with a connection of its own, it is released once the process exits
+func (db *DB) LockDatabase() (*DatabaseLock, error) {
+	ctx := context.Background()
+	conn, err := db.Instance.DB().Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(CONCAT('hubble:', DATABASE()), 0)").Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		conn.Close()
+		return nil, ErrDatabaseInUse
+	}
+	return &DatabaseLock{conn: conn}, nil
+}
+
+// Release releases the lock of the database
+func (l *DatabaseLock) Release() error {
+	defer l.conn.Close()
+	_, err := l.conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(CONCAT('hubble:', DATABASE()))")
+	return err
+}
+
+// Transaction runs fn within a DB transaction, committed if fn returns nil and rolled back otherwise
+// fn joins the current transaction if there is one, it is committed or rolled back by whoever began it
+func (db *DB) Transaction(fn func(tx DB) error) error {
+	if db.inTransaction() {
+		return fn(*db)
+	}
+
+	// begin a transaction
+	mysqlTx := db.Instance.Begin()
+	if mysqlTx.Error != nil {
+		return mysqlTx.Error
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			mysqlTx.Rollback()
+			panic(r)
+		}
+	}()
+	dbCopy := *db
+	dbCopy.Instance = mysqlTx
+
+	if err := fn(dbCopy); err != nil {
+		mysqlTx.Rollback()
+		return err
+	}
+	return mysqlTx.Commit().Error
+}
+
+// scratch runs fn within a DB transaction, or a savepoint of the current one, and discards the changes it made
+func (db *DB) scratch(fn func(tx DB) error) error {
+	if db.inTransaction() {
+		if err := db.Instance.Exec("SAVEPOINT scratch").Error; err != nil {
+			return err
+		}
+		fnErr := fn(*db)
+		if err := db.Instance.Exec("ROLLBACK TO SAVEPOINT scratch").Error; err != nil {
+			return err
+		}
+		return fnErr
+	}
+
+	mysqlTx := db.Instance.Begin()
+	if mysqlTx.Error != nil {
+		return mysqlTx.Error
+	}
+	defer mysqlTx.Rollback()
+	dbCopy := *db
+	dbCopy.Instance = mysqlTx
+	return fn(dbCopy)
+}
+
+// inTransaction returns true if the DB calls are made within a transaction
+func (db *DB) inTransaction() bool {
+	_, ok := db.Instance.CommonDB().(*sql.Tx)
+	return ok
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"strings"
+	"time"
+
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/jinzhu/gorm"
+)
+
+// DeadLetter is a main chain event the syncer gave up applying after retrying it
+// The log is stored as is so the event can be inspected and replayed
+type DeadLetter struct {
+	ID        uint64    `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	EventName   string `json:"eventName"`
+	BlockNumber uint64 `json:"blockNumber" gorm:"index:BlockNumber"`
+	BlockHash   string `json:"blockHash"`
+	TxHash      string `json:"txHash"`
+	TxIndex     uint64 `json:"txIndex"`
+	LogIndex    uint64 `json:"logIndex"`
+	Address     string `json:"address"`
+	// comma separated topics
+	Topics string `json:"topics" gorm:"size:1000"`
+	Data   []byte `json:"data" gorm:"size:1000000"`
+
+	// last error applying the event and how many times it was tried
+	Error    string `json:"error" gorm:"size:1000"`
+	Attempts uint64 `json:"attempts"`
+
+	// set once the event was applied by a replay
+	Replayed bool `json:"replayed"`
+}
+
+// NewDeadLetter creates the dead letter of the log which failed with err
+func NewDeadLetter(eventName string, vLog ethTypes.Log, err error, attempts uint64) DeadLetter {
+	var topics []string
+	for _, topic := range vLog.Topics {
+		topics = append(topics, topic.Hex())
+	}
+	return DeadLetter{
+		EventName:   eventName,
+		BlockNumber: vLog.BlockNumber,
+		BlockHash:   vLog.BlockHash.Hex(),
+		TxHash:      vLog.TxHash.Hex(),
+		TxIndex:     uint64(vLog.TxIndex),
+		LogIndex:    uint64(vLog.Index),
+		Address:     vLog.Address.Hex(),
+		Topics:      strings.Join(topics, ","),
+		Data:        vLog.Data,
+		Error:       err.Error(),
+		Attempts:    attempts,
+	}
+}
+
+// Log rebuilds the log of the event
+func (d *DeadLetter) Log() ethTypes.Log {
+	var topics []ethCmn.Hash
+	if d.Topics != "" {
+		for _, topic := range strings.Split(d.Topics, ",") {
+			topics = append(topics, ethCmn.HexToHash(topic))
+		}
+	}
+	return ethTypes.Log{
+		Address:     ethCmn.HexToAddress(d.Address),
+		Topics:      topics,
+		Data:        d.Data,
+		BlockNumber: d.BlockNumber,
+		TxHash:      ethCmn.HexToHash(d.TxHash),
+		TxIndex:     uint(d.TxIndex),
+		BlockHash:   ethCmn.HexToHash(d.BlockHash),
+		Index:       uint(d.LogIndex),
+	}
+}
+
+func (db *DB) AddDeadLetter(deadLetter DeadLetter) error {
+	return db.Instance.Create(&deadLetter).Error
+}
+
+// GetDeadLetters returns the dead letters in the order the events were emitted, replayed ones only if asked for
+func (db *DB) GetDeadLetters(includeReplayed bool) (deadLetters []DeadLetter, err error) {
+	query := db.Instance.Order("block_number asc, log_index asc")
+	if !includeReplayed {
+		query = query.Where("replayed = ?", false)
+	}
+	if err := query.Find(&deadLetters).Error; err != nil {
+		return deadLetters, err
+	}
+	return deadLetters, nil
+}
+
+func (db *DB) GetDeadLetterByID(id uint64) (deadLetter DeadLetter, err error) {
+	if err := db.Instance.Where("id = ?", id).First(&deadLetter).Error; err != nil {
+		return deadLetter, err
+	}
+	return deadLetter, nil
+}
+
+// RecordDeadLetterReplay records the outcome of replaying the dead letter, err is nil if it was applied
+func (db *DB) RecordDeadLetterReplay(id uint64, err error) error {
+	updates := map[string]interface{}{
+		"attempts": gorm.Expr("attempts + 1"),
+		"replayed": err == nil,
+	}
+	if err != nil {
+		updates["error"] = err.Error()
+	}
+	return db.Instance.Model(&DeadLetter{}).Where("id = ?", id).Updates(updates).Error
+}
@@ -0,0 +1,26 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetterLog(t *testing.T) {
+	vLog := ethTypes.Log{
+		Address:     ethCmn.HexToAddress("0x1"),
+		Topics:      []ethCmn.Hash{ethCmn.HexToHash("0x2"), ethCmn.HexToHash("0x3")},
+		Data:        []byte{4, 5},
+		BlockNumber: 6,
+		TxHash:      ethCmn.HexToHash("0x7"),
+		TxIndex:     8,
+		BlockHash:   ethCmn.HexToHash("0x9"),
+		Index:       10,
+	}
+	deadLetter := NewDeadLetter("NewBatch", vLog, errors.New("failed"), 5)
+	require.Equal(t, "failed", deadLetter.Error)
+	require.Equal(t, vLog, deadLetter.Log())
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Dispute is raised against a batch committed by someone else that failed re-execution
+type Dispute struct {
+	DBModel
+
+	BatchID uint64 `json:"batchID" gorm:"unique_index"`
+
+	// why the batch is invalid
+	Reason string `json:"reason"`
+
+	// packed disputeBatch call
+	Calldata []byte `json:"-" gorm:"size:1000000"`
+
+	// set once the batch has been rolled back on chain, by our dispute or someone else's
+	RolledBack bool `json:"rolledBack"`
+
+	// L1 submission details, SubmissionStatus is 0 until the dispute is first sent
+	SubmissionHash     string `json:"submissionHash"`
+	SubmissionNonce    uint64 `json:"submissionNonce"`
+	SubmissionGasPrice string `json:"submissionGasPrice"`
+	SubmissionAttempts uint64 `json:"submissionAttempts"`
+	SubmissionStatus   uint64 `json:"submissionStatus"`
+	IncludedInBlock    uint64 `json:"includedInBlock"`
+}
+
+func (db *DB) AddDispute(dispute Dispute) error {
+	return db.Instance.Create(&dispute).Error
+}
+
+func (db *DB) GetDisputeByBatchID(batchID uint64) (dispute Dispute, err error) {
+	if err := db.Instance.Where("batch_id = ?", batchID).First(&dispute).Error; err != nil {
+		return dispute, err
+	}
+	return dispute, nil
+}
+
+// GetUnsentDisputes returns the disputes that need to be sent as of the given block, oldest batch first
+// Disputes whose last submission failed are sent again, for as long as the batch can be disputed
+func (db *DB) GetUnsentDisputes(currentBlock uint64) (disputes []Dispute, err error) {
+	err = db.openDisputes(currentBlock).
+		Where("disputes.submission_status IN (?)", []uint64{0, SUBMISSION_FAILED}).
+		Order("disputes.batch_id asc").Find(&disputes).Error
+	if err != nil {
+		return disputes, err
+	}
+	return disputes, nil
+}
+
+// HasOutstandingDispute returns true if a disputed batch hasn't been rolled back yet and still can be as of the given block
+// A batch which finalised without being rolled back never will be, the batches after it are applied as usual
+func (db *DB) HasOutstandingDispute(currentBlock uint64) (bool, error) {
+	var count int
+	if err := db.openDisputes(currentBlock).Model(&Dispute{}).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// openDisputes selects the disputes of batches which haven't been rolled back and are still within their dispute window
+func (db *DB) openDisputes(currentBlock uint64) *gorm.DB {
+	return db.Instance.Joins("JOIN batches ON batches.batch_id = disputes.batch_id").
+		Where("disputes.rolled_back = ? AND (batches.finalises_on = 0 OR batches.finalises_on > ?)", false, currentBlock)
+}
+
+// GetDisputesBySubmissionStatus returns all disputes with the given L1 submission status
+func (db *DB) GetDisputesBySubmissionStatus(status uint64) (disputes []Dispute, err error) {
+	if err := db.Instance.Where("submission_status = ?", status).Find(&disputes).Error; err != nil {
+		return disputes, err
+	}
+	return disputes, nil
+}
+
+// UpdateDisputeSubmission records the latest broadcast of the dispute on the main chain
+func (db *DB) UpdateDisputeSubmission(batchID uint64, txHash string, nonce uint64, gasPrice string, attempts uint64) error {
+	return db.Instance.Model(&Dispute{}).Where("batch_id = ?", batchID).Updates(map[string]interface{}{
+		"submission_hash":      txHash,
+		"submission_nonce":     nonce,
+		"submission_gas_price": gasPrice,
+		"submission_attempts":  attempts,
+		"submission_status":    SUBMISSION_PENDING,
+	}).Error
+}
+
+// UpdateDisputeSubmissionOutcome records the final outcome of the dispute submission
+func (db *DB) UpdateDisputeSubmissionOutcome(batchID uint64, txHash string, status uint64, includedInBlock uint64) error {
+	return db.Instance.Model(&Dispute{}).Where("batch_id = ?", batchID).Updates(map[string]interface{}{
+		"submission_hash":   txHash,
+		"submission_status": status,
+		"included_in_block": includedInBlock,
+	}).Error
+}
+
+// MarkDisputeRolledBack records that the disputed batch was rolled back on chain
+func (db *DB) MarkDisputeRolledBack(batchID uint64) error {
+	return db.Instance.Model(&Dispute{}).Where("batch_id = ?", batchID).Update("rolled_back", true).Error
+}
@@ -1,9 +1,19 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 )
 
+// ErrInvalidTx is returned when the contract rejects a tx, as opposed to failing to ask the contract
+var ErrInvalidTx = errors.New("Tx is invalid")
+
+// ErrTxsAlreadyPicked is returned when some of the txs to batch were picked by another batch in the meantime
+var ErrTxsAlreadyPicked = errors.New("txs were already picked by another batch")
+
+// ErrDatabaseInUse is returned when the database is used by a running node, or a command rewriting it
+var ErrDatabaseInUse = errors.New("database is in use by a running node or replay")
+
 func ErrRecordNotFound(msg string) error {
 	return fmt.Errorf("Error: Record not found. Msg: %s", msg)
 }
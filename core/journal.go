@@ -0,0 +1,250 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+)
+
+// JournalHistoryBatches is the number of latest batches the journal is kept for,
+// the state can't be rewound to older batches
+const JournalHistoryBatches = 100
+
+// StateLock serialises the changes to the balance tree and its journal, the syncer and the aggregator
+// both write them and rewinding the journal undoes whatever was journaled after the point rewound to
+var StateLock sync.Mutex
+
+// ErrJournalInterleaved is returned when txs can't be reverted as others were journaled after them
+var ErrJournalInterleaved = errors.New("journal has entries of other updates after the txs")
+
+// ErrPreStateUnknown is returned when the journal doesn't tell the state before a batch
+// batches stored before their latest journal entry was recorded can't be undone
+var ErrPreStateUnknown = errors.New("state before the batch isn't known")
+
+// UndoLog records an account leaf as it was before being updated
+// Undoing the entries newest first takes the balance tree back to any earlier state
+type UndoLog struct {
+	// order in which the updates were made
+	Seq uint64 `gorm:"primary_key;AUTO_INCREMENT"`
+
+	// tx which updated the leaf, empty for deposits
+	TxHash string `gorm:"index:TxHash"`
+
+	// the leaf before the update
+	Path                    string `gorm:"not null"`
+	AccountID               uint64
+	Data                    []byte `gorm:"type:varbinary(255)"`
+	Status                  uint64
+	Type                    uint64
+	Hash                    string
+	Level                   uint64
+	CreatedByDepositSubTree string
+}
+
+// Leaf returns the account leaf as it was before the update
+func (u *UndoLog) Leaf() UserAccount {
+	return UserAccount{
+		AccountID:               u.AccountID,
+		Data:                    u.Data,
+		Path:                    u.Path,
+		Status:                  u.Status,
+		Type:                    u.Type,
+		Hash:                    u.Hash,
+		Level:                   u.Level,
+		CreatedByDepositSubTree: u.CreatedByDepositSubTree,
+	}
+}
+
+// journalLeaf records the current state of the leaf at path before it is updated by txHash
+func (db *DB) journalLeaf(path, txHash string) error {
+	leaf, err := db.GetAccountByPath(path)
+	if err != nil {
+		return err
+	}
+	entry := UndoLog{
+		TxHash:                  txHash,
+		Path:                    leaf.Path,
+		AccountID:               leaf.AccountID,
+		Data:                    leaf.Data,
+		Status:                  leaf.Status,
+		Type:                    leaf.Type,
+		Hash:                    leaf.Hash,
+		Level:                   leaf.Level,
+		CreatedByDepositSubTree: leaf.CreatedByDepositSubTree,
+	}
+	return db.Instance.Create(&entry).Error
+}
+
+// LastJournalSeq returns the sequence number of the latest journal entry, 0 if there is none
+func (db *DB) LastJournalSeq() (uint64, error) {
+	var entries []UndoLog
+	if err := db.Instance.Order("seq desc").Limit(1).Find(&entries).Error; err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	return entries[0].Seq, nil
+}
+
+// GetUndoLogsBefore returns at most limit journal entries older than seq, newest first
+func (db *DB) GetUndoLogsBefore(seq uint64, limit uint64) (entries []UndoLog, err error) {
+	if err := db.Instance.Where("seq < ?", seq).Order("seq desc").Limit(limit).Find(&entries).Error; err != nil {
+		return entries, err
+	}
+	return entries, nil
+}
+
+// RestoreLeaf overwrites the leaf at its path, zero values included, and updates the nodes up to the root
+// Restoring isn't journaled
+func (db *DB) RestoreLeaf(leaf UserAccount) error {
+	err := db.Instance.Model(&UserAccount{}).Where("path = ?", leaf.Path).Updates(map[string]interface{}{
+		"account_id":                  leaf.AccountID,
+		"data":                        leaf.Data,
+		"status":                      leaf.Status,
+		"type":                        leaf.Type,
+		"hash":                        leaf.Hash,
+		"created_by_deposit_sub_tree": leaf.CreatedByDepositSubTree,
+	}).Error
+	if err != nil {
+		return err
+	}
+	siblings, err := db.GetSiblings(leaf.Path)
+	if err != nil {
+		return err
+	}
+	return db.StoreLeaf(leaf, leaf.Path, siblings)
+}
+
+// RevertTxs undoes the updates of the txs and drops them from the journal
+// The txs have to be the latest updates journaled, updates made by anyone else are never undone
+func (db *DB) RevertTxs(txHashes []string) error {
+	if len(txHashes) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx DB) error {
+		var first []UndoLog
+		if err := tx.Instance.Where("tx_hash IN (?)", txHashes).Order("seq asc").Limit(1).Find(&first).Error; err != nil {
+			return err
+		}
+		if len(first) == 0 {
+			return nil
+		}
+		var others uint64
+		err := tx.Instance.Model(&UndoLog{}).Where("seq > ? AND tx_hash NOT IN (?)", first[0].Seq, txHashes).Count(&others).Error
+		if err != nil {
+			return err
+		}
+		if others != 0 {
+			return ErrJournalInterleaved
+		}
+		return tx.rewindJournal(first[0].Seq - 1)
+	})
+}
+
+// RollbackBatchesFrom takes the balance tree back to the state before the batch, undoing it along with the batches after it
+// Unlike rewinding, the leaves are restored as new journaled updates so that a reorg of the rollback applies
+// the batches again. Our txs which were undone are settled like on a reorg, see returnUndoneTxs
+func (db *DB) RollbackBatchesFrom(batchID uint64) error {
+	if batchID == 0 {
+		return fmt.Errorf("the genesis batch can't be rolled back")
+	}
+	return db.Transaction(func(tx DB) error {
+		previous, err := tx.GetBatchByIndex(batchID - 1)
+		if err != nil {
+			return err
+		}
+		if previous.JournalSeq == 0 && previous.BatchID != 0 {
+			return ErrPreStateUnknown
+		}
+
+		// the oldest entry after the batch before holds the leaf as it was back then
+		var entries []UndoLog
+		if err := tx.Instance.Where("seq > ?", previous.JournalSeq).Order("seq asc").Find(&entries).Error; err != nil {
+			return err
+		}
+		restored := make(map[string]bool)
+		var undoneTxHashes []string
+		for _, entry := range entries {
+			if entry.TxHash != "" {
+				undoneTxHashes = append(undoneTxHashes, entry.TxHash)
+			}
+			if restored[entry.Path] {
+				continue
+			}
+			restored[entry.Path] = true
+			if err := tx.journalLeaf(entry.Path, ""); err != nil {
+				return err
+			}
+			if err := tx.RestoreLeaf(entry.Leaf()); err != nil {
+				return err
+			}
+		}
+		return tx.returnUndoneTxs(undoneTxHashes, nil)
+	})
+}
+
+// rewindJournal undoes all updates made after seq and drops them from the journal, within the current DB transaction
+func (db *DB) rewindJournal(seq uint64) error {
+	var entries []UndoLog
+	if err := db.Instance.Where("seq > ?", seq).Order("seq desc").Find(&entries).Error; err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := db.RestoreLeaf(entry.Leaf()); err != nil {
+			return err
+		}
+	}
+	return db.Instance.Where("seq > ?", seq).Delete(&UndoLog{}).Error
+}
+
+// PruneJournal drops the entries no longer needed to rewind the state, the journal is kept back to the
+// oldest of: the synced blocks a reorg can unwind, the pre-state of the batches which can still be
+// disputed and the last JournalHistoryBatches batches
+func (db *DB) PruneJournal() error {
+	var blocks []SyncedBlock
+	if err := db.Instance.Order("number asc").Limit(1).Find(&blocks).Error; err != nil {
+		return err
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	keepFrom := blocks[0].JournalSeq
+
+	lastBatchID, err := db.GetLastCommittedBatchID()
+	if err != nil {
+		return err
+	}
+	if lastBatchID <= JournalHistoryBatches {
+		return nil
+	}
+	oldestBatchID := lastBatchID - JournalHistoryBatches
+
+	// batches which can still be disputed are proven from the state of the batch before them
+	var undecided []Batch
+	err = db.Instance.Where("status IN (?)", []uint64{BATCH_BROADCASTED, BATCH_COMMITTED, BATCH_DISPUTED}).Order("batch_id asc").Limit(1).Find(&undecided).Error
+	if err != nil {
+		return err
+	}
+	if len(undecided) != 0 && undecided[0].BatchID <= oldestBatchID {
+		if undecided[0].BatchID == 0 {
+			return nil
+		}
+		oldestBatchID = undecided[0].BatchID - 1
+	}
+	oldestBatch, err := db.GetBatchByIndex(oldestBatchID)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if oldestBatch.JournalSeq < keepFrom {
+		keepFrom = oldestBatch.JournalSeq
+	}
+	if keepFrom == 0 {
+		return nil
+	}
+	return db.Instance.Where("seq <= ?", keepFrom).Delete(&UndoLog{}).Error
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/BOPR/config"
+	ethCmn "github.com/ethereum/go-ethereum/common"
+)
+
+// OperatorNonceManager is the global nonce manager for the operator account
+var OperatorNonceManager *NonceManager
+
+// NonceSource returns the next nonce of an account as seen by the main chain
+type NonceSource interface {
+	PendingNonceAt(ctx context.Context, account ethCmn.Address) (uint64, error)
+}
+
+// QueuedSend is a send waiting for, or holding, the operator nonce
+type QueuedSend struct {
+	ID          uint64    `json:"id"`
+	Description string    `json:"description"`
+	EnqueuedAt  time.Time `json:"enqueuedAt"`
+	InFlight    bool      `json:"inFlight"`
+	Nonce       uint64    `json:"nonce,omitempty"`
+}
+
+// NonceQueue is a snapshot of the nonce manager state
+type NonceQueue struct {
+	NextNonce uint64       `json:"nextNonce"`
+	Synced    bool         `json:"synced"`
+	Queue     []QueuedSend `json:"queue"`
+}
+
+// NonceManager hands out nonces for the operator account. All L1 sends go through it
+// one at a time so that services sending txs concurrently never pick the same nonce.
+// The local counter is resynchronised with the chain whenever a send fails.
+type NonceManager struct {
+	source  NonceSource
+	account ethCmn.Address
+
+	// held for the whole duration of a send
+	sendMu    sync.Mutex
+	nextNonce uint64
+	synced    bool
+
+	queueMu sync.Mutex
+	lastID  uint64
+	queue   []QueuedSend
+}
+
+// NewNonceManager returns a nonce manager for the given account
+func NewNonceManager(source NonceSource, account ethCmn.Address) *NonceManager {
+	return &NonceManager{source: source, account: account}
+}
+
+// NewOperatorNonceManager returns a nonce manager for the operator account
+func NewOperatorNonceManager(source NonceSource) *NonceManager {
+	return NewNonceManager(source, config.OperatorAddress)
+}
+
+// Execute waits for its turn and calls send with the next operator nonce
+// the nonce is only consumed if send succeeds, otherwise it is resynced from the chain
+func (n *NonceManager) Execute(description string, send func(nonce uint64) error) error {
+	id := n.enqueue(description)
+	defer n.dequeue(id)
+
+	n.sendMu.Lock()
+	defer n.sendMu.Unlock()
+
+	if !n.synced {
+		if err := n.resync(); err != nil {
+			return err
+		}
+	}
+
+	nonce := n.nextNonce
+	n.markInFlight(id, nonce)
+	if err := send(nonce); err != nil {
+		n.synced = false
+		return err
+	}
+	n.nextNonce = nonce + 1
+	return nil
+}
+
+// Resync marks the local nonce as stale, the next send fetches it from the chain
+func (n *NonceManager) Resync() {
+	n.sendMu.Lock()
+	defer n.sendMu.Unlock()
+	n.synced = false
+}
+
+// Queue returns a snapshot of the sends waiting for a nonce
+func (n *NonceManager) Queue() NonceQueue {
+	n.queueMu.Lock()
+	queue := make([]QueuedSend, len(n.queue))
+	copy(queue, n.queue)
+	n.queueMu.Unlock()
+
+	// a send might be in progress, don't wait for it
+	snapshot := NonceQueue{Queue: queue}
+	for _, send := range queue {
+		if send.InFlight {
+			snapshot.NextNonce = send.Nonce
+			snapshot.Synced = true
+			return snapshot
+		}
+	}
+	n.sendMu.Lock()
+	snapshot.NextNonce, snapshot.Synced = n.nextNonce, n.synced
+	n.sendMu.Unlock()
+	return snapshot
+}
+
+func (n *NonceManager) resync() error {
+	nonce, err := n.source.PendingNonceAt(context.Background(), n.account)
+	if err != nil {
+		return err
+	}
+	n.nextNonce = nonce
+	n.synced = true
+	return nil
+}
+
+func (n *NonceManager) enqueue(description string) uint64 {
+	n.queueMu.Lock()
+	defer n.queueMu.Unlock()
+	n.lastID++
+	n.queue = append(n.queue, QueuedSend{ID: n.lastID, Description: description, EnqueuedAt: time.Now()})
+	return n.lastID
+}
+
+func (n *NonceManager) markInFlight(id, nonce uint64) {
+	n.queueMu.Lock()
+	defer n.queueMu.Unlock()
+	for i := range n.queue {
+		if n.queue[i].ID == id {
+			n.queue[i].InFlight = true
+			n.queue[i].Nonce = nonce
+			return
+		}
+	}
+}
+
+func (n *NonceManager) dequeue(id uint64) {
+	n.queueMu.Lock()
+	defer n.queueMu.Unlock()
+	for i := range n.queue {
+		if n.queue[i].ID == id {
+			n.queue = append(n.queue[:i], n.queue[i+1:]...)
+			return
+		}
+	}
+}
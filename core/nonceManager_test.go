@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeNonceSource struct {
+	nonce uint64
+	calls int
+}
+
+func (f *fakeNonceSource) PendingNonceAt(ctx context.Context, account ethCmn.Address) (uint64, error) {
+	f.calls++
+	return f.nonce, nil
+}
+
+func TestNonceManagerSerialisesSends(t *testing.T) {
+	source := &fakeNonceSource{nonce: 7}
+	manager := NewNonceManager(source, ethCmn.Address{})
+
+	var mu sync.Mutex
+	used := make(map[uint64]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := manager.Execute("send", func(nonce uint64) error {
+				mu.Lock()
+				defer mu.Unlock()
+				require.False(t, used[nonce], "nonce %v used twice", nonce)
+				used[nonce] = true
+				return nil
+			})
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, used, 20)
+	for nonce := uint64(7); nonce < 27; nonce++ {
+		require.True(t, used[nonce])
+	}
+	// the chain is only asked once
+	require.Equal(t, 1, source.calls)
+}
+
+func TestNonceManagerResyncsAfterFailure(t *testing.T) {
+	source := &fakeNonceSource{nonce: 3}
+	manager := NewNonceManager(source, ethCmn.Address{})
+
+	var got []uint64
+	record := func(nonce uint64) error {
+		got = append(got, nonce)
+		return nil
+	}
+	require.NoError(t, manager.Execute("first", record))
+	require.NoError(t, manager.Execute("second", record))
+
+	// the send failed, the nonce is not consumed and gets refetched
+	source.nonce = 4
+	require.Error(t, manager.Execute("failing", func(nonce uint64) error {
+		return errors.New("nonce too low")
+	}))
+	require.False(t, manager.Queue().Synced)
+	require.NoError(t, manager.Execute("third", record))
+
+	require.Equal(t, []uint64{3, 4, 4}, got)
+	require.Equal(t, 2, source.calls)
+}
+
+func TestNonceManagerQueue(t *testing.T) {
+	manager := NewNonceManager(&fakeNonceSource{nonce: 10}, ethCmn.Address{})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		manager.Execute("in flight", func(nonce uint64) error {
+			close(started)
+			<-release
+			return nil
+		})
+		close(done)
+	}()
+	<-started
+
+	queue := manager.Queue()
+	require.Len(t, queue.Queue, 1)
+	require.Equal(t, "in flight", queue.Queue[0].Description)
+	require.True(t, queue.Queue[0].InFlight)
+	require.Equal(t, uint64(10), queue.NextNonce)
+
+	close(release)
+	<-done
+	queue = manager.Queue()
+	require.Empty(t, queue.Queue)
+	require.Equal(t, uint64(11), queue.NextNonce)
+	require.True(t, queue.Synced)
+}
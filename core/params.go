@@ -7,10 +7,9 @@ import "math/big"
 type Params struct {
 	DBModel
 
-	// Stake amount which coordinator needs to submit a new batch
-	// Updates when syncer receives a stake update event from the contract
-	// Used while sending new batch
-	StakeAmount uint64 `json:"stakeAmount"`
+	// Stake amount in wei which coordinator needs to submit a new batch
+	// Read from the governance contract, used while sending new batch
+	StakeAmount BigInt `json:"stakeAmount" gorm:"type:varchar(78)"`
 
 	// MaxDepth is the maximum depth of the balances tree possible
 	// If in case we want to increase it we will update the value on the contract
@@ -21,6 +20,8 @@ type Params struct {
 	// It is set on the contract and will be updated when that value changes
 	MaxDepositSubTreeHeight uint64 `json:"maxDepositSubTreeHeight"`
 
+	// FinalisationTime is the number of blocks after which a batch is finalised
+	// It is set on the contract and will be updated when that value changes
 	FinalisationTime uint64 `json:"finalisationTime"`
 }
 
@@ -32,6 +33,49 @@ type SyncStatus struct {
 
 	// Last batch index is recorded for this field
 	LastBatchRecorded uint64 `json:"lastBatchRecorded"`
+
+	// Position of the last event applied after LastEthBlockRecorded, so that the events of
+	// a range which failed midway aren't applied twice when it is retried
+	CursorBlock    uint64 `json:"cursorBlock"`
+	CursorLogIndex uint64 `json:"cursorLogIndex"`
+
+	// State of the syncer, 0 until it first processes a head
+	State uint64 `json:"state"`
+
+	// Latest main chain head seen by the syncer
+	HeadBlock uint64 `json:"headBlock"`
+}
+
+// SyncStateName returns the printable name of the sync state
+func SyncStateName(state uint64) string {
+	switch state {
+	case SYNC_STATE_CATCHING_UP:
+		return "catching_up"
+	case SYNC_STATE_SYNCED:
+		return "synced"
+	case SYNC_STATE_DEGRADED:
+		return "degraded"
+	default:
+		return "unknown"
+	}
+}
+
+// UpdateSyncState records the state of the syncer and the latest head it has seen
+func (db *DB) UpdateSyncState(state, headBlock uint64) error {
+	return db.Instance.Model(&SyncStatus{}).Updates(map[string]interface{}{
+		"state":      state,
+		"head_block": headBlock,
+	}).Error
+}
+
+// IsSynced returns true if the syncer is caught up with the main chain
+// on-chain actions shouldn't be taken on state which isn't
+func (db *DB) IsSynced() (bool, error) {
+	status, err := db.GetSyncStatus()
+	if err != nil {
+		return false, err
+	}
+	return status.State == SYNC_STATE_SYNCED, nil
 }
 
 func (ss *SyncStatus) LastEthBlockBigInt() *big.Int {
@@ -39,6 +83,25 @@ func (ss *SyncStatus) LastEthBlockBigInt() *big.Int {
 	return n.SetUint64(ss.LastEthBlockRecorded)
 }
 
+// IsApplied returns true if the event at the position was applied already
+func (ss *SyncStatus) IsApplied(blockNumber, logIndex uint64) bool {
+	if blockNumber <= ss.LastEthBlockRecorded {
+		return true
+	}
+	if ss.CursorBlock <= ss.LastEthBlockRecorded {
+		return false
+	}
+	return blockNumber < ss.CursorBlock || (blockNumber == ss.CursorBlock && logIndex <= ss.CursorLogIndex)
+}
+
+// UpdateSyncCursor records the position of the last event applied
+func (db *DB) UpdateSyncCursor(blockNumber, logIndex uint64) error {
+	return db.Instance.Model(&SyncStatus{}).Updates(map[string]interface{}{
+		"cursor_block":     blockNumber,
+		"cursor_log_index": logIndex,
+	}).Error
+}
+
 func (db *DB) UpdateSyncStatusWithBatchNumber(batchIndex uint64) error {
 	var updatedSyncStatus SyncStatus
 	updatedSyncStatus.LastBatchRecorded = batchIndex
@@ -63,21 +126,14 @@ func (db *DB) GetSyncStatus() (status SyncStatus, err error) {
 	return status, nil
 }
 
-// UpdateStakeAmount updates the stake amount
-func (db *DB) UpdateStakeAmount(newStakeAmount uint64) error {
+// UpdateGovernanceParams updates the params read from the governance contract
+func (db *DB) UpdateGovernanceParams(stakeAmount *big.Int, finalisationTime, maxDepositSubTreeHeight uint64) error {
 	var updatedParams Params
-	updatedParams.StakeAmount = newStakeAmount
-	if err := db.Instance.Table("params").Assign(Params{StakeAmount: newStakeAmount}).FirstOrCreate(&updatedParams).Error; err != nil {
-		return err
-	}
-	return nil
-}
-
-// UpdateFinalisationTimePerBatch updates the max depth
-func (db *DB) UpdateFinalisationTimePerBatch(finalisationDuration uint64) error {
-	var updatedParams Params
-	updatedParams.MaxDepth = finalisationDuration
-	if err := db.Instance.Table("params").Assign(Params{FinalisationTime: finalisationDuration}).FirstOrCreate(&updatedParams).Error; err != nil {
+	if err := db.Instance.Table("params").Assign(map[string]interface{}{
+		"stake_amount":                NewBigInt(stakeAmount),
+		"finalisation_time":           finalisationTime,
+		"max_deposit_sub_tree_height": maxDepositSubTreeHeight,
+	}).FirstOrCreate(&updatedParams).Error; err != nil {
 		return err
 	}
 	return nil
@@ -93,16 +149,6 @@ func (db *DB) UpdateMaxDepth(newDepth uint64) error {
 	return nil
 }
 
-// UpdateDepositSubTreeHeight updates the max height of deposit sub tree
-func (db *DB) UpdateDepositSubTreeHeight(newHeight uint64) error {
-	var updatedParams Params
-	updatedParams.MaxDepositSubTreeHeight = newHeight
-	if err := db.Instance.Table("params").Assign(Params{MaxDepositSubTreeHeight: newHeight}).FirstOrCreate(&updatedParams).Error; err != nil {
-		return err
-	}
-	return nil
-}
-
 func (db *DB) GetBatchFinalisationTime() (uint64, error) {
 	var params Params
 	if err := db.Instance.First(&params).Error; err != nil {
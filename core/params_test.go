@@ -0,0 +1,24 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncStatusIsApplied(t *testing.T) {
+	status := SyncStatus{LastEthBlockRecorded: 10}
+	require.True(t, status.IsApplied(10, 3))
+	require.False(t, status.IsApplied(11, 0))
+
+	// the range after block 10 failed after the event at block 12 index 2
+	status.CursorBlock, status.CursorLogIndex = 12, 2
+	require.True(t, status.IsApplied(11, 7))
+	require.True(t, status.IsApplied(12, 2))
+	require.False(t, status.IsApplied(12, 3))
+	require.False(t, status.IsApplied(13, 0))
+
+	// cursor is stale once the range is synced
+	status.LastEthBlockRecorded = 20
+	require.False(t, status.IsApplied(21, 0))
+}
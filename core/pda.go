@@ -327,12 +327,11 @@ func abiEncodePubkey(pubkey string) ([]byte, error) {
 	return bytes, nil
 }
 
+// GetPDALeafByID returns the PDA leaf holding the pubkey of the account, gorm.ErrRecordNotFound if none was registered
 func (db *DB) GetPDALeafByID(ID uint64) (PDA, error) {
 	var pda PDA
-	if err := db.Instance.Where("account_id = ?", ID).Find(&pda).Error; err != nil {
-		return pda, ErrRecordNotFound(fmt.Sprintf("unable to find record for ID: %v", ID))
-	}
-	return pda, nil
+	err := db.Instance.Where("account_id = ?", ID).Find(&pda).Error
+	return pda, err
 }
 
 func (db *DB) GetPDARoot() (PDA, error) {
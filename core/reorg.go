@@ -0,0 +1,345 @@
+package core
+
+// SyncedBlock is a main chain block the syncer applied the events up to, along with
+// where the local state was at that point so it can be unwound to it on a reorg
+type SyncedBlock struct {
+	Number     uint64 `gorm:"primary_key;auto_increment:false"`
+	Hash       string `gorm:"not null"`
+	ParentHash string `gorm:"not null"`
+
+	// latest journal entry once the events up to the block were applied
+	JournalSeq uint64
+
+	// latest batch committed on chain as of the block
+	LastBatchID uint64
+}
+
+// BatchStatusUndo records the status a batch had before an event in the block changed it
+type BatchStatusUndo struct {
+	ID          uint64 `gorm:"primary_key;AUTO_INCREMENT"`
+	BlockNumber uint64 `gorm:"index:BlockNumber"`
+	BatchID     uint64
+	Status      uint64
+}
+
+// DepositUndo records a pending deposit as it was before an event in the block changed it
+type DepositUndo struct {
+	ID          uint64 `gorm:"primary_key;AUTO_INCREMENT"`
+	BlockNumber uint64 `gorm:"index:BlockNumber"`
+	AccountID   uint64
+
+	// the deposit was queued by the event, it didn't exist before
+	Queued bool
+
+	Data                    []byte `gorm:"type:varbinary(255)"`
+	CreatedByDepositSubTree string
+}
+
+// PubkeyUndo records a PDA leaf as it was before an event in the block registered a pubkey in it
+type PubkeyUndo struct {
+	ID          uint64 `gorm:"primary_key;AUTO_INCREMENT"`
+	BlockNumber uint64 `gorm:"index:BlockNumber"`
+	Path        string `gorm:"not null"`
+	AccountID   uint64
+	PublicKey   string `gorm:"type:varchar(1000)"`
+}
+
+// AddSyncedBlock records a block the syncer is done with and forgets the blocks
+// which are more than keep blocks older, they can't be reorged anymore
+func (db *DB) AddSyncedBlock(block SyncedBlock, keep uint64) error {
+	if err := db.Instance.Save(&block).Error; err != nil {
+		return err
+	}
+	if block.Number <= keep {
+		return nil
+	}
+	return db.Instance.Where("number < ?", block.Number-keep).Delete(&SyncedBlock{}).Error
+}
+
+// GetSyncedBlocks returns at most limit synced blocks, newest first
+func (db *DB) GetSyncedBlocks(limit uint64) (blocks []SyncedBlock, err error) {
+	if err := db.Instance.Order("number desc").Limit(limit).Find(&blocks).Error; err != nil {
+		return blocks, err
+	}
+	return blocks, nil
+}
+
+// GetLastCommittedBatchID returns the ID of the latest batch seen on chain, 0 if there is none
+func (db *DB) GetLastCommittedBatchID() (uint64, error) {
+	var batches []Batch
+	if err := db.Instance.Where("status != ?", BATCH_BROADCASTED).Order("batch_id desc").Limit(1).Find(&batches).Error; err != nil {
+		return 0, err
+	}
+	if len(batches) == 0 {
+		return 0, nil
+	}
+	return batches[0].BatchID, nil
+}
+
+// JournalBatchStatus records the current status of the batch before an event in the block changes it
+func (db *DB) JournalBatchStatus(blockNumber, batchID uint64) error {
+	batch, err := db.GetBatchByIndex(batchID)
+	if err != nil {
+		return err
+	}
+	return db.Instance.Create(&BatchStatusUndo{BlockNumber: blockNumber, BatchID: batchID, Status: batch.Status}).Error
+}
+
+// JournalDepositQueued records that the deposit of the account is queued by an event in the block
+func (db *DB) JournalDepositQueued(blockNumber, accountID uint64) error {
+	return db.Instance.Create(&DepositUndo{BlockNumber: blockNumber, AccountID: accountID, Queued: true}).Error
+}
+
+// JournalPendingDeposits records the current state of all pending deposits before an event in the block changes them
+func (db *DB) JournalPendingDeposits(blockNumber uint64) error {
+	var accounts []UserAccount
+	if err := db.Instance.Where("status = ?", STATUS_PENDING).Find(&accounts).Error; err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		undo := DepositUndo{
+			BlockNumber:             blockNumber,
+			AccountID:               account.AccountID,
+			Data:                    account.Data,
+			CreatedByDepositSubTree: account.CreatedByDepositSubTree,
+		}
+		if err := db.Instance.Create(&undo).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JournalPubkey records the current state of the PDA leaf at path before an event in the block registers a pubkey in it
+func (db *DB) JournalPubkey(blockNumber uint64, path string) error {
+	leaf, err := db.GetPDALeafByPath(path)
+	if err != nil {
+		return err
+	}
+	return db.Instance.Create(&PubkeyUndo{BlockNumber: blockNumber, Path: leaf.Path, AccountID: leaf.AccountID, PublicKey: leaf.PublicKey}).Error
+}
+
+// UnwindTo takes the local state back to where it was once the events up to the block were applied
+// - account updates are undone using the journal, txs we applied since go back to pending
+// - batches committed since are dropped, along with their disputes and stake withdrawals
+// - batches we broadcasted are kept as the tx manager still tracks them, their txs are left to be applied once they land
+// - batch status changes made since are undone
+// - deposits queued and finalised, pubkeys and tokens registered since are undone
+func (db *DB) UnwindTo(block SyncedBlock) error {
+	// begin a transaction
+	mysqlTx := db.Instance.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			mysqlTx.Rollback()
+		}
+	}()
+	dbCopy := *db
+	dbCopy.Instance = mysqlTx
+
+	if err := dbCopy.unwindTo(block); err != nil {
+		mysqlTx.Rollback()
+		return err
+	}
+	return mysqlTx.Commit().Error
+}
+
+// returnUndoneTxs settles our txs whose updates were undone, along with the txs of the dropped batches
+// our batches which haven't been seen on chain yet may still land, their txs stay with them
+// and the ones which were undone are marked as such so that they are applied when the batch lands
+// the other txs go back to pending
+func (db *DB) returnUndoneTxs(undoneTxHashes []string, droppedBatchIDs []uint64) error {
+	var broadcastedBatchIDs []uint64
+	if err := db.Instance.Model(&Batch{}).Where("status = ?", BATCH_BROADCASTED).Pluck("batch_id", &broadcastedBatchIDs).Error; err != nil {
+		return err
+	}
+	if len(undoneTxHashes) != 0 && len(broadcastedBatchIDs) != 0 {
+		err := db.Instance.Model(&Tx{}).Where("tx_hash IN (?) AND batch_id IN (?)", undoneTxHashes, broadcastedBatchIDs).Update("status", TX_STATUS_PROCESSING).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(undoneTxHashes) == 0 && len(droppedBatchIDs) == 0 {
+		return nil
+	}
+	query := db.Instance.Model(&Tx{}).Where("tx_hash IN (?) OR batch_id IN (?)", undoneTxHashes, droppedBatchIDs)
+	if len(broadcastedBatchIDs) != 0 {
+		query = query.Where("batch_id NOT IN (?)", broadcastedBatchIDs)
+	}
+	return query.Updates(map[string]interface{}{
+		"status":   TX_STATUS_PENDING,
+		"batch_id": 0,
+	}).Error
+}
+
+func (db *DB) unwindTo(block SyncedBlock) error {
+	var undoneTxHashes []string
+	if err := db.Instance.Model(&UndoLog{}).Where("seq > ? AND tx_hash != ''", block.JournalSeq).Pluck("DISTINCT tx_hash", &undoneTxHashes).Error; err != nil {
+		return err
+	}
+	if err := db.rewindJournal(block.JournalSeq); err != nil {
+		return err
+	}
+
+	var droppedBatchIDs []uint64
+	if err := db.Instance.Model(&Batch{}).Where("batch_id > ? AND status != ?", block.LastBatchID, BATCH_BROADCASTED).Pluck("batch_id", &droppedBatchIDs).Error; err != nil {
+		return err
+	}
+	if err := db.returnUndoneTxs(undoneTxHashes, droppedBatchIDs); err != nil {
+		return err
+	}
+	if len(droppedBatchIDs) != 0 {
+		if err := db.Instance.Where("batch_id IN (?)", droppedBatchIDs).Delete(&Batch{}).Error; err != nil {
+			return err
+		}
+		if err := db.Instance.Where("batch_id IN (?)", droppedBatchIDs).Delete(&Dispute{}).Error; err != nil {
+			return err
+		}
+		if err := db.Instance.Where("batch_id IN (?)", droppedBatchIDs).Delete(&StakeWithdrawal{}).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := db.undoBatchStatuses(block.Number); err != nil {
+		return err
+	}
+	if err := db.undoDeposits(block.Number); err != nil {
+		return err
+	}
+	if err := db.undoPubkeys(block.Number); err != nil {
+		return err
+	}
+	if err := db.undoTokens(block.Number); err != nil {
+		return err
+	}
+
+	if err := db.Instance.Where("number > ?", block.Number).Delete(&SyncedBlock{}).Error; err != nil {
+		return err
+	}
+	return db.Instance.Model(&SyncStatus{}).Updates(map[string]interface{}{
+		"last_eth_block_recorded": block.Number,
+		"last_batch_recorded":     block.LastBatchID,
+		"cursor_block":            0,
+		"cursor_log_index":        0,
+	}).Error
+}
+
+// statusesToRestore returns the status each batch had before the first of the undone changes,
+// undos are ordered newest first
+func statusesToRestore(undos []BatchStatusUndo) map[uint64]uint64 {
+	restored := make(map[uint64]uint64)
+	for _, undo := range undos {
+		restored[undo.BatchID] = undo.Status
+	}
+	return restored
+}
+
+// undoBatchStatuses restores the statuses batches had before the events after the block,
+// along with what was recorded alongside the status changes
+func (db *DB) undoBatchStatuses(blockNumber uint64) error {
+	var undos []BatchStatusUndo
+	if err := db.Instance.Where("block_number > ?", blockNumber).Order("id desc").Find(&undos).Error; err != nil {
+		return err
+	}
+
+	for batchID, status := range statusesToRestore(undos) {
+		if err := db.Instance.Model(&Batch{}).Where("batch_id = ?", batchID).Update("status", status).Error; err != nil {
+			return err
+		}
+		if status != BATCH_ROLLED_BACK {
+			if err := db.Instance.Model(&Dispute{}).Where("batch_id = ?", batchID).Update("rolled_back", false).Error; err != nil {
+				return err
+			}
+		}
+		if status != BATCH_STAKE_WITHDRAWN {
+			if err := db.Instance.Model(&StakeWithdrawal{}).Where("batch_id = ?", batchID).Update("withdrawn", false).Error; err != nil {
+				return err
+			}
+		}
+		if status == BATCH_COMMITTED {
+			if err := db.Instance.Model(&Tx{}).Where("batch_id = ? AND status = ?", batchID, TX_STATUS_FINALISED).Update("status", TX_STATUS_PROCESSED).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return db.Instance.Where("block_number > ?", blockNumber).Delete(&BatchStatusUndo{}).Error
+}
+
+// undoDeposits restores the pending deposits as they were before the events after the block
+// Deposits finalised since are pending again, their leaves are restored by the journal
+func (db *DB) undoDeposits(blockNumber uint64) error {
+	var undos []DepositUndo
+	if err := db.Instance.Where("block_number > ?", blockNumber).Order("id desc").Find(&undos).Error; err != nil {
+		return err
+	}
+	for _, undo := range undos {
+		if undo.Queued {
+			if err := db.DeletePendingAccount(undo.AccountID); err != nil {
+				return err
+			}
+			continue
+		}
+		var pending []UserAccount
+		if err := db.Instance.Where("account_id = ? AND status = ?", undo.AccountID, STATUS_PENDING).Find(&pending).Error; err != nil {
+			return err
+		}
+		if len(pending) != 0 {
+			err := db.Instance.Model(&UserAccount{}).Where("account_id = ? AND status = ?", undo.AccountID, STATUS_PENDING).Update("created_by_deposit_sub_tree", undo.CreatedByDepositSubTree).Error
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		account := NewPendingUserAccount(undo.AccountID, undo.Data)
+		account.CreatedByDepositSubTree = undo.CreatedByDepositSubTree
+		if err := db.AddNewPendingAccount(*account); err != nil {
+			return err
+		}
+	}
+	return db.Instance.Where("block_number > ?", blockNumber).Delete(&DepositUndo{}).Error
+}
+
+// undoPubkeys restores the PDA leaves as they were before the pubkeys registered after the block
+func (db *DB) undoPubkeys(blockNumber uint64) error {
+	var undos []PubkeyUndo
+	if err := db.Instance.Where("block_number > ?", blockNumber).Order("id desc").Find(&undos).Error; err != nil {
+		return err
+	}
+	for _, undo := range undos {
+		leaf := PDA{AccountID: undo.AccountID, PublicKey: undo.PublicKey, Type: TYPE_TERMINAL}
+		leaf.UpdatePath(undo.Path)
+		if err := leaf.PopulateHash(); err != nil {
+			return err
+		}
+		// zero values aren't written by the tree updates, an empty leaf has none
+		err := db.Instance.Model(&PDA{}).Where("path = ?", leaf.Path).Updates(map[string]interface{}{
+			"account_id": leaf.AccountID,
+			"public_key": leaf.PublicKey,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := db.UpdatePDALeaf(leaf); err != nil {
+			return err
+		}
+	}
+	return db.Instance.Where("block_number > ?", blockNumber).Delete(&PubkeyUndo{}).Error
+}
+
+// undoTokens drops the tokens registered after the block, along with the requests made since
+// Tokens requested before the block are waiting to be registered again
+func (db *DB) undoTokens(blockNumber uint64) error {
+	var addresses []string
+	if err := db.Instance.Model(&Token{}).Where("block_number > ?", blockNumber).Pluck("address", &addresses).Error; err != nil {
+		return err
+	}
+	if len(addresses) != 0 {
+		if err := db.Instance.Where("block_number > ?", blockNumber).Delete(&Token{}).Error; err != nil {
+			return err
+		}
+		err := db.Instance.Unscoped().Model(&TokenRegistrationRequest{}).Where("address IN (?)", addresses).Update("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+	}
+	return db.Instance.Unscoped().Where("block_number > ?", blockNumber).Delete(&TokenRegistrationRequest{}).Error
+}
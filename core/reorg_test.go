@@ -0,0 +1,36 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// undosAfter returns the journaled status changes made after the block, newest first as undoBatchStatuses reads them
+func undosAfter(journal []BatchStatusUndo, blockNumber uint64) (undos []BatchStatusUndo) {
+	for i := len(journal) - 1; i >= 0; i-- {
+		if journal[i].BlockNumber > blockNumber {
+			undos = append(undos, journal[i])
+		}
+	}
+	return undos
+}
+
+func TestStatusesToRestore(t *testing.T) {
+	// batch 1 is finalised and its stake withdrawn, batch 2 is disputed and rolled back
+	journal := []BatchStatusUndo{
+		{BlockNumber: 20, BatchID: 1, Status: BATCH_COMMITTED},
+		{BlockNumber: 21, BatchID: 2, Status: BATCH_COMMITTED},
+		{BlockNumber: 22, BatchID: 1, Status: BATCH_FINALISED},
+		{BlockNumber: 23, BatchID: 2, Status: BATCH_DISPUTED},
+	}
+
+	// the finalisation survives a reorg past it
+	require.Equal(t, map[uint64]uint64{1: BATCH_FINALISED, 2: BATCH_DISPUTED}, statusesToRestore(undosAfter(journal, 21)))
+
+	// and is undone along with what followed by a reorg before it
+	require.Equal(t, map[uint64]uint64{1: BATCH_COMMITTED, 2: BATCH_COMMITTED}, statusesToRestore(undosAfter(journal, 19)))
+	require.Equal(t, map[uint64]uint64{1: BATCH_FINALISED, 2: BATCH_COMMITTED}, statusesToRestore(undosAfter(journal, 20)))
+
+	require.Empty(t, statusesToRestore(undosAfter(journal, 23)))
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"math/big"
+)
+
+// StakeWithdrawal tracks the withdrawal of the stake locked by a batch committed by this node
+type StakeWithdrawal struct {
+	DBModel
+
+	BatchID uint64 `json:"batchID" gorm:"unique_index"`
+
+	// set once the StakeWithdraw event is seen, whoever sent the withdrawal
+	Withdrawn bool   `json:"withdrawn"`
+	Amount    BigInt `json:"amount" gorm:"type:varchar(78)"`
+
+	// L1 submission details, SubmissionStatus is 0 until the withdrawal is first sent
+	SubmissionHash     string `json:"submissionHash"`
+	SubmissionNonce    uint64 `json:"submissionNonce"`
+	SubmissionGasPrice string `json:"submissionGasPrice"`
+	SubmissionAttempts uint64 `json:"submissionAttempts"`
+	SubmissionStatus   uint64 `json:"submissionStatus"`
+	IncludedInBlock    uint64 `json:"includedInBlock"`
+}
+
+// StakeSummary is the stake locked by the batches committed by an account
+type StakeSummary struct {
+	// stake of the committed batches which haven't been rolled back or withdrawn
+	Locked BigInt `json:"locked"`
+
+	// part of the locked stake whose batches are final
+	Withdrawable BigInt `json:"withdrawable"`
+
+	// batches holding the locked stake
+	LockedBatches       uint64 `json:"lockedBatches"`
+	WithdrawableBatches uint64 `json:"withdrawableBatches"`
+}
+
+// GetStakeWithdrawal returns the withdrawal of the batch, creating it if it doesn't exist
+func (db *DB) GetStakeWithdrawal(batchID uint64) (withdrawal StakeWithdrawal, err error) {
+	if err := db.Instance.Where(StakeWithdrawal{BatchID: batchID}).FirstOrCreate(&withdrawal).Error; err != nil {
+		return withdrawal, err
+	}
+	return withdrawal, nil
+}
+
+// GetStakeWithdrawalsBySubmissionStatus returns all withdrawals with the given L1 submission status
+func (db *DB) GetStakeWithdrawalsBySubmissionStatus(status uint64) (withdrawals []StakeWithdrawal, err error) {
+	if err := db.Instance.Where("submission_status = ?", status).Find(&withdrawals).Error; err != nil {
+		return withdrawals, err
+	}
+	return withdrawals, nil
+}
+
+// UpdateStakeWithdrawalSubmission records the latest broadcast of the withdrawal on the main chain
+func (db *DB) UpdateStakeWithdrawalSubmission(batchID uint64, txHash string, nonce uint64, gasPrice string, attempts uint64) error {
+	return db.Instance.Model(&StakeWithdrawal{}).Where("batch_id = ?", batchID).Updates(map[string]interface{}{
+		"submission_hash":      txHash,
+		"submission_nonce":     nonce,
+		"submission_gas_price": gasPrice,
+		"submission_attempts":  attempts,
+		"submission_status":    SUBMISSION_PENDING,
+	}).Error
+}
+
+// UpdateStakeWithdrawalSubmissionOutcome records the final outcome of the withdrawal submission
+func (db *DB) UpdateStakeWithdrawalSubmissionOutcome(batchID uint64, txHash string, status uint64, includedInBlock uint64) error {
+	return db.Instance.Model(&StakeWithdrawal{}).Where("batch_id = ?", batchID).Updates(map[string]interface{}{
+		"submission_hash":   txHash,
+		"submission_status": status,
+		"included_in_block": includedInBlock,
+	}).Error
+}
+
+// RecordStakeWithdrawn records the withdrawal of the stake of the batch seen on chain
+func (db *DB) RecordStakeWithdrawn(batchID uint64, amount *big.Int) error {
+	if _, err := db.GetStakeWithdrawal(batchID); err != nil {
+		return err
+	}
+	err := db.Instance.Model(&StakeWithdrawal{}).Where("batch_id = ?", batchID).Updates(map[string]interface{}{
+		"withdrawn": true,
+		"amount":    NewBigInt(amount),
+	}).Error
+	if err != nil {
+		return err
+	}
+	batch, err := db.GetBatchByIndex(batchID)
+	if err != nil {
+		return err
+	}
+	// stake can only be withdrawn once the batch is final, we might not have caught up yet
+	if batch.Status == BATCH_COMMITTED {
+		if err := db.UpdateBatchStatus(batchID, BATCH_FINALISED); err != nil {
+			return err
+		}
+	}
+	return db.UpdateBatchStatus(batchID, BATCH_STAKE_WITHDRAWN)
+}
+
+// GetBatchesWithLockedStake returns the committed and finalised batches of the committer which still hold stake
+func (db *DB) GetBatchesWithLockedStake(committer string) (batches []Batch, err error) {
+	statuses := []uint64{BATCH_COMMITTED, BATCH_FINALISED}
+	if err := db.Instance.Where("committer = ? AND status IN (?)", committer, statuses).Order("batch_id asc").Find(&batches).Error; err != nil {
+		return batches, err
+	}
+	return batches, nil
+}
+
+// UpdateBatchFinalisesOn records the block after which the batch is final
+func (db *DB) UpdateBatchFinalisesOn(batchID uint64, finalisesOn uint64) error {
+	return db.Instance.Model(&Batch{}).Where("batch_id = ?", batchID).Update("finalises_on", finalisesOn).Error
+}
+
+// GetStakeSummary returns the stake locked by the batches of the committer as of the given block
+func (db *DB) GetStakeSummary(committer string, currentBlock uint64) (summary StakeSummary, err error) {
+	batches, err := db.GetBatchesWithLockedStake(committer)
+	if err != nil {
+		return summary, err
+	}
+	locked, withdrawable := big.NewInt(0), big.NewInt(0)
+	for _, batch := range batches {
+		locked.Add(locked, batch.StakeAmount.BigInt())
+		summary.LockedBatches++
+		if batch.Status == BATCH_FINALISED || batch.IsFinal(currentBlock) {
+			withdrawable.Add(withdrawable, batch.StakeAmount.BigInt())
+			summary.WithdrawableBatches++
+		}
+	}
+	summary.Locked = NewBigInt(locked)
+	summary.Withdrawable = NewBigInt(withdrawable)
+	return summary, nil
+}
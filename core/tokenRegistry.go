@@ -6,16 +6,64 @@ type Token struct {
 
 	// token ID allocated to the token
 	TokenID uint64 `json:"tokenID"`
+
+	// ERC20 metadata, empty if the token contract doesn't expose it
+	Symbol   string `json:"symbol"`
+	Decimals uint64 `json:"decimals"`
+
+	// block the token was registered in, to drop it if the block is reorged away
+	BlockNumber uint64 `json:"-"`
+}
+
+// TokenRegistrationRequest is a token waiting to be registered
+// Requests of registered tokens are soft deleted, they are waiting again if the registration is reorged away
+type TokenRegistrationRequest struct {
+	DBModel
+
+	Address     string `json:"address" gorm:"unique_index"`
+	BlockNumber uint64 `json:"blockNumber"`
+	TxHash      string `json:"txHash"`
 }
 
 func (db *DB) AddToken(t Token) error {
 	return db.Instance.Create(t).Error
 }
 
-func (db *DB) GetTokenByID(id uint) (token Token, err error) {
+func (db *DB) GetTokenByID(id uint64) (token Token, err error) {
 	err = db.Instance.Where("token_id = ?", id).First(&token).Error
 	if err != nil {
 		return
 	}
 	return
 }
+
+// GetTokens returns all registered tokens, by ID
+func (db *DB) GetTokens() (tokens []Token, err error) {
+	if err := db.Instance.Order("token_id asc").Find(&tokens).Error; err != nil {
+		return tokens, err
+	}
+	return tokens, nil
+}
+
+// AddTokenRegistrationRequest records a token waiting to be registered, requesting it again is a no-op
+func (db *DB) AddTokenRegistrationRequest(request TokenRegistrationRequest) error {
+	return db.Instance.Unscoped().Where(TokenRegistrationRequest{Address: request.Address}).Attrs(request).FirstOrCreate(&TokenRegistrationRequest{}).Error
+}
+
+// GetTokenRegistrationRequests returns the tokens waiting to be registered, oldest first
+func (db *DB) GetTokenRegistrationRequests() (requests []TokenRegistrationRequest, err error) {
+	if err := db.Instance.Order("block_number asc").Find(&requests).Error; err != nil {
+		return requests, err
+	}
+	return requests, nil
+}
+
+// RegisterToken adds the token and drops its registration request, within a single DB transaction
+func (db *DB) RegisterToken(t Token) error {
+	return db.Transaction(func(tx DB) error {
+		if err := tx.AddToken(t); err != nil {
+			return err
+		}
+		return tx.Instance.Where("address = ?", t.Address).Delete(&TokenRegistrationRequest{}).Error
+	})
+}
@@ -2,14 +2,12 @@ package core
 
 import (
 	"fmt"
+	"strings"
 
 	"encoding/hex"
 
-	"github.com/BOPR/config"
-	ethCmn "github.com/ethereum/go-ethereum/common"
-
+	"github.com/BOPR/common"
 	"github.com/ethereum/go-ethereum/rlp"
-	"golang.org/x/crypto/sha3"
 )
 
 // Tx represets the transaction on hubble
@@ -22,17 +20,22 @@ type Tx struct {
 	TxHash    string `json:"hash" gorm:"not null"`
 	Status    uint64 `json:"status"`
 	Type      uint64 `json:"type"`
+
+	// batch the tx was submitted in, 0 until then
+	BatchID uint64 `json:"batchID" gorm:"index:BatchID"`
 }
 
 // NewTx creates a new transaction
 func NewTx(from, to, txType uint64, message []byte, sig string) Tx {
-	return Tx{
+	tx := Tx{
 		From:      from,
 		To:        to,
 		Data:      message,
 		Signature: sig,
 		Type:      txType,
 	}
+	tx.AssignHash()
+	return tx
 }
 
 // NewPendingTx creates a new transaction
@@ -55,70 +58,56 @@ func (tx Tx) GetSignBytes() (signBytes []byte) {
 }
 
 // AssignHash creates a tx hash and add it to the tx
+// The hash only depends on the signed message so that it matches RollupUtils.HashFromTx
+// and every node replaying the batch from L1 arrives at the same hash
 func (t *Tx) AssignHash() {
-	if t.TxHash != "" {
-		return
-	}
-	hash := rlpHash(t)
-	t.TxHash = hash.String()
+	t.TxHash = common.Keccak256(t.GetSignBytes()).String()
 }
 
-func (tx *Tx) Apply(updatedFrom, updatedTo []byte) error {
-	// get fresh copy of database
-	db, err := NewDB()
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	// begin a transaction
-	mysqlTx := db.Instance.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			mysqlTx.Rollback()
+// Apply updates the accounts of the tx and marks it as processed, within a single DB transaction
+func (tx *Tx) Apply(db DB, updatedFrom, updatedTo []byte) error {
+	return db.Transaction(func(dbTx DB) error {
+		err := dbTx.updateAccountsData([]uint64{tx.From, tx.To}, [][]byte{updatedFrom, updatedTo}, tx.TxHash)
+		if err != nil {
+			return err
 		}
-	}()
-
-	// post this perform all ops on transaction
-	db.Instance = mysqlTx
-
-	// apply transaction on from account
-	fromAcc, err := db.GetAccountByID(tx.From)
-	if err != nil {
-		mysqlTx.Rollback()
-		return err
-	}
-
-	fromAcc.Data = updatedFrom
+		return dbTx.updateTxStatus(tx, TX_STATUS_PROCESSED)
+	})
+}
 
-	err = db.UpdateAccount(fromAcc)
-	if err != nil {
-		mysqlTx.Rollback()
-		return err
-	}
+// updateAccountsData updates the data of the given accounts, in order
+// every update is journaled under txHash so that it can be undone
+func (db *DB) updateAccountsData(accountIDs []uint64, data [][]byte, txHash string) error {
+	for i, ID := range accountIDs {
+		acc, err := db.GetAccountByID(ID)
+		if err != nil {
+			return err
+		}
 
-	// apply transaction on to account
-	toAcc, err := db.GetAccountByID(tx.To)
-	if err != nil {
-		mysqlTx.Rollback()
-		return err
-	}
+		err = db.journalLeaf(acc.Path, txHash)
+		if err != nil {
+			return err
+		}
 
-	toAcc.Data = updatedTo
+		acc.Data = data[i]
 
-	err = db.UpdateAccount(toAcc)
-	if err != nil {
-		mysqlTx.Rollback()
-		return err
+		err = db.UpdateAccount(acc)
+		if err != nil {
+			return err
+		}
 	}
-
-	tx.UpdateStatus(TX_STATUS_PROCESSED)
-
-	// Or commit the transaction
-	mysqlTx.Commit()
 	return nil
 }
 
+// CalldataSize estimates the number of bytes the tx adds to the submitBatch calldata
+// i.e the ABI encoding of the compressed tx which includes the message and the signature
+func (tx *Tx) CalldataSize() uint64 {
+	sigLen := len(strings.TrimPrefix(tx.Signature, "0x")) / 2
+	payload := uint64(len(tx.Data) + sigLen)
+	// offset and length words followed by the payload padded to 32 bytes
+	return 64 + (payload+31)/32*32
+}
+
 func (t *Tx) String() string {
 	return fmt.Sprintf("To: %v From: %v Status:%v Hash: %v Data: %v", t.To, t.From, t.Status, t.TxHash, hex.EncodeToString(t.Data))
 }
@@ -138,53 +127,94 @@ func (db *DB) InsertTx(t *Tx) error {
 	return db.Instance.Create(t).Error
 }
 
-func (db *DB) PopTxs() (txs []Tx, err error) {
-	txType, err := db.FetchTxType()
-	tx := db.Instance.Begin()
+// GetPendingTxs fetches upto `limit` pending txs of the given type, oldest first
+// A limit of 0 fetches all pending txs
+func (db *DB) GetPendingTxs(txType uint64, limit uint64) (txs []Tx, err error) {
+	query := db.Instance.Where(&Tx{Status: TX_STATUS_PENDING, Type: txType}).Order("created_at asc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&txs).Error; err != nil {
+		db.Logger.Error("error while fetching pending transactions", "error", err)
+		return txs, err
+	}
+	return txs, nil
+}
+
+// MarkTxsAsProcessing moves the given txs from pending to processing so that
+// they are not picked up by another batch
+// Either all txs are moved or none is, ErrTxsAlreadyPicked is returned if some weren't pending anymore
+func (db *DB) MarkTxsAsProcessing(txs []Tx) error {
+	if len(txs) == 0 {
+		return nil
+	}
+	var ids []string
+	for _, tx := range txs {
+		ids = append(ids, tx.ID)
+	}
+	mysqlTx := db.Instance.Begin()
 	defer func() {
 		if r := recover(); r != nil {
-			tx.Rollback()
+			mysqlTx.Rollback()
 		}
 	}()
-	if err := tx.Error; err != nil {
-		return txs, err
+	result := mysqlTx.Table("txes").Where("id IN (?) AND status = ?", ids, TX_STATUS_PENDING).Updates(map[string]interface{}{"status": TX_STATUS_PROCESSING})
+	if result.Error != nil {
+		mysqlTx.Rollback()
+		return result.Error
 	}
-	var pendingTxs []Tx
-	// select N number of transactions which are pending in mempool and
-	if err := tx.Limit(config.GlobalCfg.TxsPerBatch).Where(&Tx{Status: TX_STATUS_PENDING, Type: txType}).Find(&pendingTxs).Error; err != nil {
-		db.Logger.Error("error while fetching pending transactions", err)
-		return txs, err
+	if result.RowsAffected != int64(len(ids)) {
+		mysqlTx.Rollback()
+		return ErrTxsAlreadyPicked
 	}
-	db.Logger.Info("found txs", "pendingTxs", pendingTxs)
+	return mysqlTx.Commit().Error
+}
+
+// AssignTxsToBatch records the batch the given txs were submitted in
+func (db *DB) AssignTxsToBatch(txs []Tx, batchID uint64) error {
 	var ids []string
-	for _, tx := range pendingTxs {
-		ids = append(ids, tx.ID)
+	for _, tx := range txs {
+		if tx.ID != "" {
+			ids = append(ids, tx.ID)
+		}
 	}
-	// update the transactions from pending to processing
-	errs := tx.Table("txes").Where("id IN (?)", ids).Updates(map[string]interface{}{"status": TX_STATUS_PROCESSING}).GetErrors()
-	if err != nil {
-		db.Logger.Error("errors while processing transactions", errs)
-		return
+	if len(ids) == 0 {
+		return nil
 	}
-	return pendingTxs, tx.Commit().Error
+	return db.Instance.Table("txes").Where("id IN (?)", ids).Updates(map[string]interface{}{"batch_id": batchID}).Error
 }
 
-func (db *DB) FetchTxType() (txType uint64, err error) {
-	// find out which txType has the highest count
-	var maxTxType uint64
-	var maxCount uint64
-	txTypes := []uint64{TX_TRANSFER_TYPE}
+// GetUnappliedBatchTxCount returns the number of txs of our batch which a reorg unwound, they are applied once the batch lands
+func (db *DB) GetUnappliedBatchTxCount(batchID uint64) (uint64, error) {
+	var count uint64
+	err := db.Instance.Model(&Tx{}).Where("batch_id = ? AND status = ?", batchID, TX_STATUS_PROCESSING).Count(&count).Error
+	return count, err
+}
+
+// SettleUnappliedBatchTxs marks the unwound txs of our batch as processed once the batch applied them,
+// or returns them to the pending pool if the batch that landed didn't apply them
+func (db *DB) SettleUnappliedBatchTxs(batchID uint64, applied bool) error {
+	query := db.Instance.Model(&Tx{}).Where("batch_id = ? AND status = ?", batchID, TX_STATUS_PROCESSING)
+	if applied {
+		return query.Update("status", TX_STATUS_PROCESSED).Error
+	}
+	return query.Updates(map[string]interface{}{
+		"status":   TX_STATUS_PENDING,
+		"batch_id": 0,
+	}).Error
+}
+
+// GetPendingTxCountPerType returns the number of pending txs for each of the given tx types
+func (db *DB) GetPendingTxCountPerType(txTypes []uint64) (map[uint64]uint64, error) {
+	counts := make(map[uint64]uint64)
 	for _, txType := range txTypes {
 		count, err := db.GetCountPerTxType(txType)
 		if err != nil {
-			return 0, err
-		}
-		if count > maxCount {
-			maxTxType = txType
-			maxCount = count
+			return counts, err
 		}
+		counts[txType] = count
 	}
-	return maxTxType, nil
+	return counts, nil
 }
 
 func (db *DB) GetCountPerTxType(txType uint64) (uint64, error) {
@@ -193,6 +223,19 @@ func (db *DB) GetCountPerTxType(txType uint64) (uint64, error) {
 	return count, err
 }
 
+// GetPendingTxCountFrom returns the number of txs sent by the account waiting in the mempool
+func (db *DB) GetPendingTxCountFrom(accountID uint64) (uint64, error) {
+	var count uint64
+	err := db.Instance.Model(&Tx{}).Where("`from` = ? AND status = ?", accountID, TX_STATUS_PENDING).Count(&count).Error
+	return count, err
+}
+
+// GetTxByHash fetches the tx with the given hash from the mempool
+func (db *DB) GetTxByHash(hash string) (tx Tx, err error) {
+	err = db.Instance.Where("tx_hash = ?", hash).First(&tx).Error
+	return tx, err
+}
+
 func (db *DB) GetTx() (tx []Tx, err error) {
 	err = db.Instance.First(&tx).Error
 	if err != nil {
@@ -202,24 +245,33 @@ func (db *DB) GetTx() (tx []Tx, err error) {
 }
 
 func (tx *Tx) UpdateStatus(status uint64) error {
-	return DBInstance.Instance.Model(&tx).Update("status", status).Error
+	return DBInstance.updateTxStatus(tx, status)
+}
+
+func (db *DB) updateTxStatus(tx *Tx, status uint64) error {
+	tx.Status = status
+	// txs replayed from batches on chain aren't stored, updating with a blank ID would update every tx
+	if tx.ID == "" {
+		return nil
+	}
+	return db.Instance.Model(tx).Update("status", status).Error
 }
 
 // GetVerificationData fetches all the data required to prove validity fo transaction
-func (tx *Tx) GetVerificationData() (fromMerkleProof, toMerkleProof AccountMerkleProof, PDAProof PDAMerkleProof, err error) {
-	fromAcc, err := DBInstance.GetAccountByID(tx.From)
+func (tx *Tx) GetVerificationData(db DB) (fromMerkleProof, toMerkleProof AccountMerkleProof, PDAProof PDAMerkleProof, err error) {
+	fromAcc, err := db.GetAccountByID(tx.From)
 	if err != nil {
 		return
 	}
-	fromSiblings, err := DBInstance.GetSiblings(fromAcc.Path)
+	fromSiblings, err := db.GetSiblings(fromAcc.Path)
 	if err != nil {
 		return
 	}
-	fromPDA, err := DBInstance.GetPDALeafByID(tx.From)
+	fromPDA, err := db.GetPDALeafByID(tx.From)
 	if err != nil {
 		return
 	}
-	fromPDASiblings, err := DBInstance.GetPDASiblings(fromPDA.Path)
+	fromPDASiblings, err := db.GetPDASiblings(fromPDA.Path)
 	if err != nil {
 		return
 	}
@@ -232,49 +284,38 @@ func (tx *Tx) GetVerificationData() (fromMerkleProof, toMerkleProof AccountMerkl
 	// 	return
 	// }
 	fromMerkleProof = NewAccountMerkleProof(fromAcc, fromSiblings)
-	toAcc, err := DBInstance.GetAccountByID(tx.To)
+	toAcc, err := db.GetAccountByID(tx.To)
 	if err != nil {
 		return
 	}
+
+	// the to proof is against the state once the from account is updated, the update is discarded
 	var toSiblings []UserAccount
-	mysqlTx := DBInstance.Instance.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			mysqlTx.Rollback()
+	err = db.scratch(func(dbCopy DB) error {
+		updatedFromAccountBytes, _, err := LoadedBazooka.ApplyTx(fromMerkleProof, *tx)
+		if err != nil {
+			return err
 		}
-	}()
-	dbCopy, _ := NewDB()
-	dbCopy.Instance = mysqlTx
-	updatedFromAccountBytes, _, err := LoadedBazooka.ApplyTx(fromMerkleProof, *tx)
-	if err != nil {
-		return
-	}
 
-	fromAcc.Data = updatedFromAccountBytes
-	err = dbCopy.UpdateAccount(fromAcc)
-	if err != nil {
-		return
-	}
+		fromAcc.Data = updatedFromAccountBytes
+		err = dbCopy.UpdateAccount(fromAcc)
+		if err != nil {
+			return err
+		}
 
-	// TODO add a check to ensure that DB copy of state matches the one returned by ApplyTransferTx
-	toSiblings, err = dbCopy.GetSiblings(toAcc.Path)
+		// TODO add a check to ensure that DB copy of state matches the one returned by ApplyTransferTx
+		toSiblings, err = dbCopy.GetSiblings(toAcc.Path)
+		return err
+	})
 	if err != nil {
 		return
 	}
 
 	toMerkleProof = NewAccountMerkleProof(toAcc, toSiblings)
 	PDAProof = NewPDAProof(fromPDA.Path, fromPDA.PublicKey, fromPDASiblings)
-	mysqlTx.Rollback()
 	return fromMerkleProof, toMerkleProof, PDAProof, nil
 }
 
-func rlpHash(x interface{}) (h ethCmn.Hash) {
-	hw := sha3.NewLegacyKeccak256()
-	rlp.Encode(hw, x)
-	hw.Sum(h[:0])
-	return h
-}
-
 func ConcatTxs(txs [][]byte) []byte {
 	var concatenatedTxs []byte
 	for _, tx := range txs {
@@ -282,3 +323,19 @@ func ConcatTxs(txs [][]byte) []byte {
 	}
 	return concatenatedTxs
 }
+
+// EncodeBatchTxs encodes the txs of a batch for storage, unlike ConcatTxs every tx can be told apart again
+func EncodeBatchTxs(txs [][]byte) ([]byte, error) {
+	return rlp.EncodeToBytes(txs)
+}
+
+// DecodeBatchTxs returns the txs of a batch stored by EncodeBatchTxs, batches stored without txs have none
+func DecodeBatchTxs(encoded []byte) (txs [][]byte, err error) {
+	if len(encoded) == 0 {
+		return nil, nil
+	}
+	if err := rlp.DecodeBytes(encoded, &txs); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
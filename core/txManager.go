@@ -0,0 +1,460 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/BOPR/common"
+	"github.com/BOPR/config"
+	"github.com/ethereum/go-ethereum"
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	L1TxManagerService = "l1-tx-manager"
+)
+
+// L1TxManager is the global tx manager through which all operator txs are sent to the main chain
+var L1TxManager *TxManager
+
+// SubmissionRecorder persists the progress of an L1 submission on the row it belongs to
+type SubmissionRecorder interface {
+	RecordBroadcast(txHash string, nonce uint64, gasPrice string, attempts uint64) error
+	RecordOutcome(txHash string, status uint64, includedInBlock uint64) error
+	String() string
+}
+
+// BatchSubmission records the submission of a batch on the batch row
+type BatchSubmission struct {
+	BatchID uint64
+}
+
+func (s BatchSubmission) RecordBroadcast(txHash string, nonce uint64, gasPrice string, attempts uint64) error {
+	return DBInstance.UpdateBatchSubmission(s.BatchID, txHash, nonce, gasPrice, attempts)
+}
+
+// a batch which never made it on chain is undone, its txs go back to pending
+func (s BatchSubmission) RecordOutcome(txHash string, status uint64, includedInBlock uint64) error {
+	if err := DBInstance.UpdateBatchSubmissionOutcome(txHash, status, includedInBlock); err != nil {
+		return err
+	}
+	if status != SUBMISSION_FAILED {
+		return nil
+	}
+	StateLock.Lock()
+	defer StateLock.Unlock()
+	return DBInstance.DropFailedBatch(txHash)
+}
+
+func (s BatchSubmission) String() string {
+	return fmt.Sprintf("batch %v", s.BatchID)
+}
+
+// DisputeSubmission records the submission of a dispute on the dispute row
+type DisputeSubmission struct {
+	BatchID uint64
+}
+
+func (s DisputeSubmission) RecordBroadcast(txHash string, nonce uint64, gasPrice string, attempts uint64) error {
+	return DBInstance.UpdateDisputeSubmission(s.BatchID, txHash, nonce, gasPrice, attempts)
+}
+
+func (s DisputeSubmission) RecordOutcome(txHash string, status uint64, includedInBlock uint64) error {
+	return DBInstance.UpdateDisputeSubmissionOutcome(s.BatchID, txHash, status, includedInBlock)
+}
+
+func (s DisputeSubmission) String() string {
+	return fmt.Sprintf("dispute of batch %v", s.BatchID)
+}
+
+// StakeWithdrawalSubmission records the submission of a stake withdrawal on the withdrawal row
+type StakeWithdrawalSubmission struct {
+	BatchID uint64
+}
+
+func (s StakeWithdrawalSubmission) RecordBroadcast(txHash string, nonce uint64, gasPrice string, attempts uint64) error {
+	return DBInstance.UpdateStakeWithdrawalSubmission(s.BatchID, txHash, nonce, gasPrice, attempts)
+}
+
+func (s StakeWithdrawalSubmission) RecordOutcome(txHash string, status uint64, includedInBlock uint64) error {
+	return DBInstance.UpdateStakeWithdrawalSubmissionOutcome(s.BatchID, txHash, status, includedInBlock)
+}
+
+func (s StakeWithdrawalSubmission) String() string {
+	return fmt.Sprintf("stake withdrawal of batch %v", s.BatchID)
+}
+
+// L1Client is the part of the main chain client the tx manager uses
+type L1Client interface {
+	ethereum.GasPricer
+	ethereum.GasEstimator
+	ethereum.TransactionSender
+	ethereum.TransactionReader
+	HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error)
+	NonceAt(ctx context.Context, account ethCmn.Address, blockNumber *big.Int) (uint64, error)
+}
+
+// trackedTx is a tx sent by the operator that hasn't got enough confirmations yet
+type trackedTx struct {
+	recorder SubmissionRecorder
+
+	// latest version of the tx that was broadcast
+	tx *ethTypes.Transaction
+
+	// hashes of all versions broadcast with the same nonce, any of them can get mined
+	hashes []ethCmn.Hash
+
+	sentAt   time.Time
+	attempts uint64
+
+	// head when the nonce was first seen used without a receipt for any version, 0 until then
+	nonceTakenAt uint64
+}
+
+// submissionOutcome is the outcome of a tracked tx, waiting to be recorded
+type submissionOutcome struct {
+	recorder   SubmissionRecorder
+	hash       ethCmn.Hash
+	status     uint64
+	includedIn uint64
+}
+
+// TxManager tracks the txs sent to the main chain until they reach the configured
+// number of confirmations. A tx that isn't mined in time is rebroadcast with the same
+// nonce and a higher gas price, never exceeding the configured ceiling.
+// The outcome of every submission is recorded on the row it belongs to.
+type TxManager struct {
+	// Base service
+	BaseService
+
+	client  L1Client
+	nonces  *NonceManager
+	key     *ecdsa.PrivateKey
+	account ethCmn.Address
+	cfg     config.Configuration
+
+	// tracked txs by nonce
+	tracked map[uint64]*trackedTx
+	mu      sync.Mutex
+
+	cancelTracking context.CancelFunc
+}
+
+// NewTxManager returns a new tx manager sending the operator txs via the given client
+func NewTxManager(client L1Client, nonces *NonceManager) *TxManager {
+	return newTxManager(client, nonces, config.OperatorKey, config.GlobalCfg)
+}
+
+func newTxManager(client L1Client, nonces *NonceManager, key *ecdsa.PrivateKey, cfg config.Configuration) *TxManager {
+	logger := common.Logger.With("module", L1TxManagerService)
+	txManager := &TxManager{
+		client:  client,
+		nonces:  nonces,
+		key:     key,
+		account: crypto.PubkeyToAddress(key.PublicKey),
+		cfg:     cfg,
+		tracked: make(map[uint64]*trackedTx),
+	}
+	txManager.BaseService = *NewBaseService(logger, L1TxManagerService, txManager)
+	return txManager
+}
+
+// OnStart resumes tracking of pending submissions and starts the tracking loop
+func (m *TxManager) OnStart() error {
+	m.BaseService.OnStart() // Always call the overridden method.
+
+	if err := m.loadPendingSubmissions(); err != nil {
+		return err
+	}
+
+	ctx, cancelTracking := context.WithCancel(context.Background())
+	m.cancelTracking = cancelTracking
+	go m.startTracking(ctx, m.cfg.PollingInterval)
+	return nil
+}
+
+// OnStop stops all necessary go routines
+func (m *TxManager) OnStop() {
+	m.BaseService.OnStop() // Always call the overridden method.
+	m.cancelTracking()
+}
+
+// Send signs the call with the operator key and broadcasts it, the tx is then tracked
+// until it is confirmed and the outcome is recorded via the given recorder
+func (m *TxManager) Send(recorder SubmissionRecorder, callMsg ethereum.CallMsg) (*ethTypes.Transaction, error) {
+	gasPrice, err := SuggestGasPrice(m.client, m.maxGasPrice())
+	if err != nil {
+		return nil, err
+	}
+
+	callMsg.From = m.account
+	gasLimit, err := m.client.EstimateGas(context.Background(), callMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx *ethTypes.Transaction
+	err = m.nonces.Execute(recorder.String(), func(nonce uint64) (err error) {
+		tx, err = m.signAndSend(ethTypes.NewTransaction(nonce, *callMsg.To, callMsg.Value, gasLimit, gasPrice, callMsg.Data))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.Logger.Info("Sent tx", "for", recorder.String(), "hash", tx.Hash().String(), "nonce", tx.Nonce(), "gasPrice", gasPrice)
+
+	m.mu.Lock()
+	m.tracked[tx.Nonce()] = &trackedTx{recorder: recorder, tx: tx, hashes: []ethCmn.Hash{tx.Hash()}, sentAt: time.Now(), attempts: 1}
+	m.mu.Unlock()
+
+	if err := recorder.RecordBroadcast(tx.Hash().String(), tx.Nonce(), gasPrice.String(), 1); err != nil {
+		m.Logger.Error("Unable to record submission", "for", recorder.String(), "error", err)
+	}
+	return tx, nil
+}
+
+func (m *TxManager) signAndSend(tx *ethTypes.Transaction) (*ethTypes.Transaction, error) {
+	signedTx, err := ethTypes.SignTx(tx, ethTypes.HomesteadSigner{}, m.key)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.client.SendTransaction(context.Background(), signedTx); err != nil {
+		return nil, err
+	}
+	return signedTx, nil
+}
+
+func (m *TxManager) startTracking(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	// stop ticker when everything done
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.checkTrackedTxs()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// loadPendingSubmissions resumes tracking the submissions that were pending when the node stopped
+func (m *TxManager) loadPendingSubmissions() error {
+	batches, err := DBInstance.GetBatchesBySubmissionStatus(SUBMISSION_PENDING)
+	if err != nil {
+		return err
+	}
+	disputes, err := DBInstance.GetDisputesBySubmissionStatus(SUBMISSION_PENDING)
+	if err != nil {
+		return err
+	}
+	withdrawals, err := DBInstance.GetStakeWithdrawalsBySubmissionStatus(SUBMISSION_PENDING)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, batch := range batches {
+		m.resume(BatchSubmission{BatchID: batch.BatchID}, batch.SubmissionHash, batch.SubmissionAttempts)
+	}
+	for _, dispute := range disputes {
+		m.resume(DisputeSubmission{BatchID: dispute.BatchID}, dispute.SubmissionHash, dispute.SubmissionAttempts)
+	}
+	for _, withdrawal := range withdrawals {
+		m.resume(StakeWithdrawalSubmission{BatchID: withdrawal.BatchID}, withdrawal.SubmissionHash, withdrawal.SubmissionAttempts)
+	}
+	return nil
+}
+
+func (m *TxManager) resume(recorder SubmissionRecorder, txHash string, attempts uint64) {
+	tx, _, err := m.client.TransactionByHash(context.Background(), ethCmn.HexToHash(txHash))
+	if err != nil {
+		m.Logger.Error("Unable to fetch pending submission", "for", recorder.String(), "hash", txHash, "error", err)
+		return
+	}
+	m.tracked[tx.Nonce()] = &trackedTx{
+		recorder: recorder,
+		tx:       tx,
+		hashes:   []ethCmn.Hash{tx.Hash()},
+		sentAt:   time.Now(),
+		attempts: attempts,
+	}
+}
+
+// checkTrackedTxs settles the tracked txs and records their outcomes
+// outcomes are recorded once the tracked txs are released, recording a failed batch takes the state
+// lock which the aggregator holds while it sends
+func (m *TxManager) checkTrackedTxs() {
+	for _, outcome := range m.settleTrackedTxs() {
+		if err := outcome.recorder.RecordOutcome(outcome.hash.String(), outcome.status, outcome.includedIn); err != nil {
+			m.Logger.Error("Unable to record submission outcome", "for", outcome.recorder.String(), "error", err)
+		}
+	}
+}
+
+// settleTrackedTxs stops tracking the txs which got enough confirmations, or whose nonce was used up
+// by another tx, and replaces the stuck ones
+func (m *TxManager) settleTrackedTxs() (outcomes []submissionOutcome) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.tracked) == 0 {
+		return nil
+	}
+
+	head, err := m.client.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		m.Logger.Error("Unable to fetch latest header", "error", err)
+		return nil
+	}
+	confirmedNonce, err := m.client.NonceAt(context.Background(), m.account, nil)
+	if err != nil {
+		m.Logger.Error("Unable to fetch operator nonce", "error", err)
+		return nil
+	}
+
+	for nonce, t := range m.tracked {
+		receipt, err := m.findReceipt(t)
+		if err != nil {
+			// any version may have been mined, it is checked again on the next tick
+			m.Logger.Error("Unable to fetch receipt", "for", t.recorder.String(), "error", err)
+			continue
+		}
+		if receipt != nil {
+			includedIn := receipt.BlockNumber.Uint64()
+			if !m.confirmed(head, includedIn) {
+				continue
+			}
+			status := uint64(SUBMISSION_CONFIRMED)
+			if receipt.Status == ethTypes.ReceiptStatusFailed {
+				status = SUBMISSION_FAILED
+			}
+			m.Logger.Info("Tx confirmed", "for", t.recorder.String(), "hash", receipt.TxHash.String(), "block", includedIn, "success", status == SUBMISSION_CONFIRMED)
+			outcomes = append(outcomes, m.finish(nonce, t, receipt.TxHash, status, includedIn))
+			continue
+		}
+
+		// the nonce was used up by a tx we aren't tracking, the receipt of ours may only be late
+		// so it is given up once the nonce has been used for as long as a confirmation takes
+		if confirmedNonce > nonce {
+			if t.nonceTakenAt == 0 {
+				t.nonceTakenAt = head.Number.Uint64()
+			}
+			if !m.confirmed(head, t.nonceTakenAt) {
+				continue
+			}
+			m.Logger.Error("Tx nonce used by another tx", "for", t.recorder.String(), "nonce", nonce)
+			outcomes = append(outcomes, m.finish(nonce, t, t.tx.Hash(), SUBMISSION_FAILED, 0))
+			m.nonces.Resync()
+			continue
+		}
+		t.nonceTakenAt = 0
+
+		if time.Since(t.sentAt) >= m.cfg.StuckTxTimeout {
+			m.replace(t)
+		}
+	}
+	return outcomes
+}
+
+// confirmed returns true if the block has the configured number of confirmations as of head
+func (m *TxManager) confirmed(head *ethTypes.Header, block uint64) bool {
+	return head.Number.Uint64() >= block && head.Number.Uint64()-block+1 >= m.cfg.ConfirmationBlocks
+}
+
+// findReceipt returns the receipt of whichever version of the tx got mined, nil if none did
+// an error is returned if no receipt was found but some couldn't be fetched
+func (m *TxManager) findReceipt(t *trackedTx) (*ethTypes.Receipt, error) {
+	var fetchErr error
+	for _, hash := range t.hashes {
+		receipt, err := m.client.TransactionReceipt(context.Background(), hash)
+		if err == nil {
+			return receipt, nil
+		}
+		if err != ethereum.NotFound {
+			fetchErr = fmt.Errorf("unable to fetch receipt of %v: %v", hash.String(), err)
+		}
+	}
+	return nil, fetchErr
+}
+
+// finish stops tracking the tx, the outcome is recorded by the caller
+func (m *TxManager) finish(nonce uint64, t *trackedTx, hash ethCmn.Hash, status uint64, includedIn uint64) submissionOutcome {
+	delete(m.tracked, nonce)
+	return submissionOutcome{recorder: t.recorder, hash: hash, status: status, includedIn: includedIn}
+}
+
+// replace rebroadcasts a stuck tx with the same nonce and a bumped gas price
+func (m *TxManager) replace(t *trackedTx) {
+	gasPrice, ok := NextGasPrice(t.tx.GasPrice(), m.cfg.GasPriceBumpPercent, m.maxGasPrice())
+	if !ok {
+		m.Logger.Error("Tx stuck at maximum gas price", "for", t.recorder.String(), "hash", t.tx.Hash().String(), "gasPrice", t.tx.GasPrice())
+		t.sentAt = time.Now()
+		return
+	}
+
+	tx, err := m.signAndSend(ethTypes.NewTransaction(t.tx.Nonce(), *t.tx.To(), t.tx.Value(), t.tx.Gas(), gasPrice, t.tx.Data()))
+	if err != nil {
+		m.Logger.Error("Unable to replace stuck tx", "for", t.recorder.String(), "hash", t.tx.Hash().String(), "error", err)
+		return
+	}
+
+	t.tx = tx
+	t.hashes = append(t.hashes, tx.Hash())
+	t.sentAt = time.Now()
+	t.attempts++
+	m.Logger.Info("Replaced stuck tx", "for", t.recorder.String(), "hash", tx.Hash().String(), "gasPrice", gasPrice, "attempt", t.attempts)
+
+	if err := t.recorder.RecordBroadcast(tx.Hash().String(), tx.Nonce(), gasPrice.String(), t.attempts); err != nil {
+		m.Logger.Error("Unable to record submission", "for", t.recorder.String(), "error", err)
+	}
+}
+
+// maxGasPrice returns the configured gas price ceiling
+func (m *TxManager) maxGasPrice() *big.Int {
+	return new(big.Int).SetUint64(m.cfg.MaxGasPrice)
+}
+
+// SuggestGasPrice returns the gas price suggested by the node, capped at the given ceiling
+func SuggestGasPrice(client ethereum.GasPricer, maxGasPrice *big.Int) (*big.Int, error) {
+	gasPrice, err := client.SuggestGasPrice(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if gasPrice.Cmp(maxGasPrice) > 0 {
+		return maxGasPrice, nil
+	}
+	return gasPrice, nil
+}
+
+// NextGasPrice bumps the gas price by the given percentage, capped at the ceiling
+// returns false if the gas price can't be increased any further
+func NextGasPrice(current *big.Int, bumpPercent uint64, maxGasPrice *big.Int) (*big.Int, bool) {
+	next := new(big.Int).Mul(current, new(big.Int).SetUint64(100+bumpPercent))
+	next.Div(next, big.NewInt(100))
+	if next.Cmp(current) <= 0 {
+		next.Add(current, big.NewInt(1))
+	}
+	if next.Cmp(maxGasPrice) > 0 {
+		next.Set(maxGasPrice)
+	}
+	if next.Cmp(current) <= 0 {
+		return current, false
+	}
+	return next, true
+}
+
+// sendL1Tx sends the call through the global tx manager
+func sendL1Tx(recorder SubmissionRecorder, callMsg ethereum.CallMsg) (*ethTypes.Transaction, error) {
+	if L1TxManager == nil {
+		return nil, errors.New("L1 tx manager not initialised")
+	}
+	return L1TxManager.Send(recorder, callMsg)
+}
@@ -0,0 +1,224 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BOPR/config"
+	"github.com/ethereum/go-ethereum"
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextGasPrice(t *testing.T) {
+	maxGasPrice := big.NewInt(1000)
+
+	next, ok := NextGasPrice(big.NewInt(100), 20, maxGasPrice)
+	require.True(t, ok)
+	require.Equal(t, big.NewInt(120), next)
+
+	// always bumps by at least 1 wei
+	next, ok = NextGasPrice(big.NewInt(1), 20, maxGasPrice)
+	require.True(t, ok)
+	require.Equal(t, big.NewInt(2), next)
+
+	// capped at the ceiling
+	next, ok = NextGasPrice(big.NewInt(900), 20, maxGasPrice)
+	require.True(t, ok)
+	require.Equal(t, maxGasPrice, next)
+
+	// can't go any higher
+	next, ok = NextGasPrice(big.NewInt(1000), 20, maxGasPrice)
+	require.False(t, ok)
+	require.Equal(t, big.NewInt(1000), next)
+}
+
+type fakeL1Client struct {
+	mu       sync.Mutex
+	head     uint64
+	nonce    uint64
+	receipts map[ethCmn.Hash]*ethTypes.Receipt
+
+	// returned for receipts instead of not found
+	receiptErr error
+
+	sent []*ethTypes.Transaction
+}
+
+func newFakeL1Client() *fakeL1Client {
+	return &fakeL1Client{head: 100, receipts: make(map[ethCmn.Hash]*ethTypes.Receipt)}
+}
+
+func (c *fakeL1Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(100), nil
+}
+
+func (c *fakeL1Client) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 21000, nil
+}
+
+func (c *fakeL1Client) SendTransaction(ctx context.Context, tx *ethTypes.Transaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, tx)
+	return nil
+}
+
+func (c *fakeL1Client) TransactionByHash(ctx context.Context, hash ethCmn.Hash) (*ethTypes.Transaction, bool, error) {
+	return nil, false, ethereum.NotFound
+}
+
+func (c *fakeL1Client) TransactionReceipt(ctx context.Context, hash ethCmn.Hash) (*ethTypes.Receipt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if receipt, ok := c.receipts[hash]; ok {
+		return receipt, nil
+	}
+	if c.receiptErr != nil {
+		return nil, c.receiptErr
+	}
+	return nil, ethereum.NotFound
+}
+
+func (c *fakeL1Client) HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &ethTypes.Header{Number: new(big.Int).SetUint64(c.head)}, nil
+}
+
+func (c *fakeL1Client) NonceAt(ctx context.Context, account ethCmn.Address, blockNumber *big.Int) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nonce, nil
+}
+
+// mine includes the tx in the current head
+func (c *fakeL1Client) mine(tx *ethTypes.Transaction, status uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.receipts[tx.Hash()] = &ethTypes.Receipt{TxHash: tx.Hash(), Status: status, BlockNumber: new(big.Int).SetUint64(c.head)}
+	c.nonce = tx.Nonce() + 1
+}
+
+type fakeRecorder struct {
+	broadcasts []uint64
+	outcomes   []uint64
+	hashes     []string
+}
+
+func (r *fakeRecorder) RecordBroadcast(txHash string, nonce uint64, gasPrice string, attempts uint64) error {
+	r.broadcasts = append(r.broadcasts, attempts)
+	return nil
+}
+
+func (r *fakeRecorder) RecordOutcome(txHash string, status uint64, includedInBlock uint64) error {
+	r.outcomes = append(r.outcomes, status)
+	r.hashes = append(r.hashes, txHash)
+	return nil
+}
+
+func (r *fakeRecorder) String() string {
+	return "fake submission"
+}
+
+func newTestTxManager(t *testing.T) (*TxManager, *fakeL1Client, *NonceManager) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	client := newFakeL1Client()
+	nonces := NewNonceManager(&fakeNonceSource{}, crypto.PubkeyToAddress(key.PublicKey))
+	cfg := config.GetDefaultConfig()
+	cfg.ConfirmationBlocks = 3
+	return newTxManager(client, nonces, key, cfg), client, nonces
+}
+
+func sendTestTx(t *testing.T, m *TxManager, recorder SubmissionRecorder) *ethTypes.Transaction {
+	to := ethCmn.HexToAddress("0x1")
+	tx, err := m.Send(recorder, ethereum.CallMsg{To: &to})
+	require.NoError(t, err)
+	return tx
+}
+
+func TestTxManagerConfirm(t *testing.T) {
+	m, client, _ := newTestTxManager(t)
+	recorder := &fakeRecorder{}
+	tx := sendTestTx(t, m, recorder)
+	require.Equal(t, []uint64{1}, recorder.broadcasts)
+
+	client.mine(tx, ethTypes.ReceiptStatusSuccessful)
+	client.head += 1
+	m.checkTrackedTxs()
+	require.Empty(t, recorder.outcomes)
+
+	// confirmed once included for as many blocks as configured
+	client.head += 1
+	m.checkTrackedTxs()
+	require.Equal(t, []uint64{SUBMISSION_CONFIRMED}, recorder.outcomes)
+	require.Equal(t, []string{tx.Hash().String()}, recorder.hashes)
+	require.Empty(t, m.tracked)
+}
+
+func TestTxManagerReplacesStuckTx(t *testing.T) {
+	m, client, _ := newTestTxManager(t)
+	recorder := &fakeRecorder{}
+	tx := sendTestTx(t, m, recorder)
+
+	m.checkTrackedTxs()
+	require.Len(t, client.sent, 1)
+
+	m.tracked[tx.Nonce()].sentAt = time.Now().Add(-m.cfg.StuckTxTimeout)
+	m.checkTrackedTxs()
+	require.Len(t, client.sent, 2)
+	replacement := client.sent[1]
+	require.Equal(t, tx.Nonce(), replacement.Nonce())
+	require.Equal(t, 1, replacement.GasPrice().Cmp(tx.GasPrice()))
+	require.Equal(t, []uint64{1, 2}, recorder.broadcasts)
+
+	// the first version can still be the one mined
+	client.mine(tx, ethTypes.ReceiptStatusSuccessful)
+	client.head += 2
+	m.checkTrackedTxs()
+	require.Equal(t, []uint64{SUBMISSION_CONFIRMED}, recorder.outcomes)
+	require.Equal(t, []string{tx.Hash().String()}, recorder.hashes)
+}
+
+func TestTxManagerNonceTaken(t *testing.T) {
+	m, client, nonces := newTestTxManager(t)
+	recorder := &fakeRecorder{}
+	tx := sendTestTx(t, m, recorder)
+	require.True(t, nonces.Queue().Synced)
+
+	// the nonce is used by another tx, ours may only be late while receipts can't be fetched
+	client.nonce = tx.Nonce() + 1
+	client.receiptErr = errors.New("connection refused")
+	client.head += 5
+	m.checkTrackedTxs()
+	require.Empty(t, recorder.outcomes)
+
+	// given up once the nonce has been used for as long as a confirmation takes
+	client.receiptErr = nil
+	m.checkTrackedTxs()
+	require.Empty(t, recorder.outcomes)
+	client.head += 2
+	m.checkTrackedTxs()
+	require.Equal(t, []uint64{SUBMISSION_FAILED}, recorder.outcomes)
+	require.Empty(t, m.tracked)
+	require.False(t, nonces.Queue().Synced)
+}
+
+func TestTxManagerFailed(t *testing.T) {
+	m, client, _ := newTestTxManager(t)
+	recorder := &fakeRecorder{}
+	tx := sendTestTx(t, m, recorder)
+
+	client.mine(tx, ethTypes.ReceiptStatusFailed)
+	client.head += 2
+	m.checkTrackedTxs()
+	require.Equal(t, []uint64{SUBMISSION_FAILED}, recorder.outcomes)
+	require.Empty(t, m.tracked)
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxHashDependsOnlyOnSignedMessage(t *testing.T) {
+	message, err := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000002")
+	require.NoError(t, err)
+
+	received := NewPendingTx(2, 3, TX_TRANSFER_TYPE, "0x1ad4", message)
+	received.CreatedAt = time.Now()
+
+	replayed := NewTx(2, 3, TX_TRANSFER_TYPE, message, "1ad4")
+	replayed.Status = TX_STATUS_PROCESSED
+
+	require.Equal(t, received.TxHash, replayed.TxHash, "same signed message should produce the same hash")
+	require.Equal(t, "0x405787fa12a823e0f2b7631cc41b3ba8828b3321ca811111fa75cd3aa3bb5ace", received.TxHash)
+
+	other := NewTx(2, 3, TX_TRANSFER_TYPE, append(message, 1), "1ad4")
+	require.NotEqual(t, received.TxHash, other.TxHash, "different messages should not share a hash")
+}
+
+func TestBatchTxsRoundTrip(t *testing.T) {
+	uint256Ty, err := abi.NewType("uint256", "", nil)
+	require.NoError(t, err)
+	bytesTy, err := abi.NewType("bytes", "", nil)
+	require.NoError(t, err)
+	args := abi.Arguments{{Type: uint256Ty}, {Type: uint256Ty}, {Type: uint256Ty}, {Type: bytesTy}}
+
+	var compressed [][]byte
+	for i, sig := range [][]byte{make([]byte, 65), make([]byte, 32), {}} {
+		tx, err := args.Pack(big.NewInt(int64(i)), big.NewInt(2), big.NewInt(10), sig)
+		require.NoError(t, err)
+		compressed = append(compressed, tx)
+	}
+
+	encoded, err := EncodeBatchTxs(compressed)
+	require.NoError(t, err)
+	decoded, err := DecodeBatchTxs(encoded)
+	require.NoError(t, err)
+	require.Equal(t, compressed, decoded)
+
+	// batches without txs, and the ones stored before txs were encoded
+	decoded, err = DecodeBatchTxs(nil)
+	require.NoError(t, err)
+	require.Empty(t, decoded)
+
+	_, err = DecodeBatchTxs(encoded[:len(encoded)-1])
+	require.Error(t, err)
+}
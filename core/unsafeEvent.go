@@ -0,0 +1,48 @@
+package core
+
+// UnsafeEvent is an event emitted in a block which doesn't have enough confirmations yet
+// Unsafe events are never applied to the local state, they can still be reorged away
+type UnsafeEvent struct {
+	ID          uint64 `json:"-" gorm:"primary_key;AUTO_INCREMENT"`
+	Name        string `json:"name"`
+	BlockNumber uint64 `json:"blockNumber"`
+	BlockHash   string `json:"blockHash"`
+	TxHash      string `json:"txHash"`
+	LogIndex    uint64 `json:"logIndex"`
+
+	// JSON encoded event fields
+	Fields []byte `json:"-" gorm:"size:1000000"`
+}
+
+// ReplaceUnsafeEvents replaces the unsafe events with the ones currently in the unconfirmed blocks
+func (db *DB) ReplaceUnsafeEvents(events []UnsafeEvent) error {
+	mysqlTx := db.Instance.Begin()
+	if err := mysqlTx.Delete(&UnsafeEvent{}).Error; err != nil {
+		mysqlTx.Rollback()
+		return err
+	}
+	for _, event := range events {
+		if err := mysqlTx.Create(&event).Error; err != nil {
+			mysqlTx.Rollback()
+			return err
+		}
+	}
+	return mysqlTx.Commit().Error
+}
+
+// GetUnsafeEvents returns the unsafe events in the order they were emitted
+func (db *DB) GetUnsafeEvents() (events []UnsafeEvent, err error) {
+	if err := db.Instance.Order("block_number asc, log_index asc").Find(&events).Error; err != nil {
+		return events, err
+	}
+	return events, nil
+}
+
+// HasForeignUnsafeBatch returns true if a batch not submitted by us is among the unsafe events
+func (db *DB) HasForeignUnsafeBatch() (bool, error) {
+	var count uint64
+	err := db.Instance.Model(&UnsafeEvent{}).
+		Where("name = ? AND tx_hash NOT IN (?)", "NewBatch", db.Instance.Table("batch_submission_hashes").Select("tx_hash").QueryExpr()).
+		Count(&count).Error
+	return count != 0, err
+}
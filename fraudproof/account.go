@@ -0,0 +1,32 @@
+package fraudproof
+
+import (
+	"github.com/BOPR/core"
+)
+
+// AccountProofAtFromDB returns the proof of the leaf at path against the state root of the batch
+// The state is rewound in memory, the DB transaction only gives a consistent view of the tree and journal
+func AccountProofAtFromDB(db core.DB, batchID uint64, path string) (core.AccountMerkleProof, error) {
+	mysqlTx := db.Instance.Begin()
+	defer mysqlTx.Rollback()
+
+	dbCopy := db
+	dbCopy.Instance = mysqlTx
+	return AccountProofAt(newOverlay(&dbCopy), batchID, path)
+}
+
+// AccountProofAt rewinds the state to the state root of the batch and returns the proof of the leaf at path
+// The leaf is returned as it was then, it may not have been deposited yet
+func AccountProofAt(state State, batchID uint64, path string) (proof core.AccountMerkleProof, err error) {
+	batch, err := state.GetBatchByIndex(batchID)
+	if err != nil {
+		return proof, err
+	}
+	if batch.Status == core.BATCH_DISPUTED || batch.Status == core.BATCH_ROLLED_BACK {
+		return proof, ErrBatchNotApplied
+	}
+	if _, err := rewind(state, batch.StateRoot); err != nil {
+		return proof, err
+	}
+	return accountProof(state, path)
+}
@@ -0,0 +1,246 @@
+package fraudproof
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/BOPR/contracts/rollup"
+	"github.com/BOPR/core"
+)
+
+// journalPageSize is the number of journal entries loaded at a time while rewinding
+const journalPageSize = 500
+
+var (
+	ErrBatchNotApplied     = errors.New("batch wasn't applied locally")
+	ErrPreStateNotFound    = errors.New("journal doesn't go back to the batch pre-state")
+	ErrPostStateNotReached = errors.New("replaying the journal doesn't reach the batch state root")
+)
+
+// State is the balance tree the proofs are built from, along with its journal
+// Building the proofs rewinds the tree, so the state should be thrown away afterwards
+type State interface {
+	GetBatchByIndex(index uint64) (core.Batch, error)
+	GetRoot() (core.UserAccount, error)
+	GetAccountByPath(path string) (core.UserAccount, error)
+	GetSiblings(path string) ([]core.UserAccount, error)
+	GetPDALeafByID(ID uint64) (core.PDA, error)
+	GetPDASiblings(path string) ([]core.PDA, error)
+	LastJournalSeq() (uint64, error)
+	GetUndoLogsBefore(seq uint64, limit uint64) ([]core.UndoLog, error)
+	RestoreLeaf(leaf core.UserAccount) error
+}
+
+// TxProof holds the proofs of a tx of the batch
+// the from proof is against the state before the tx, the to proof against the state
+// after the from account was updated
+type TxProof struct {
+	TxHash string
+	From   core.AccountMerkleProof
+	To     core.AccountMerkleProof
+	PDA    core.PDAMerkleProof
+}
+
+// BatchProofs holds the proofs of all txs of a batch, in order, starting from the batch pre-state
+type BatchProofs struct {
+	BatchID      uint64
+	PreStateRoot core.ByteArray
+	Txs          []TxProof
+}
+
+// ToABIVersion returns the proofs in the form expected by processBatch and disputeBatch
+func (p *BatchProofs) ToABIVersion() (proofs rollup.TypesBatchValidationProofs, err error) {
+	for _, tx := range p.Txs {
+		fromMP, err := tx.From.ToABIVersion()
+		if err != nil {
+			return proofs, err
+		}
+		toMP, err := tx.To.ToABIVersion()
+		if err != nil {
+			return proofs, err
+		}
+		proofs.AccountProofs = append(proofs.AccountProofs, rollup.TypesAccountProofs{From: fromMP, To: toMP})
+		proofs.PdaProof = append(proofs.PdaProof, tx.PDA.ToABIVersion())
+	}
+	return proofs, nil
+}
+
+// undoneUpdate is a journaled update that was undone along with the leaf it had written
+type undoneUpdate struct {
+	entry core.UndoLog
+	leaf  core.UserAccount
+}
+
+// BuildFromDB builds the proofs of the batch within a DB transaction which is rolled back
+// so the state is left untouched
+func BuildFromDB(db core.DB, batchID uint64) (BatchProofs, error) {
+	mysqlTx := db.Instance.Begin()
+	defer mysqlTx.Rollback()
+
+	dbCopy := db
+	dbCopy.Instance = mysqlTx
+	return Build(&dbCopy, batchID)
+}
+
+// Build rewinds the state to the pre-state of the batch, i.e the state root of the previous batch,
+// and replays the updates made by the batch txs collecting the proofs along the way
+func Build(state State, batchID uint64) (proofs BatchProofs, err error) {
+	if batchID == 0 {
+		return proofs, ErrPreStateNotFound
+	}
+	batch, err := state.GetBatchByIndex(batchID)
+	if err != nil {
+		return proofs, err
+	}
+	if batch.Status == core.BATCH_DISPUTED || batch.Status == core.BATCH_ROLLED_BACK {
+		return proofs, ErrBatchNotApplied
+	}
+	prevBatch, err := state.GetBatchByIndex(batchID - 1)
+	if err != nil {
+		return proofs, err
+	}
+
+	undone, err := rewind(state, prevBatch.StateRoot)
+	if err != nil {
+		return proofs, err
+	}
+	proofs.BatchID = batchID
+	proofs.PreStateRoot, err = core.HexToByteArray(prevBatch.StateRoot)
+	if err != nil {
+		return proofs, err
+	}
+
+	// replay oldest first until the batch state root is reached
+	for i := len(undone) - 1; i >= 0; i-- {
+		reached, err := hasRoot(state, batch.StateRoot)
+		if err != nil {
+			return proofs, err
+		}
+		if reached {
+			break
+		}
+
+		// deposits don't need proofs
+		if undone[i].entry.TxHash == "" {
+			if err := state.RestoreLeaf(undone[i].leaf); err != nil {
+				return proofs, err
+			}
+			continue
+		}
+
+		// a tx updates the from account and then the to account
+		if i == 0 || undone[i-1].entry.TxHash != undone[i].entry.TxHash {
+			return proofs, fmt.Errorf("journal is missing the to account update of tx %v", undone[i].entry.TxHash)
+		}
+		txProof, err := replayTx(state, undone[i], undone[i-1])
+		if err != nil {
+			return proofs, err
+		}
+		proofs.Txs = append(proofs.Txs, txProof)
+		i--
+	}
+
+	reached, err := hasRoot(state, batch.StateRoot)
+	if err != nil {
+		return proofs, err
+	}
+	if !reached {
+		return proofs, ErrPostStateNotReached
+	}
+	return proofs, nil
+}
+
+// rewind undoes journaled updates, newest first, until the state root is root
+// returns the undone updates, newest first
+func rewind(state State, root string) (undone []undoneUpdate, err error) {
+	before, err := state.LastJournalSeq()
+	if err != nil {
+		return undone, err
+	}
+	before++
+
+	var page []core.UndoLog
+	for {
+		reached, err := hasRoot(state, root)
+		if err != nil {
+			return undone, err
+		}
+		if reached {
+			return undone, nil
+		}
+
+		if len(page) == 0 {
+			page, err = state.GetUndoLogsBefore(before, journalPageSize)
+			if err != nil {
+				return undone, err
+			}
+			if len(page) == 0 {
+				return undone, ErrPreStateNotFound
+			}
+		}
+		entry := page[0]
+		page = page[1:]
+		before = entry.Seq
+
+		leaf, err := state.GetAccountByPath(entry.Path)
+		if err != nil {
+			return undone, err
+		}
+		if err := state.RestoreLeaf(entry.Leaf()); err != nil {
+			return undone, err
+		}
+		undone = append(undone, undoneUpdate{entry: entry, leaf: leaf})
+	}
+}
+
+// replayTx redoes the updates of a tx and returns the proofs of the accounts before each update
+func replayTx(state State, from, to undoneUpdate) (txProof TxProof, err error) {
+	txProof.TxHash = from.entry.TxHash
+	txProof.From, err = accountProof(state, from.entry.Path)
+	if err != nil {
+		return
+	}
+	if err = state.RestoreLeaf(from.leaf); err != nil {
+		return
+	}
+	txProof.To, err = accountProof(state, to.entry.Path)
+	if err != nil {
+		return
+	}
+	if err = state.RestoreLeaf(to.leaf); err != nil {
+		return
+	}
+
+	// pubkeys are never updated once registered, the proof is against the latest PDA tree
+	// which is the one the contract checks it against
+	fromPDA, err := state.GetPDALeafByID(txProof.From.Account.AccountID)
+	if err != nil {
+		return
+	}
+	fromPDASiblings, err := state.GetPDASiblings(fromPDA.Path)
+	if err != nil {
+		return
+	}
+	txProof.PDA = core.NewPDAProof(fromPDA.Path, fromPDA.PublicKey, fromPDASiblings)
+	return txProof, nil
+}
+
+func accountProof(state State, path string) (proof core.AccountMerkleProof, err error) {
+	account, err := state.GetAccountByPath(path)
+	if err != nil {
+		return
+	}
+	siblings, err := state.GetSiblings(path)
+	if err != nil {
+		return
+	}
+	return core.NewAccountMerkleProof(account, siblings), nil
+}
+
+func hasRoot(state State, root string) (bool, error) {
+	rootNode, err := state.GetRoot()
+	if err != nil {
+		return false, err
+	}
+	return rootNode.Hash == root, nil
+}
@@ -0,0 +1,239 @@
+package fraudproof
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/BOPR/core"
+	"github.com/stretchr/testify/require"
+)
+
+// memState is an in memory balance tree of depth 2 which journals updates like the DB does
+type memState struct {
+	nodes   map[string]core.UserAccount
+	journal []core.UndoLog
+	batches map[uint64]core.Batch
+}
+
+func newMemState(t *testing.T) *memState {
+	s := &memState{nodes: make(map[string]core.UserAccount), batches: make(map[uint64]core.Batch)}
+	for i, path := range []string{"00", "01", "10", "11"} {
+		leaf := core.UserAccount{AccountID: uint64(i), Path: path, Level: 2, Type: core.TYPE_TERMINAL, Status: core.STATUS_ACTIVE, Data: []byte{byte(i)}}
+		leaf.CreateAccountHash()
+		s.nodes[path] = leaf
+	}
+	for _, path := range []string{"0", "1", ""} {
+		s.nodes[path] = core.UserAccount{Path: path, Level: uint64(len(path)), Hash: core.ByteArray{}.String()}
+	}
+	require.NoError(t, s.RestoreLeaf(s.nodes["00"]))
+	require.NoError(t, s.RestoreLeaf(s.nodes["10"]))
+	return s
+}
+
+// update journals the leaf at path and writes data to it, returning the new root
+func (s *memState) update(t *testing.T, txHash, path string, data []byte) string {
+	prev := s.nodes[path]
+	s.journal = append(s.journal, core.UndoLog{
+		Seq:       uint64(len(s.journal) + 1),
+		TxHash:    txHash,
+		Path:      prev.Path,
+		AccountID: prev.AccountID,
+		Data:      prev.Data,
+		Status:    prev.Status,
+		Type:      prev.Type,
+		Hash:      prev.Hash,
+		Level:     prev.Level,
+	})
+	leaf := prev
+	leaf.Data = data
+	leaf.CreateAccountHash()
+	require.NoError(t, s.RestoreLeaf(leaf))
+	return s.nodes[""].Hash
+}
+
+func (s *memState) addBatch(id, status uint64) {
+	s.batches[id] = core.Batch{BatchID: id, StateRoot: s.nodes[""].Hash, Status: status}
+}
+
+func (s *memState) GetBatchByIndex(index uint64) (core.Batch, error) {
+	batch, ok := s.batches[index]
+	if !ok {
+		return batch, fmt.Errorf("no batch %v", index)
+	}
+	return batch, nil
+}
+
+func (s *memState) GetRoot() (core.UserAccount, error) {
+	return s.nodes[""], nil
+}
+
+func (s *memState) GetAccountByPath(path string) (core.UserAccount, error) {
+	return s.nodes[path], nil
+}
+
+func (s *memState) GetSiblings(path string) (siblings []core.UserAccount, err error) {
+	for ; path != ""; path = core.GetParentPath(path) {
+		siblings = append(siblings, s.nodes[core.GetOtherChild(path)])
+	}
+	return siblings, nil
+}
+
+func (s *memState) GetPDALeafByID(ID uint64) (core.PDA, error) {
+	return core.PDA{AccountID: ID, Path: fmt.Sprintf("%02b", ID)}, nil
+}
+
+func (s *memState) GetPDASiblings(path string) ([]core.PDA, error) {
+	return nil, nil
+}
+
+func (s *memState) LastJournalSeq() (uint64, error) {
+	return uint64(len(s.journal)), nil
+}
+
+func (s *memState) GetUndoLogsBefore(seq uint64, limit uint64) (entries []core.UndoLog, err error) {
+	for _, entry := range s.journal {
+		if entry.Seq < seq {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq > entries[j].Seq })
+	if uint64(len(entries)) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (s *memState) RestoreLeaf(leaf core.UserAccount) error {
+	s.nodes[leaf.Path] = leaf
+	for path := leaf.Path; path != ""; path = core.GetParentPath(path) {
+		parentPath := core.GetParentPath(path)
+		left, right := s.nodes[parentPath+"0"], s.nodes[parentPath+"1"]
+		parentHash, err := core.GetParent(left.HashToByteArray(), right.HashToByteArray())
+		if err != nil {
+			return err
+		}
+		parent := s.nodes[parentPath]
+		parent.Hash = parentHash.String()
+		s.nodes[parentPath] = parent
+	}
+	return nil
+}
+
+// proofRoot computes the root the account proof is against
+func proofRoot(t *testing.T, proof core.AccountMerkleProof) string {
+	node := proof.Account.HashToByteArray()
+	for i, sibling := range proof.Siblings {
+		var err error
+		if core.GetNthBitFromRight(proof.Account.Path, i) == 0 {
+			node, err = core.GetParent(node, sibling.HashToByteArray())
+		} else {
+			node, err = core.GetParent(sibling.HashToByteArray(), node)
+		}
+		require.NoError(t, err)
+	}
+	return node.String()
+}
+
+// generateBatches builds a deposit batch and two tx batches on top of genesis
+// returns the roots each tx proof should be against, per tx batch
+func generateBatches(t *testing.T, s *memState) map[uint64][]string {
+	roots := make(map[uint64][]string)
+	s.addBatch(0, core.BATCH_COMMITTED)
+
+	s.update(t, "", "11", []byte("deposit"))
+	s.addBatch(1, core.BATCH_COMMITTED)
+
+	preTx1 := s.nodes[""].Hash
+	afterFrom1 := s.update(t, "tx1", "00", []byte("a1"))
+	s.update(t, "tx1", "01", []byte("b1"))
+	preTx2 := s.nodes[""].Hash
+	afterFrom2 := s.update(t, "tx2", "01", []byte("b2"))
+	s.update(t, "tx2", "10", []byte("c2"))
+	s.addBatch(2, core.BATCH_COMMITTED)
+	roots[2] = []string{preTx1, afterFrom1, preTx2, afterFrom2}
+
+	preTx3 := s.nodes[""].Hash
+	afterFrom3 := s.update(t, "tx3", "10", []byte("c3"))
+	s.update(t, "tx3", "11", []byte("d3"))
+	s.addBatch(3, core.BATCH_COMMITTED)
+	roots[3] = []string{preTx3, afterFrom3}
+	return roots
+}
+
+func TestBuildProofsAtBatchPreState(t *testing.T) {
+	s := newMemState(t)
+	roots := generateBatches(t, s)
+
+	proofs, err := Build(s, 2)
+	require.NoError(t, err)
+	require.Equal(t, s.batches[1].StateRoot, proofs.PreStateRoot.String())
+	require.Len(t, proofs.Txs, 2)
+	require.Equal(t, "tx1", proofs.Txs[0].TxHash)
+	require.Equal(t, "tx2", proofs.Txs[1].TxHash)
+
+	// from proofs are before the tx, to proofs after the from update
+	require.Equal(t, roots[2][0], proofRoot(t, proofs.Txs[0].From))
+	require.Equal(t, roots[2][1], proofRoot(t, proofs.Txs[0].To))
+	require.Equal(t, roots[2][2], proofRoot(t, proofs.Txs[1].From))
+	require.Equal(t, roots[2][3], proofRoot(t, proofs.Txs[1].To))
+	require.Equal(t, []byte("b1"), proofs.Txs[1].From.Account.Data)
+	require.Equal(t, "01", proofs.Txs[1].PDA.Path)
+}
+
+func TestBuildProofsOfLatestBatch(t *testing.T) {
+	s := newMemState(t)
+	roots := generateBatches(t, s)
+
+	proofs, err := Build(s, 3)
+	require.NoError(t, err)
+	require.Len(t, proofs.Txs, 1)
+	require.Equal(t, roots[3][0], proofRoot(t, proofs.Txs[0].From))
+	require.Equal(t, roots[3][1], proofRoot(t, proofs.Txs[0].To))
+}
+
+func TestBuildProofsOfDepositBatch(t *testing.T) {
+	s := newMemState(t)
+	generateBatches(t, s)
+
+	proofs, err := Build(s, 1)
+	require.NoError(t, err)
+	require.Empty(t, proofs.Txs)
+	require.Equal(t, s.batches[0].StateRoot, proofs.PreStateRoot.String())
+}
+
+func TestBuildProofsErrors(t *testing.T) {
+	s := newMemState(t)
+	generateBatches(t, s)
+	s.batches[3] = core.Batch{BatchID: 3, StateRoot: s.batches[3].StateRoot, Status: core.BATCH_DISPUTED}
+	_, err := Build(s, 3)
+	require.Equal(t, ErrBatchNotApplied, err)
+
+	// journal only goes back to batch 2
+	s = newMemState(t)
+	generateBatches(t, s)
+	s.journal = s.journal[3:]
+	_, err = Build(s, 2)
+	require.Equal(t, ErrPreStateNotFound, err)
+}
+
+func TestAccountProofAtBatch(t *testing.T) {
+	s := newMemState(t)
+	generateBatches(t, s)
+
+	proof, err := AccountProofAt(s, 1, "01")
+	require.NoError(t, err)
+	require.Equal(t, s.batches[1].StateRoot, proofRoot(t, proof))
+	require.Equal(t, "01", proof.Account.Path)
+
+	// the latest batch needs no rewinding
+	s = newMemState(t)
+	generateBatches(t, s)
+	proof, err = AccountProofAt(s, 3, "00")
+	require.NoError(t, err)
+	require.Equal(t, s.batches[3].StateRoot, proofRoot(t, proof))
+
+	s.batches[2] = core.Batch{BatchID: 2, StateRoot: s.batches[2].StateRoot, Status: core.BATCH_ROLLED_BACK}
+	_, err = AccountProofAt(s, 2, "00")
+	require.Equal(t, ErrBatchNotApplied, err)
+}
@@ -0,0 +1,73 @@
+package fraudproof
+
+import (
+	"github.com/BOPR/core"
+)
+
+// overlay is a read only view of the balance tree, restored leaves and the nodes above them
+// are kept in memory so the tree can be rewound without writing to it
+type overlay struct {
+	State
+	nodes map[string]core.UserAccount
+}
+
+func newOverlay(state State) *overlay {
+	return &overlay{State: state, nodes: make(map[string]core.UserAccount)}
+}
+
+func (o *overlay) GetRoot() (core.UserAccount, error) {
+	if root, ok := o.nodes[""]; ok {
+		return root, nil
+	}
+	return o.State.GetRoot()
+}
+
+func (o *overlay) GetAccountByPath(path string) (core.UserAccount, error) {
+	if node, ok := o.nodes[path]; ok {
+		return node, nil
+	}
+	return o.State.GetAccountByPath(path)
+}
+
+func (o *overlay) GetSiblings(path string) (siblings []core.UserAccount, err error) {
+	for ; path != ""; path = core.GetParentPath(path) {
+		sibling, err := o.GetAccountByPath(core.GetOtherChild(path))
+		if err != nil {
+			return siblings, err
+		}
+		siblings = append(siblings, sibling)
+	}
+	return siblings, nil
+}
+
+// RestoreLeaf overwrites the leaf in memory and recomputes the nodes up to the root
+func (o *overlay) RestoreLeaf(leaf core.UserAccount) error {
+	o.nodes[leaf.Path] = leaf
+	for path := leaf.Path; path != ""; path = core.GetParentPath(path) {
+		parentPath := core.GetParentPath(path)
+		left, err := o.GetAccountByPath(parentPath + "0")
+		if err != nil {
+			return err
+		}
+		right, err := o.GetAccountByPath(parentPath + "1")
+		if err != nil {
+			return err
+		}
+		parentHash, err := core.GetParent(left.HashToByteArray(), right.HashToByteArray())
+		if err != nil {
+			return err
+		}
+		var parent core.UserAccount
+		if parentPath == "" {
+			parent, err = o.GetRoot()
+		} else {
+			parent, err = o.GetAccountByPath(parentPath)
+		}
+		if err != nil {
+			return err
+		}
+		parent.Hash = parentHash.String()
+		o.nodes[parentPath] = parent
+	}
+	return nil
+}
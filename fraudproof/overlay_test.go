@@ -0,0 +1,32 @@
+package fraudproof
+
+import (
+	"testing"
+
+	"github.com/BOPR/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountProofAtLeavesStateUntouched(t *testing.T) {
+	s := newMemState(t)
+	generateBatches(t, s)
+	before := make(map[string]core.UserAccount)
+	for path, node := range s.nodes {
+		before[path] = node
+	}
+
+	for _, batchID := range []uint64{0, 1, 2, 3} {
+		proof, err := AccountProofAt(newOverlay(s), batchID, "01")
+		require.NoError(t, err)
+		require.Equal(t, s.batches[batchID].StateRoot, proofRoot(t, proof))
+	}
+	require.Equal(t, before, s.nodes)
+	require.Len(t, s.journal, 7)
+
+	// the overlay rewinds like the state itself does
+	fromOverlay, err := AccountProofAt(newOverlay(s), 1, "10")
+	require.NoError(t, err)
+	rewound, err := AccountProofAt(s, 1, "10")
+	require.NoError(t, err)
+	require.Equal(t, rewound, fromOverlay)
+}
@@ -0,0 +1,132 @@
+package jsonrpc
+
+import (
+	"net/http"
+
+	"github.com/BOPR/core"
+	"github.com/BOPR/rest"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Namespace prefixes the methods served, e.g hubble_getAccount
+const Namespace = "hubble"
+
+// Error codes, the ones not defined by JSON-RPC 2.0 follow the ones used by Ethereum clients
+const (
+	CodeInvalidParams       = -32602
+	CodeInternalError       = -32603
+	CodeResourceNotFound    = -32001
+	CodeTransactionRejected = -32003
+)
+
+// Error is a failed call along with its JSON-RPC error code
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorCode returns the code the error is served with
+func (e *Error) ErrorCode() int {
+	return e.Code
+}
+
+// toRPCError maps the HTTP status of a failed REST request to a JSON-RPC error code
+func toRPCError(err error) error {
+	restErr, ok := err.(*rest.Error)
+	if !ok {
+		return &Error{Code: CodeInternalError, Message: err.Error()}
+	}
+	switch restErr.Status {
+	case http.StatusBadRequest:
+		return &Error{Code: CodeInvalidParams, Message: restErr.Message}
+	case http.StatusNotFound:
+		return &Error{Code: CodeResourceNotFound, Message: restErr.Message}
+	case http.StatusConflict:
+		return &Error{Code: CodeTransactionRejected, Message: restErr.Message}
+	default:
+		return &Error{Code: CodeInternalError, Message: restErr.Message}
+	}
+}
+
+// NewServer creates a JSON-RPC 2.0 server serving the hubble methods, batch requests included
+// The server is an http.Handler taking POST requests, it reads the node state through the service
+func NewServer(service *rest.Service) (*rpc.Server, error) {
+	server := rpc.NewServer()
+	if err := server.RegisterName(Namespace, &API{service: service}); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// API holds the hubble methods, they serve the same as the REST handlers
+type API struct {
+	service *rest.Service
+}
+
+// SendTransaction adds the transfer to the pool and returns its hash
+func (api *API) SendTransaction(tx rest.TxReceiver) (string, error) {
+	userTx, err := api.service.SendTx(tx)
+	if err != nil {
+		return "", toRPCError(err)
+	}
+	return userTx.TxHash, nil
+}
+
+// GetTransaction returns the tx with the hash
+func (api *API) GetTransaction(hash string) (*core.Tx, error) {
+	tx, err := rest.GetTx(hash)
+	if err != nil {
+		return nil, toRPCError(err)
+	}
+	return &tx, nil
+}
+
+// GetAccount returns the decoded state of the account, withProof includes the merkle siblings of its leaf
+func (api *API) GetAccount(ID uint64, withProof *bool) (*rest.AccountResponse, error) {
+	account, err := api.service.GetAccount(ID, withProof != nil && *withProof)
+	if err != nil {
+		return nil, toRPCError(err)
+	}
+	return &account, nil
+}
+
+// GetBatch returns the batch along with the txs it includes
+func (api *API) GetBatch(ID uint64) (*rest.BatchResponse, error) {
+	batch, err := api.service.GetBatch(ID)
+	if err != nil {
+		return nil, toRPCError(err)
+	}
+	return &batch, nil
+}
+
+// GetProof returns the merkle proof of the account against the current balance root,
+// or against the state root of the batch if one is given
+func (api *API) GetProof(ID uint64, batchID *uint64) (*rest.AccountProofResponse, error) {
+	proof, err := api.service.GetAccountProof(ID, batchID)
+	if err != nil {
+		return nil, toRPCError(err)
+	}
+	return &proof, nil
+}
+
+// GetPubkeyProof returns the merkle proof of the pubkey registered for the account against the current PDA root
+func (api *API) GetPubkeyProof(ID uint64) (*rest.PubkeyProofResponse, error) {
+	proof, err := api.service.GetPubkeyProof(ID)
+	if err != nil {
+		return nil, toRPCError(err)
+	}
+	return &proof, nil
+}
+
+// SyncStatus returns the sync state of the node
+func (api *API) SyncStatus() (*rest.SyncStatusResponse, error) {
+	status, err := rest.GetSyncStatus()
+	if err != nil {
+		return nil, toRPCError(err)
+	}
+	return &status, nil
+}
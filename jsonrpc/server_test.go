@@ -0,0 +1,63 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BOPR/rest"
+	"github.com/stretchr/testify/require"
+)
+
+type response struct {
+	ID    int `json:"id"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func TestBatchRequest(t *testing.T) {
+	server, err := NewServer(rest.NewService(nil, nil, nil, nil, nil))
+	require.NoError(t, err)
+	defer server.Stop()
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	// both calls fail before reaching the node state
+	body := `[
+		{"jsonrpc":"2.0","id":1,"method":"hubble_getAccount","params":["not an ID"]},
+		{"jsonrpc":"2.0","id":2,"method":"hubble_getBalance","params":[1]}
+	]`
+	resp, err := http.Post(httpServer.URL, "application/json", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var responses []response
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&responses))
+	require.Len(t, responses, 2)
+	codes := make(map[int]int)
+	for _, r := range responses {
+		require.NotNil(t, r.Error)
+		codes[r.ID] = r.Error.Code
+	}
+	require.Equal(t, CodeInvalidParams, codes[1])
+	require.Equal(t, -32601, codes[2])
+}
+
+func TestRPCErrorCodes(t *testing.T) {
+	for status, code := range map[int]int{
+		http.StatusBadRequest:          CodeInvalidParams,
+		http.StatusNotFound:            CodeResourceNotFound,
+		http.StatusConflict:            CodeTransactionRejected,
+		http.StatusInternalServerError: CodeInternalError,
+	} {
+		err := toRPCError(rest.NewError(status, "failed"))
+		require.Equal(t, code, err.(*Error).ErrorCode())
+		require.Equal(t, "failed", err.Error())
+	}
+	require.Equal(t, CodeInternalError, toRPCError(errors.New("failed")).(*Error).ErrorCode())
+}
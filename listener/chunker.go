@@ -0,0 +1,100 @@
+package listener
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// tooManyResultsErrors are parts of the errors RPC providers return when a log query is too large
+var tooManyResultsErrors = []string{
+	"query returned more than",
+	"too many",
+	"limit exceeded",
+	"response size exceeded",
+	"block range",
+	"timeout",
+	"timed out",
+	"deadline exceeded",
+}
+
+// IsTooManyResults returns true if the log query failed because its range is too large
+func IsTooManyResults(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, part := range tooManyResultsErrors {
+		if strings.Contains(msg, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// ChunkSizer adapts the number of blocks logs are fetched for at once to what the RPC provider can serve
+// It halves on queries which are too large and doubles when responses are small
+type ChunkSizer struct {
+	size uint64
+	min  uint64
+	max  uint64
+}
+
+// NewChunkSizer creates a chunk sizer starting at initial blocks, bounded by min and max
+func NewChunkSizer(initial, min, max uint64) *ChunkSizer {
+	return &ChunkSizer{size: initial, min: min, max: max}
+}
+
+// Size returns the number of blocks to fetch the logs for
+func (c *ChunkSizer) Size() uint64 {
+	return c.size
+}
+
+// Shrink halves the chunk size, returns false if it was already at its minimum
+func (c *ChunkSizer) Shrink() bool {
+	if c.size <= c.min {
+		return false
+	}
+	c.size /= 2
+	if c.size < c.min {
+		c.size = c.min
+	}
+	return true
+}
+
+// Grow doubles the chunk size if a chunk returned few logs
+func (c *ChunkSizer) Grow(logs int) {
+	if logs >= SmallChunkLogs {
+		return
+	}
+	c.size *= 2
+	if c.size > c.max {
+		c.size = c.max
+	}
+}
+
+// fetchChunk fetches the logs of the contract from the block on, for as many blocks as the chunk size allows
+// up to the last block, shrinking the chunk on queries which are too large
+// returns the logs and the last block they were fetched for
+func fetchChunk(ctx context.Context, chain ChainReader, sizer *ChunkSizer, address ethCmn.Address, from, to uint64) ([]ethTypes.Log, uint64, error) {
+	for {
+		end := from + sizer.Size() - 1
+		if end > to {
+			end = to
+		}
+		query := ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: []ethCmn.Address{address},
+		}
+		logs, err := chain.FilterLogs(ctx, query)
+		if err == nil {
+			sizer.Grow(len(logs))
+			return logs, end, nil
+		}
+		if !IsTooManyResults(err) || !sizer.Shrink() {
+			return nil, from, err
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkSizer(t *testing.T) {
+	sizer := NewChunkSizer(8, 2, 32)
+
+	require.True(t, sizer.Shrink())
+	require.Equal(t, uint64(4), sizer.Size())
+	require.True(t, sizer.Shrink())
+	require.False(t, sizer.Shrink(), "already at min")
+	require.Equal(t, uint64(2), sizer.Size())
+
+	// only small responses grow the chunk
+	sizer.Grow(SmallChunkLogs)
+	require.Equal(t, uint64(2), sizer.Size())
+	for i := 0; i < 10; i++ {
+		sizer.Grow(0)
+	}
+	require.Equal(t, uint64(32), sizer.Size())
+}
+
+func TestIsTooManyResults(t *testing.T) {
+	require.True(t, IsTooManyResults(errors.New("query returned more than 10000 results")))
+	require.True(t, IsTooManyResults(errors.New("Log response size exceeded")))
+	require.True(t, IsTooManyResults(context.DeadlineExceeded))
+	require.False(t, IsTooManyResults(errors.New("connection refused")))
+}
+
+func TestFetchChunkShrinksOnTooManyResults(t *testing.T) {
+	// one log per block
+	chain := newFakeChain(100, "a")
+	chain.maxLogs = 10
+	sizer := NewChunkSizer(64, 1, 64)
+
+	var fetched []uint64
+	for from := uint64(1); from <= 99; {
+		logs, end, err := fetchChunk(context.Background(), chain, sizer, ethCmn.Address{}, from, 99)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(logs), chain.maxLogs)
+		for _, vLog := range logs {
+			fetched = append(fetched, vLog.BlockNumber)
+		}
+		from = end + 1
+	}
+
+	// every block exactly once, in order
+	require.Len(t, fetched, 99)
+	for i, number := range fetched {
+		require.Equal(t, uint64(i+1), number)
+	}
+}
+
+func TestFetchChunkFailsAtMinSize(t *testing.T) {
+	chain := newFakeChain(10, "a")
+	chain.maxLogs = 1
+	chain.logs[5] = append(chain.logs[5], chain.logs[5]...)
+	sizer := NewChunkSizer(4, 1, 4)
+
+	_, _, err := fetchChunk(context.Background(), chain, sizer, ethCmn.Address{}, 5, 9)
+	require.Error(t, err)
+	require.Equal(t, uint64(1), sizer.Size())
+}
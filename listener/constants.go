@@ -1,5 +1,38 @@
 package listener
 
+import "time"
+
 const (
 	SyncerServiceName = "syncer"
+
+	// ReorgDepth is the number of synced blocks kept to find the fork point on a reorg
+	ReorgDepth = 128
+
+	// number of blocks logs are fetched for at once, adapted to what the RPC provider can serve
+	DefaultChunkSize = 1000
+	MinChunkSize     = 1
+	MaxChunkSize     = 100000
+
+	// chunks returning fewer logs grow the chunk size
+	SmallChunkLogs = 100
+
+	// number of batch calldata fetched at once
+	PrefetchWorkers = 8
+
+	// events failing this many times are moved to the dead letters, or halt the syncer if they can't be skipped
+	MaxEventAttempts = 5
+
+	// wait between attempts at a failing event, doubling with each attempt
+	BaseRetryBackoff = 5 * time.Second
+	MaxRetryBackoff  = 5 * time.Minute
+
+	// wait between attempts at re-subscribing to new heads, doubling with each attempt
+	MinResubscribeBackoff = 1 * time.Second
+	MaxResubscribeBackoff = 2 * time.Minute
+
+	// the syncer counts as synced while at most this many blocks behind the confirmed head
+	MaxSyncedLag = 10
+
+	// the syncer is degraded when no new head came in for this long
+	StaleHeadTimeout = 2 * time.Minute
 )
@@ -0,0 +1,202 @@
+package listener
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+// HeadClient is the part of the main chain client new heads are read from
+type HeadClient interface {
+	SubscribeNewHead(ctx context.Context, ch chan<- *ethTypes.Header) (ethereum.Subscription, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error)
+}
+
+// HeadSourceHealth is the state of the head source
+type HeadSourceHealth struct {
+	// heads are received through the subscription
+	Subscribed bool `json:"subscribed"`
+
+	// heads are polled for while the subscription is down
+	Polling bool `json:"polling"`
+
+	LastHead   uint64    `json:"lastHead"`
+	LastHeadAt time.Time `json:"lastHeadAt"`
+
+	// times the subscription was re-established after going down
+	Reconnects uint64 `json:"reconnects"`
+	LastError  string `json:"lastError"`
+}
+
+// HeadSource delivers new main chain heads using a subscription, re-subscribing with exponential
+// backoff when it goes down and polling for heads in the meantime
+// Heads seen already are only delivered once
+type HeadSource struct {
+	client       HeadClient
+	heads        chan<- *ethTypes.Header
+	pollInterval time.Duration
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	logger       log.Logger
+
+	// hashes of the latest heads delivered, oldest first
+	delivered []ethCmn.Hash
+
+	mu     sync.Mutex
+	health HeadSourceHealth
+}
+
+// NewHeadSource creates a head source delivering heads to the channel
+func NewHeadSource(client HeadClient, heads chan<- *ethTypes.Header, pollInterval, minBackoff, maxBackoff time.Duration, logger log.Logger) *HeadSource {
+	return &HeadSource{
+		client:       client,
+		heads:        heads,
+		pollInterval: pollInterval,
+		minBackoff:   minBackoff,
+		maxBackoff:   maxBackoff,
+		logger:       logger,
+	}
+}
+
+// Health returns the current state of the head source
+func (h *HeadSource) Health() HeadSourceHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.health
+}
+
+func (h *HeadSource) updateHealth(update func(health *HeadSourceHealth)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	update(&h.health)
+}
+
+// Run delivers heads until the context is cancelled
+func (h *HeadSource) Run(ctx context.Context) {
+	backoff := h.minBackoff
+	subscribedBefore := false
+	for {
+		subHeads := make(chan *ethTypes.Header)
+		sub, err := h.client.SubscribeNewHead(ctx, subHeads)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			h.logger.Error("Unable to subscribe to new heads, polling until the next attempt", "retryIn", backoff, "error", err)
+			h.updateHealth(func(health *HeadSourceHealth) {
+				health.Subscribed = false
+				health.LastError = err.Error()
+			})
+			if !h.pollFor(ctx, backoff) {
+				return
+			}
+			backoff *= 2
+			if backoff > h.maxBackoff {
+				backoff = h.maxBackoff
+			}
+			continue
+		}
+
+		backoff = h.minBackoff
+		h.updateHealth(func(health *HeadSourceHealth) {
+			health.Subscribed = true
+			if subscribedBefore {
+				health.Reconnects++
+			}
+		})
+		if subscribedBefore {
+			h.logger.Info("Subscription to new heads re-established")
+		}
+		subscribedBefore = true
+
+		if !h.consume(ctx, sub, subHeads) {
+			return
+		}
+	}
+}
+
+// consume delivers the heads of the subscription until it goes down
+// returns false if the context was cancelled
+func (h *HeadSource) consume(ctx context.Context, sub ethereum.Subscription, subHeads chan *ethTypes.Header) bool {
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case head := <-subHeads:
+			if !h.deliver(ctx, head) {
+				return false
+			}
+		case err := <-sub.Err():
+			msg := "subscription closed"
+			if err != nil {
+				msg = err.Error()
+			}
+			h.logger.Error("Subscription to new heads went down", "error", msg)
+			h.updateHealth(func(health *HeadSourceHealth) {
+				health.Subscribed = false
+				health.LastError = msg
+			})
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// pollFor polls for the latest head for the given duration
+// returns false if the context was cancelled
+func (h *HeadSource) pollFor(ctx context.Context, duration time.Duration) bool {
+	h.updateHealth(func(health *HeadSourceHealth) { health.Polling = true })
+	defer h.updateHealth(func(health *HeadSourceHealth) { health.Polling = false })
+
+	deadline := time.NewTimer(duration)
+	defer deadline.Stop()
+	ticker := time.NewTicker(h.pollInterval)
+	defer ticker.Stop()
+	for {
+		head, err := h.client.HeaderByNumber(ctx, nil)
+		if err == nil && head != nil {
+			if !h.deliver(ctx, head) {
+				return false
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// deliver sends the head on unless it was delivered already
+// returns false if the context was cancelled
+func (h *HeadSource) deliver(ctx context.Context, head *ethTypes.Header) bool {
+	hash := head.Hash()
+	for _, delivered := range h.delivered {
+		if delivered == hash {
+			return true
+		}
+	}
+	h.delivered = append(h.delivered, hash)
+	if len(h.delivered) > ReorgDepth {
+		h.delivered = h.delivered[1:]
+	}
+
+	select {
+	case h.heads <- head:
+	case <-ctx.Done():
+		return false
+	}
+	h.updateHealth(func(health *HeadSourceHealth) {
+		health.LastHead = head.Number.Uint64()
+		health.LastHeadAt = time.Now()
+	})
+	return true
+}
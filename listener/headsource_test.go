@@ -0,0 +1,155 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+// fakeSubscription is a head subscription the test can break
+type fakeSubscription struct {
+	err  chan error
+	once sync.Once
+}
+
+func (s *fakeSubscription) Unsubscribe() {
+	s.once.Do(func() { close(s.err) })
+}
+
+func (s *fakeSubscription) Err() <-chan error {
+	return s.err
+}
+
+// fakeRPC is a scriptable main chain node, subscriptions fail while it is down
+type fakeRPC struct {
+	mu      sync.Mutex
+	down    bool
+	head    *ethTypes.Header
+	subCh   chan<- *ethTypes.Header
+	sub     *fakeSubscription
+	attempt int
+}
+
+func (r *fakeRPC) SubscribeNewHead(ctx context.Context, ch chan<- *ethTypes.Header) (ethereum.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempt++
+	if r.down {
+		return nil, errors.New("connection refused")
+	}
+	r.sub = &fakeSubscription{err: make(chan error, 1)}
+	r.subCh = ch
+	return r.sub, nil
+}
+
+func (r *fakeRPC) HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.head, nil
+}
+
+// mine makes a new head
+func (r *fakeRPC) mine(n int64) *ethTypes.Header {
+	head := &ethTypes.Header{Number: big.NewInt(n)}
+	r.mu.Lock()
+	r.head = head
+	r.mu.Unlock()
+	return head
+}
+
+// push sends the head through the current subscription
+func (r *fakeRPC) push(head *ethTypes.Header) {
+	r.mu.Lock()
+	ch := r.subCh
+	r.mu.Unlock()
+	ch <- head
+}
+
+// disconnect breaks the subscription and fails new ones
+func (r *fakeRPC) disconnect() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.down = true
+	r.sub.err <- errors.New("websocket closed")
+}
+
+func (r *fakeRPC) reconnect() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.down = false
+}
+
+func receiveHead(t *testing.T, heads chan *ethTypes.Header) *ethTypes.Header {
+	select {
+	case head := <-heads:
+		return head
+	case <-time.After(2 * time.Second):
+		t.Fatal("no head received")
+		return nil
+	}
+}
+
+func TestHeadSourceReconnects(t *testing.T) {
+	rpc := &fakeRPC{}
+	heads := make(chan *ethTypes.Header)
+	source := NewHeadSource(rpc, heads, 5*time.Millisecond, 20*time.Millisecond, 40*time.Millisecond, log.NewNopLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go source.Run(ctx)
+
+	// heads come through the subscription, duplicates are dropped
+	require.Eventually(t, func() bool { return source.Health().Subscribed }, 2*time.Second, 5*time.Millisecond)
+	head := rpc.mine(1)
+	rpc.push(head)
+	require.Equal(t, int64(1), receiveHead(t, heads).Number.Int64())
+	rpc.push(head)
+	rpc.push(rpc.mine(2))
+	require.Equal(t, int64(2), receiveHead(t, heads).Number.Int64())
+	require.True(t, source.Health().Subscribed)
+
+	// heads are polled for while disconnected
+	rpc.disconnect()
+	rpc.mine(3)
+	require.Equal(t, int64(3), receiveHead(t, heads).Number.Int64())
+	health := source.Health()
+	require.False(t, health.Subscribed)
+	require.Equal(t, "connection refused", health.LastError)
+
+	// back to the subscription once reconnected
+	rpc.reconnect()
+	require.Eventually(t, func() bool { return source.Health().Subscribed }, 2*time.Second, 5*time.Millisecond)
+	require.Equal(t, uint64(1), source.Health().Reconnects)
+	rpc.push(rpc.mine(4))
+	require.Equal(t, int64(4), receiveHead(t, heads).Number.Int64())
+	require.Equal(t, uint64(4), source.Health().LastHead)
+}
+
+func TestHeadSourcePollsWhenSubscriptionsUnsupported(t *testing.T) {
+	rpc := &fakeRPC{down: true}
+	rpc.mine(7)
+	heads := make(chan *ethTypes.Header)
+	source := NewHeadSource(rpc, heads, 5*time.Millisecond, 10*time.Millisecond, 20*time.Millisecond, log.NewNopLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go source.Run(ctx)
+
+	require.Equal(t, int64(7), receiveHead(t, heads).Number.Int64())
+	rpc.mine(8)
+	require.Equal(t, int64(8), receiveHead(t, heads).Number.Int64())
+
+	// keeps trying to subscribe with backoff
+	require.Eventually(t, func() bool {
+		rpc.mu.Lock()
+		defer rpc.mu.Unlock()
+		return rpc.attempt >= 3
+	}, 2*time.Second, 5*time.Millisecond)
+	require.False(t, source.Health().Subscribed)
+}
@@ -0,0 +1,100 @@
+package listener
+
+import (
+	"sync"
+
+	ethCmn "github.com/ethereum/go-ethereum/common"
+)
+
+// CalldataFetcher fetches the txs of a batch from the calldata of the main chain tx which submitted it
+type CalldataFetcher interface {
+	FetchBatchInputData(txHash ethCmn.Hash) ([][]byte, error)
+}
+
+// calldataResult is the outcome of fetching the calldata of a tx, done is closed once it is known
+type calldataResult struct {
+	txs  [][]byte
+	err  error
+	done chan struct{}
+}
+
+// Prefetcher fetches the calldata of upcoming batches in the background with a bounded number of workers
+// Batches are still applied in order, each one waiting for its calldata if it isn't there yet
+type Prefetcher struct {
+	fetcher CalldataFetcher
+	workers int
+
+	mu      sync.Mutex
+	results map[ethCmn.Hash]*calldataResult
+}
+
+// NewPrefetcher creates a prefetcher fetching at most workers calldata at a time
+func NewPrefetcher(fetcher CalldataFetcher, workers int) *Prefetcher {
+	return &Prefetcher{
+		fetcher: fetcher,
+		workers: workers,
+		results: make(map[ethCmn.Hash]*calldataResult),
+	}
+}
+
+// Prefetch starts fetching the calldata of the txs, in order, those being fetched already are skipped
+func (p *Prefetcher) Prefetch(txHashes []ethCmn.Hash) {
+	type job struct {
+		txHash ethCmn.Hash
+		result *calldataResult
+	}
+	var jobs []job
+	p.mu.Lock()
+	for _, txHash := range txHashes {
+		if _, ok := p.results[txHash]; ok {
+			continue
+		}
+		result := &calldataResult{done: make(chan struct{})}
+		p.results[txHash] = result
+		jobs = append(jobs, job{txHash: txHash, result: result})
+	}
+	p.mu.Unlock()
+	if len(jobs) == 0 {
+		return
+	}
+
+	queue := make(chan job, len(jobs))
+	for _, j := range jobs {
+		queue <- j
+	}
+	close(queue)
+
+	workers := p.workers
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := range queue {
+				j.result.txs, j.result.err = p.fetcher.FetchBatchInputData(j.txHash)
+				close(j.result.done)
+			}
+		}()
+	}
+}
+
+// Get returns the calldata of the tx, waiting for it if it is being prefetched and fetching it otherwise
+// The result is only handed out once, getting it again fetches it again
+func (p *Prefetcher) Get(txHash ethCmn.Hash) ([][]byte, error) {
+	p.mu.Lock()
+	result, ok := p.results[txHash]
+	delete(p.results, txHash)
+	p.mu.Unlock()
+	if !ok {
+		return p.fetcher.FetchBatchInputData(txHash)
+	}
+	<-result.done
+	return result.txs, result.err
+}
+
+// Clear drops the results which weren't picked up, fetches in progress are left to finish
+func (p *Prefetcher) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.results = make(map[ethCmn.Hash]*calldataResult)
+}
@@ -0,0 +1,101 @@
+package listener
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+// slowFetcher returns the tx hash as calldata after a delay, tracking how many fetches run at once
+type slowFetcher struct {
+	delay   time.Duration
+	failing ethCmn.Hash
+
+	running    int32
+	maxRunning int32
+	mu         sync.Mutex
+	fetched    map[ethCmn.Hash]int
+}
+
+func (f *slowFetcher) FetchBatchInputData(txHash ethCmn.Hash) ([][]byte, error) {
+	running := atomic.AddInt32(&f.running, 1)
+	defer atomic.AddInt32(&f.running, -1)
+	for {
+		max := atomic.LoadInt32(&f.maxRunning)
+		if running <= max || atomic.CompareAndSwapInt32(&f.maxRunning, max, running) {
+			break
+		}
+	}
+	f.mu.Lock()
+	f.fetched[txHash]++
+	f.mu.Unlock()
+
+	time.Sleep(f.delay)
+	if txHash == f.failing {
+		return nil, errors.New("not found")
+	}
+	return [][]byte{txHash.Bytes()}, nil
+}
+
+func TestPrefetcherBoundsWorkers(t *testing.T) {
+	fetcher := &slowFetcher{delay: 10 * time.Millisecond, fetched: make(map[ethCmn.Hash]int)}
+	prefetcher := NewPrefetcher(fetcher, 3)
+
+	var txHashes []ethCmn.Hash
+	for i := 0; i < 12; i++ {
+		txHashes = append(txHashes, ethCmn.BigToHash(big.NewInt(int64(i+1))))
+	}
+	prefetcher.Prefetch(txHashes)
+	// prefetching again doesn't fetch twice
+	prefetcher.Prefetch(txHashes)
+
+	// applied in order
+	for _, txHash := range txHashes {
+		txs, err := prefetcher.Get(txHash)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{txHash.Bytes()}, txs)
+	}
+	require.Equal(t, int32(3), atomic.LoadInt32(&fetcher.maxRunning))
+	for _, txHash := range txHashes {
+		require.Equal(t, 1, fetcher.fetched[txHash])
+	}
+}
+
+func TestPrefetcherFetchesAgainAfterFailure(t *testing.T) {
+	failing := ethCmn.HexToHash("0x1")
+	fetcher := &slowFetcher{failing: failing, fetched: make(map[ethCmn.Hash]int)}
+	prefetcher := NewPrefetcher(fetcher, 2)
+
+	prefetcher.Prefetch([]ethCmn.Hash{failing})
+	_, err := prefetcher.Get(failing)
+	require.Error(t, err)
+
+	// a retry isn't served the failed result
+	fetcher.failing = ethCmn.Hash{}
+	txs, err := prefetcher.Get(failing)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, 2, fetcher.fetched[failing])
+}
+
+func TestPrefetcherClear(t *testing.T) {
+	fetcher := &slowFetcher{fetched: make(map[ethCmn.Hash]int)}
+	prefetcher := NewPrefetcher(fetcher, 2)
+	txHash := ethCmn.HexToHash("0x2")
+
+	prefetcher.Prefetch([]ethCmn.Hash{txHash})
+	prefetcher.Clear()
+	_, err := prefetcher.Get(txHash)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		fetcher.mu.Lock()
+		defer fetcher.mu.Unlock()
+		return fetcher.fetched[txHash] == 2
+	}, time.Second, time.Millisecond)
+}
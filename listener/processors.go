@@ -4,11 +4,12 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"math/big"
 
 	"github.com/BOPR/common"
+	"github.com/BOPR/config"
 	"github.com/BOPR/core"
 	"github.com/ethereum/go-ethereum/accounts/abi"
+	ethCmn "github.com/ethereum/go-ethereum/common"
 	ethTypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/jinzhu/gorm"
 
@@ -18,16 +19,14 @@ import (
 // ZEROROOT
 const ZEROROOT = "0x0000000000000000000000000000000000000000000000000000000000000000"
 
-func (s *Syncer) processNewPubkeyAddition(eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) {
+func (s *Syncer) processNewPubkeyAddition(db core.DB, eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) error {
 	s.Logger.Info("New deposit found")
 
 	// unpack event
 	event := new(logger.LoggerNewPubkeyAdded)
 	err := common.UnpackLog(abiObject, event, eventName, vLog)
 	if err != nil {
-		// TODO do something with this error
-		fmt.Println("Unable to unpack log:", err)
-		panic(err)
+		return err
 	}
 
 	s.Logger.Info(
@@ -40,20 +39,19 @@ func (s *Syncer) processNewPubkeyAddition(eventName string, abiObject *abi.ABI,
 	// add new account in pending state to DB and
 	pathToNode, err := core.SolidityPathToNodePath(event.AccountID.Uint64(), 4)
 	if err != nil {
-		// TODO do something with this error
-		fmt.Println("Unable to convert path", err)
-		panic(err)
+		return err
 	}
 	newPDAAccount, err := core.NewPDA(event.AccountID.Uint64(), hex.EncodeToString(event.Pubkey), pathToNode)
 	if err != nil {
-		fmt.Println("unable to create new account")
-		panic(err)
+		return err
 	}
-	if err := s.DBInstance.UpdatePDALeaf(*newPDAAccount); err != nil {
-		panic(err)
+	if err := db.JournalPubkey(vLog.BlockNumber, newPDAAccount.Path); err != nil {
+		return err
 	}
+	return db.UpdatePDALeaf(*newPDAAccount)
 }
-func (s *Syncer) processDepositQueued(eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) {
+
+func (s *Syncer) processDepositQueued(db core.DB, eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) error {
 	s.Logger.Info("New deposit found")
 
 	// unpack event
@@ -61,9 +59,7 @@ func (s *Syncer) processDepositQueued(eventName string, abiObject *abi.ABI, vLog
 
 	err := common.UnpackLog(abiObject, event, eventName, vLog)
 	if err != nil {
-		// TODO do something with this error
-		fmt.Println("Unable to unpack log:", err)
-		panic(err)
+		return err
 	}
 
 	s.Logger.Info(
@@ -76,34 +72,43 @@ func (s *Syncer) processDepositQueued(eventName string, abiObject *abi.ABI, vLog
 
 	// add new account in pending state to DB and
 	newAccount := core.NewPendingUserAccount(event.AccountID.Uint64(), event.Data)
-	if err := s.DBInstance.AddNewPendingAccount(*newAccount); err != nil {
-		panic(err)
+	if err := db.JournalDepositQueued(vLog.BlockNumber, newAccount.AccountID); err != nil {
+		return err
 	}
+	return db.AddNewPendingAccount(*newAccount)
 }
 
-func (s *Syncer) processDepositSubtreeCreated(eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) {
+func (s *Syncer) processDepositSubtreeCreated(db core.DB, eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) error {
 	s.Logger.Info("New deposit subtree created")
 	// unpack event
 	event := new(logger.LoggerDepositSubTreeReady)
 	err := common.UnpackLog(abiObject, event, eventName, vLog)
 	if err != nil {
-		// TODO do something with this error
-		fmt.Println("Unable to unpack log:", err)
-		panic(err)
+		return err
+	}
+	if err := db.JournalPendingDeposits(vLog.BlockNumber); err != nil {
+		return err
+	}
+	err = db.AttachDepositInfo(event.Root)
+	if err != nil {
+		return err
 	}
-	err = s.DBInstance.AttachDepositInfo(event.Root)
+	// subtrees which got ready while catching up are finalised once synced, if no one did by then
+	synced, err := db.IsSynced()
 	if err != nil {
-		// TODO do something with this error
-		fmt.Println("Unable to attack deposit information:", err)
-		panic(err)
+		return err
+	}
+	if s.replaying || !synced {
+		s.Logger.Info("Catching up, not sending deposit finalisation", "root", core.ByteArray(event.Root).String())
+		return nil
 	}
-	// TODO add a sync flag, do not send transactions when in sync mode
 
-	// send deposit finalisation transction to ethereum chain
-	s.SendDepositFinalisationTx()
+	// send deposit finalisation transction to ethereum chain once the subtree is committed
+	s.finaliseDeposits = true
+	return nil
 }
 
-func (s *Syncer) processDepositFinalised(eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) {
+func (s *Syncer) processDepositFinalised(db core.DB, eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) error {
 	s.Logger.Info("Deposit batch finalised!")
 
 	// unpack event
@@ -111,9 +116,7 @@ func (s *Syncer) processDepositFinalised(eventName string, abiObject *abi.ABI, v
 
 	err := common.UnpackLog(abiObject, event, eventName, vLog)
 	if err != nil {
-		// TODO do something with this error
-		fmt.Println("Unable to unpack log:", err)
-		panic(err)
+		return err
 	}
 
 	depositRoot := core.ByteArray(event.DepositSubTreeRoot)
@@ -126,25 +129,55 @@ func (s *Syncer) processDepositFinalised(eventName string, abiObject *abi.ABI, v
 		"PathToDepositSubTreeInserted", pathToDepositSubTree.String(),
 	)
 
-	// TODO handle error
-	newRoot, err := s.DBInstance.FinaliseDepositsAndAddBatch(depositRoot, pathToDepositSubTree.Uint64())
+	if err := db.JournalPendingDeposits(vLog.BlockNumber); err != nil {
+		return err
+	}
+	newRoot, err := db.FinaliseDepositsAndAddBatch(depositRoot, pathToDepositSubTree.Uint64())
 	if err != nil {
-		fmt.Println("Error while finalising deposits", err)
+		return err
 	}
+	s.Logger.Info("Deposits finalised", "newRoot", newRoot)
 
-	fmt.Println("new root", newRoot)
+	// the batch of the deposits is emitted by the same main chain tx, the root is checked by whichever is applied last
+	batch, err := db.GetBatchBySubmissionHash(vLog.TxHash.String())
+	if gorm.IsRecordNotFoundError(err) || (err == nil && batch.Status == core.BATCH_BROADCASTED) {
+		s.depositsFinalisedIn = vLog.TxHash
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return s.checkDepositBatchRoot(db, batch.BatchID, batch.StateRoot, batch.Committer == config.OperatorAddress.String())
 }
 
-func (s *Syncer) processNewBatch(eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) {
+// checkDepositBatchRoot compares the state root committed by a deposit batch with the local one
+// once the deposits were finalised locally
+func (s *Syncer) checkDepositBatchRoot(db core.DB, batchID uint64, committedRoot string, own bool) error {
+	root, err := db.GetRoot()
+	if err != nil {
+		return err
+	}
+	if root.Hash == committedRoot {
+		return nil
+	}
+	mismatch := &RootMismatchError{BatchID: batchID, Committed: committedRoot, Computed: root.Hash}
+	// deposit batches carry no txs to dispute
+	mismatch.Resync = s.rootMismatchAction(batchID, own) == config.RootMismatchResync
+	return mismatch
+}
+
+// isDepositBatch returns true if the batch only carries deposits, its root is checked along with the deposits
+func isDepositBatch(txs [][]byte, event *logger.LoggerNewBatch) bool {
+	return len(txs) == 0 && !isTxBatchType(uint64(event.BatchType))
+}
+
+func (s *Syncer) processNewBatch(db core.DB, eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) error {
 	s.Logger.Info("New batch submitted on eth chain")
 
 	event := new(logger.LoggerNewBatch)
 
 	err := common.UnpackLog(abiObject, event, eventName, vLog)
 	if err != nil {
-		// TODO do something with this error
-		fmt.Println("Unable to unpack log:", err)
-		panic(err)
+		return err
 	}
 
 	s.Logger.Info(
@@ -156,86 +189,167 @@ func (s *Syncer) processNewBatch(eventName string, abiObject *abi.ABI, vLog *eth
 		"Committer", event.Committer.String(),
 	)
 
-	params, err := s.DBInstance.GetParams()
+	params, err := db.GetParams()
 	if err != nil {
-		return
+		return err
 	}
 
 	// if the batch has some txs, parse them
 	var txs [][]byte
 	if ZEROROOT != core.ByteArray(event.Txroot).String() {
-		// pick the calldata for the batch
-		txs, err = s.loadedBazooka.FetchBatchInputData(vLog.TxHash)
+		// pick the calldata for the batch, prefetched along with the other batches of the range
+		txs, err = s.prefetcher.Get(vLog.TxHash)
 		if err != nil {
-			// TODO do something with this error
-			panic(err)
+			return err
 		}
 	}
 
-	batch, err := s.DBInstance.GetBatchByIndex(event.Index.Uint64())
-	// if we havent seen the batch, apply txs and store batch
-	if err != nil && gorm.IsRecordNotFoundError(err) {
-		s.Logger.Info("Found a new batch, applying transactions and adding new batch", "index", event.Index.Uint64)
-		err := s.ApplyTxsFromBatch(txs, uint64(event.BatchType))
+	// the deposits of a deposit batch are finalised by the same main chain tx, if they were already
+	// the root is checked now, otherwise once they are
+	own := event.Committer == config.OperatorAddress
+	if isDepositBatch(txs, event) && s.depositsFinalisedIn == vLog.TxHash {
+		s.depositsFinalisedIn = ethCmn.Hash{}
+		if err := s.checkDepositBatchRoot(db, event.Index.Uint64(), core.ByteArray(event.UpdatedRoot).String(), own); err != nil {
+			return err
+		}
+	}
+
+	includedTxs, err := core.EncodeBatchTxs(txs)
+	if err != nil {
+		return err
+	}
+
+	batch, found, err := s.localBatch(db, event, vLog)
+	if err != nil {
+		return err
+	}
+	// if we havent seen the batch, verify and apply txs and store batch
+	if !found {
+		s.Logger.Info("Found a new batch, verifying and applying transactions", "index", event.Index.Uint64())
+		disputed, err := s.verifyAndApplyBatch(db, txs, event, vLog.BlockNumber, own)
 		if err != nil {
-			panic(err)
+			return err
+		}
+		journalSeq, err := db.LastJournalSeq()
+		if err != nil {
+			return err
 		}
 
 		newBatch := core.Batch{
 			BatchID:              event.Index.Uint64(),
 			StateRoot:            core.ByteArray(event.UpdatedRoot).String(),
 			TxRoot:               core.ByteArray(event.Txroot).String(),
-			TransactionsIncluded: core.ConcatTxs(txs),
+			TransactionsIncluded: includedTxs,
 			Committer:            event.Committer.String(),
+			BatchType:            uint64(event.BatchType),
 			StakeAmount:          params.StakeAmount,
-			FinalisesOn:          *big.NewInt(int64(params.FinalisationTime)),
-			Status:               core.BATCH_COMMITTED,
+			SubmissionHash:       vLog.TxHash.String(),
+			// the finalisation time is a number of blocks from inclusion
+			FinalisesOn: vLog.BlockNumber + params.FinalisationTime,
+			Status:      core.BATCH_COMMITTED,
+			JournalSeq:  journalSeq,
 		}
-
-		err = s.DBInstance.AddNewBatch(newBatch)
-		if err != nil {
-			// TODO do something with this error
-			panic(err)
+		if disputed {
+			newBatch.Status = core.BATCH_DISPUTED
 		}
-		return
-	} else if err != nil {
-		s.Logger.Error("Unable to fetch batch", "index", event.Index, "err", err)
-		return
+		return db.AddNewBatch(newBatch)
 	}
 
-	// if batch is present but in a non committed state we parse txs and commit batch
-	if batch.Status != core.BATCH_COMMITTED {
+	// batch broadcasted by us, txs applied but batch needs to be committed
+	if batch.Status == core.BATCH_BROADCASTED {
 		s.Logger.Info("Found a non committed batch")
-		if batch.StateRoot != core.ByteArray(event.UpdatedRoot).String() {
-			// State root mismatch error
+		unapplied, err := db.GetUnappliedBatchTxCount(batch.BatchID)
+		if err != nil {
+			return err
+		}
+		if unapplied != 0 {
+			return s.applyUnwoundBatch(db, txs, event, vLog, params)
+		}
+		// deposit finalisations are sent without a root, it is checked along with the deposits
+		if batch.BatchType != core.BATCH_TYPE_DEPOSIT_FINALISATION && batch.StateRoot != core.ByteArray(event.UpdatedRoot).String() {
+			mismatch := &RootMismatchError{BatchID: batch.BatchID, Committed: core.ByteArray(event.UpdatedRoot).String(), Computed: batch.StateRoot}
+			mismatch.Resync = s.rootMismatchAction(batch.BatchID, true) == config.RootMismatchResync
+			return mismatch
 		}
-		// batch broadcasted by us
-		// txs applied but batch needs to be committed
-		// TODO add batch type
 		newBatch := core.Batch{
 			BatchID:              event.Index.Uint64(),
 			StateRoot:            core.ByteArray(event.UpdatedRoot).String(),
 			TxRoot:               core.ByteArray(event.Txroot).String(),
-			TransactionsIncluded: core.ConcatTxs(txs),
+			TransactionsIncluded: includedTxs,
 			Committer:            event.Committer.String(),
+			BatchType:            uint64(event.BatchType),
 			StakeAmount:          params.StakeAmount,
-			FinalisesOn:          *big.NewInt(int64(params.FinalisationTime)),
-			Status:               core.BATCH_COMMITTED,
+			SubmissionHash:       vLog.TxHash.String(),
+			FinalisesOn:          vLog.BlockNumber + params.FinalisationTime,
 		}
-		s.DBInstance.CommitBatch(newBatch)
+		if err := db.CommitBatch(newBatch); err != nil {
+			return err
+		}
+	}
+	return db.UpdateSyncStatusWithBatchNumber(event.Index.Uint64())
+}
+
+// localBatch returns the local row of the batch of the event, found is false if there is none
+// Our batches are recognised by any of the main chain txs sent to submit them and moved to the index
+// they landed at. A batch of someone else landing at the index of one of ours moves ours out of the way.
+func (s *Syncer) localBatch(db core.DB, event *logger.LoggerNewBatch, vLog *ethTypes.Log) (batch core.Batch, found bool, err error) {
+	index := event.Index.Uint64()
+	if event.Committer == config.OperatorAddress {
+		batch, err = db.GetBatchBySubmissionHash(vLog.TxHash.String())
+		if err == nil && batch.Status == core.BATCH_BROADCASTED {
+			if batch.BatchID != index {
+				s.Logger.Info("Our batch landed at another index than expected", "expected", batch.BatchID, "index", index)
+				if err := db.MoveBroadcastedBatch(batch.BatchID, index); err != nil {
+					return batch, false, err
+				}
+				batch.BatchID = index
+			}
+			return batch, true, nil
+		} else if err != nil && !gorm.IsRecordNotFoundError(err) {
+			return batch, false, err
+		}
+	}
+
+	batch, err = db.GetBatchByIndex(index)
+	if gorm.IsRecordNotFoundError(err) {
+		return batch, false, nil
+	} else if err != nil {
+		return batch, false, err
+	}
+	if batch.Status != core.BATCH_BROADCASTED {
+		return batch, true, nil
+	}
+	s.Logger.Info("Someone else committed a batch at the index of one of ours", "index", index, "committer", event.Committer.String())
+	if err := db.DisplaceBroadcastedBatches(index); err != nil {
+		return batch, false, err
 	}
-	s.DBInstance.UpdateSyncStatusWithBatchNumber(event.Index.Uint64())
+	return core.Batch{}, false, nil
 }
 
-func (s *Syncer) processRegisteredToken(eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) {
+func (s *Syncer) processRegistrationRequest(db core.DB, eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) error {
+	s.Logger.Info("New token registration requested")
+	event := new(logger.LoggerRegistrationRequest)
+
+	err := common.UnpackLog(abiObject, event, eventName, vLog)
+	if err != nil {
+		return err
+	}
+	s.Logger.Info(
+		"⬜ New event found",
+		"event", eventName,
+		"TokenAddress", event.TokenContract.String(),
+	)
+	request := core.TokenRegistrationRequest{Address: event.TokenContract.String(), BlockNumber: vLog.BlockNumber, TxHash: vLog.TxHash.String()}
+	return db.AddTokenRegistrationRequest(request)
+}
+
+func (s *Syncer) processRegisteredToken(db core.DB, eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) error {
 	s.Logger.Info("New token registered")
 	event := new(logger.LoggerRegisteredToken)
 
 	err := common.UnpackLog(abiObject, event, eventName, vLog)
 	if err != nil {
-		// TODO do something with this error
-		fmt.Println("Unable to unpack log:", err)
-		panic(err)
+		return err
 	}
 	s.Logger.Info(
 		"⬜ New event found",
@@ -243,85 +357,277 @@ func (s *Syncer) processRegisteredToken(eventName string, abiObject *abi.ABI, vL
 		"TokenAddress", event.TokenContract.String(),
 		"TokenID", event.TokenType,
 	)
-	newToken := core.Token{TokenID: event.TokenType.Uint64(), Address: event.TokenContract.String()}
-	if err := s.DBInstance.AddToken(newToken); err != nil {
-		panic(err)
+	newToken := core.Token{TokenID: event.TokenType.Uint64(), Address: event.TokenContract.String(), BlockNumber: vLog.BlockNumber}
+
+	// the token is registered either way, not every token exposes its metadata
+	symbol, decimals, err := s.loadedBazooka.FetchTokenMetadata(event.TokenContract)
+	if err != nil {
+		s.Logger.Error("Unable to fetch token metadata", "token", event.TokenContract.String(), "error", err)
+	} else {
+		newToken.Symbol = symbol
+		newToken.Decimals = uint64(decimals)
 	}
+	return db.RegisterToken(newToken)
 }
 
-func (s *Syncer) SendDepositFinalisationTx() {
+func (s *Syncer) SendDepositFinalisationTx() error {
 	params, err := s.DBInstance.GetParams()
 	if err != nil {
-		return
+		return err
 	}
 	nodeToBeReplaced, siblings, err := s.DBInstance.GetDepositNodeAndSiblings()
 	if err != nil {
-		return
+		return err
+	}
+	return s.loadedBazooka.FireDepositFinalisation(nodeToBeReplaced, siblings, params.MaxDepositSubTreeHeight)
+}
+
+func (s *Syncer) processBatchRollback(db core.DB, eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) error {
+	s.Logger.Info("Batch rolled back")
+	event := new(logger.LoggerBatchRollback)
+
+	err := common.UnpackLog(abiObject, event, eventName, vLog)
+	if err != nil {
+		return err
 	}
+	s.Logger.Info(
+		"⬜ New event found",
+		"event", eventName,
+		"BatchID", event.BatchId.String(),
+		"Committer", event.Committer.String(),
+		"StakeSlashed", event.StakeSlashed.String(),
+	)
 
-	err = s.loadedBazooka.FireDepositFinalisation(nodeToBeReplaced, siblings, params.MaxDepositSubTreeHeight)
+	batch, err := db.GetBatchByIndex(event.BatchId.Uint64())
+	if err != nil {
+		return err
+	}
+	// the contract rolls back the batch along with the ones after it, the first of them which was applied
+	// locally takes the state back to before it, batches which were disputed were never applied
+	if batch.Status != core.BATCH_DISPUTED && batch.BatchID != 0 {
+		previous, err := db.GetBatchByIndex(batch.BatchID - 1)
+		if err != nil {
+			return err
+		}
+		if previous.Status != core.BATCH_ROLLED_BACK {
+			s.Logger.Info("A batch applied locally was rolled back, undoing it along with the batches after it", "index", batch.BatchID)
+			err := db.RollbackBatchesFrom(batch.BatchID)
+			if errors.Is(err, core.ErrPreStateUnknown) {
+				s.Logger.Error("A batch applied locally was rolled back, local state needs a resync", "index", batch.BatchID)
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+	if err := db.JournalBatchStatus(vLog.BlockNumber, batch.BatchID); err != nil {
+		return err
+	}
+	if err := db.UpdateBatchStatus(batch.BatchID, core.BATCH_ROLLED_BACK); err != nil {
+		return err
+	}
+	return db.MarkDisputeRolledBack(batch.BatchID)
 }
 
-func (s *Syncer) ApplyTxsFromBatch(txs [][]byte, txType uint64) error {
-	if len(txs) == 0 {
+func (s *Syncer) processStakeWithdraw(db core.DB, eventName string, abiObject *abi.ABI, vLog *ethTypes.Log) error {
+	s.Logger.Info("Stake withdrawn")
+	event := new(logger.LoggerStakeWithdraw)
+
+	err := common.UnpackLog(abiObject, event, eventName, vLog)
+	if err != nil {
+		return err
+	}
+	s.Logger.Info(
+		"⬜ New event found",
+		"event", eventName,
+		"BatchID", event.BatchId.String(),
+		"Committer", event.Committed.String(),
+		"Amount", event.Amount.String(),
+	)
+
+	if err := db.JournalBatchStatus(vLog.BlockNumber, event.BatchId.Uint64()); err != nil {
+		return err
+	}
+	return db.RecordStakeWithdrawn(event.BatchId.Uint64(), event.Amount)
+}
+
+// applyUnwoundBatch handles the NewBatch event for a batch we broadcasted whose txs were unwound by a reorg
+// The batch that landed is applied like anyone else's, our txs are settled depending on whether it was ours and valid
+func (s *Syncer) applyUnwoundBatch(db core.DB, txs [][]byte, event *logger.LoggerNewBatch, vLog *ethTypes.Log, params core.Params) error {
+	s.Logger.Info("Txs of the batch were unwound by a reorg, applying the batch", "index", event.Index.Uint64())
+	own := event.Committer == config.OperatorAddress
+	disputed, err := s.verifyAndApplyBatch(db, txs, event, vLog.BlockNumber, own)
+	if err != nil {
+		return err
+	}
+	includedTxs, err := core.EncodeBatchTxs(txs)
+	if err != nil {
+		return err
+	}
+	journalSeq, err := db.LastJournalSeq()
+	if err != nil {
+		return err
+	}
+	newBatch := core.Batch{
+		BatchID:              event.Index.Uint64(),
+		StateRoot:            core.ByteArray(event.UpdatedRoot).String(),
+		TxRoot:               core.ByteArray(event.Txroot).String(),
+		TransactionsIncluded: includedTxs,
+		Committer:            event.Committer.String(),
+		BatchType:            uint64(event.BatchType),
+		StakeAmount:          params.StakeAmount,
+		SubmissionHash:       vLog.TxHash.String(),
+		FinalisesOn:          vLog.BlockNumber + params.FinalisationTime,
+		JournalSeq:           journalSeq,
+	}
+	if disputed {
+		newBatch.Status = core.BATCH_DISPUTED
+	}
+	if err := db.CommitBatch(newBatch); err != nil {
+		return err
+	}
+	applied := !disputed && own
+	if err := db.SettleUnappliedBatchTxs(event.Index.Uint64(), applied); err != nil {
+		return err
+	}
+	return db.UpdateSyncStatusWithBatchNumber(event.Index.Uint64())
+}
+
+// verifyAndApplyBatch re-executes a batch against the local state
+// An invalid batch is undone locally and a dispute is recorded for the watcher to send, unless
+// we committed it, own batches which don't apply are handled like a root mismatch
+// returns true if the batch wasn't applied because it is, or builds on, an invalid batch
+func (s *Syncer) verifyAndApplyBatch(db core.DB, txs [][]byte, event *logger.LoggerNewBatch, blockNumber uint64, own bool) (bool, error) {
+	// batches built on top of a disputed batch get rolled back along with it
+	outstanding, err := db.HasOutstandingDispute(blockNumber)
+	if err != nil {
+		return false, err
+	}
+	if outstanding {
+		s.Logger.Info("Batch builds on a disputed batch, not applying it", "index", event.Index)
+		return true, nil
+	}
+
+	// batches without txs are still checked, the root must be left as is
+	// other kinds of batches, like deposit batches, are applied by their own events
+	var coreTxs []core.Tx
+	if len(txs) == 0 && !isTxBatchType(uint64(event.BatchType)) {
 		s.Logger.Info("No txs to apply")
-		return nil
+		return false, nil
+	} else if coreTxs, err = s.DecodeTxsFromBatch(db, txs, uint64(event.BatchType)); err != nil {
+		return false, err
+	}
+	executed, err := s.loadedBazooka.ExecuteTxs(db, coreTxs)
+	if err != nil {
+		return false, err
+	}
+	reason, err := s.checkExecutedBatch(db, executed, event)
+	var mismatch *RootMismatchError
+	if errors.As(err, &mismatch) {
+		action := s.rootMismatchAction(mismatch.BatchID, own)
+		if action != config.RootMismatchDispute {
+			if err := executed.Revert(); err != nil {
+				return false, err
+			}
+			mismatch.Resync = action == config.RootMismatchResync
+			return false, mismatch
+		}
+		reason = mismatch.Error()
+	} else if err != nil || reason == "" {
+		return false, err
+	} else if own {
+		// we never dispute our own batches, it is our local state which is off
+		if err := executed.Revert(); err != nil {
+			return false, err
+		}
+		mismatch = &RootMismatchError{BatchID: event.Index.Uint64(), Committed: core.ByteArray(event.UpdatedRoot).String(), Reason: reason}
+		mismatch.Resync = s.rootMismatchAction(mismatch.BatchID, true) == config.RootMismatchResync
+		return false, mismatch
 	}
+
+	s.Logger.Error("Found an invalid batch, disputing it", "index", event.Index, "committer", event.Committer.String(), "reason", reason)
+	if err := executed.Revert(); err != nil {
+		return false, err
+	}
+	calldata, err := s.loadedBazooka.PackDisputeBatch(event.Index.Uint64(), executed.ABITxs, executed.Proofs)
+	if err != nil {
+		return false, err
+	}
+	dispute := core.Dispute{BatchID: event.Index.Uint64(), Reason: reason, Calldata: calldata}
+	return true, db.AddDispute(dispute)
+}
+
+// isTxBatchType returns true if the batches of the type carry txs applied by the syncer
+func isTxBatchType(batchType uint64) bool {
+	for _, txType := range core.TxTypes {
+		if txType == batchType {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExecutedBatch compares the re-executed batch with what was committed on chain
+// returns why the batch is invalid, empty if it is valid, a state root mismatch is returned as a RootMismatchError
+func (s *Syncer) checkExecutedBatch(db core.DB, executed core.ExecutedBatch, event *logger.LoggerNewBatch) (string, error) {
+	if i := executed.FirstInvalid(); i != -1 {
+		return fmt.Sprintf("invalid tx at index %v", i), nil
+	}
+	root, err := db.GetRoot()
+	if err != nil {
+		return "", err
+	}
+	if root.Hash != core.ByteArray(event.UpdatedRoot).String() {
+		return "", &RootMismatchError{BatchID: event.Index.Uint64(), Committed: core.ByteArray(event.UpdatedRoot).String(), Computed: root.Hash}
+	}
+	if len(executed.ABITxs) == 0 {
+		return "", nil
+	}
+	txRoot, err := s.loadedBazooka.GenerateTxRoot(executed.ABITxs)
+	if err != nil {
+		return "", err
+	}
+	if txRoot != core.ByteArray(event.Txroot) {
+		return fmt.Sprintf("tx root mismatch, committed: %v computed: %v", core.ByteArray(event.Txroot).String(), txRoot.String()), nil
+	}
+	return "", nil
+}
+
+// DecodeTxsFromBatch rebuilds the txs from the compressed txs in the batch calldata
+// Compressed txs don't carry the nonce, it is derived from the sender's account and the
+// sender's earlier txs in the same batch
+func (s *Syncer) DecodeTxsFromBatch(db core.DB, txs [][]byte, txType uint64) ([]core.Tx, error) {
 	var coreTxs []core.Tx
+	sentInBatch := make(map[uint64]int64)
 	for i := range txs {
-		var from, to uint64
-		var txData, sig []byte
-
 		switch txType {
 		case core.TX_TRANSFER_TYPE:
-			from, to, amount, sig, err := s.loadedBazooka.DecompressTransferTx(txs[i])
+			fromID, toID, amount, txSig, err := s.loadedBazooka.DecompressTransferTx(txs[i])
 			if err != nil {
-				return err
+				return nil, err
 			}
-			s.Logger.Debug("Fetched tx data", "from", from, "to", to, "amount", amount, "sig", sig)
-			fromAccount, err := s.DBInstance.GetAccountByID(from.Uint64())
+			s.Logger.Debug("Fetched tx data", "from", fromID, "to", toID, "amount", amount, "sig", txSig)
+			fromAccount, err := db.GetAccountByID(fromID.Uint64())
 			if err != nil {
-				return err
+				return nil, err
 			}
 			_, _, nonce, token, err := s.loadedBazooka.DecodeAccount(fromAccount.Data)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			s.Logger.Debug("Decoded account", "nonce", nonce, "token", token)
-			txData, err = s.loadedBazooka.EncodeTransferTx(from.Int64(), to.Int64(), token.Int64(), nonce.Int64(), amount.Int64(), core.TX_TRANSFER_TYPE)
+			// the signed message carries the next nonce of the sender, rebuild it as such
+			// so that the tx hash matches the one assigned by the node that received it
+			sentInBatch[fromID.Uint64()]++
+			txNonce := nonce.Int64() + sentInBatch[fromID.Uint64()]
+			txData, err := s.loadedBazooka.EncodeTransferTx(fromID.Int64(), toID.Int64(), token.Int64(), txNonce, amount.Int64(), core.TX_TRANSFER_TYPE)
 			if err != nil {
-				return err
+				return nil, err
 			}
+			coreTxs = append(coreTxs, core.NewTx(fromID.Uint64(), toID.Uint64(), txType, txData, hex.EncodeToString(txSig)))
 		default:
 			fmt.Println("TxType didnt match any options", txType)
-			return errors.New("Didn't match any options")
-		}
-		coreTx := core.NewTx(from, to, txType, txData, hex.EncodeToString(sig))
-		coreTxs = append(coreTxs, coreTx)
-		fromMP, toMP, _, err := coreTx.GetVerificationData()
-		if err != nil {
-			return err
-		}
-
-		updatedFromAccData, _, err := s.loadedBazooka.ApplyTx(fromMP, coreTx)
-		if err != nil {
-			return err
-		}
-
-		updatedToAccData, _, err := s.loadedBazooka.ApplyTx(toMP, coreTx)
-		if err != nil {
-			return err
+			return nil, errors.New("Didn't match any options")
 		}
-
-		err = coreTx.Apply(updatedFromAccData, updatedToAccData)
-		if err != nil {
-			return err
-		}
-		// TODO validate updated root post application
-		// root, err := s.DBInstance.GetRoot()
-		// if err != nil {
-		// 	return err
-		// }
 	}
-	return nil
+	return coreTxs, nil
 }
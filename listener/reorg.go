@@ -0,0 +1,108 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/BOPR/core"
+	"github.com/ethereum/go-ethereum"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrReorgTooDeep is returned when none of the synced blocks we kept track of is canonical anymore
+var ErrReorgTooDeep = errors.New("reorg is deeper than the synced blocks kept")
+
+// ChainReader reads headers and logs from the main chain
+type ChainReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]ethTypes.Log, error)
+}
+
+// ReorgState keeps track of the synced blocks and unwinds the local state to one of them
+type ReorgState interface {
+	LastJournalSeq() (uint64, error)
+	GetLastCommittedBatchID() (uint64, error)
+	AddSyncedBlock(block core.SyncedBlock, keep uint64) error
+	PruneJournal() error
+	GetSyncedBlocks(limit uint64) ([]core.SyncedBlock, error)
+	UnwindTo(block core.SyncedBlock) error
+}
+
+// NewSyncedBlock returns the synced block for the header
+func NewSyncedBlock(header ethTypes.Header, journalSeq, lastBatchID uint64) core.SyncedBlock {
+	return core.SyncedBlock{
+		Number:      header.Number.Uint64(),
+		Hash:        header.Hash().Hex(),
+		ParentHash:  header.ParentHash.Hex(),
+		JournalSeq:  journalSeq,
+		LastBatchID: lastBatchID,
+	}
+}
+
+// isReorged returns true if the block following the last synced block doesn't build on it
+func isReorged(ctx context.Context, chain ChainReader, last core.SyncedBlock) (bool, error) {
+	child, err := chain.HeaderByNumber(ctx, new(big.Int).SetUint64(last.Number+1))
+	if err != nil {
+		return false, err
+	}
+	return child.ParentHash.Hex() != last.Hash, nil
+}
+
+// findForkPoint returns the latest of the synced blocks, given newest first, which is still canonical
+func findForkPoint(ctx context.Context, chain ChainReader, synced []core.SyncedBlock) (fork core.SyncedBlock, err error) {
+	for _, block := range synced {
+		header, err := chain.HeaderByNumber(ctx, new(big.Int).SetUint64(block.Number))
+		if err != nil {
+			return fork, err
+		}
+		if header.Hash().Hex() == block.Hash {
+			return block, nil
+		}
+	}
+	return fork, ErrReorgTooDeep
+}
+
+// recordSyncedBlock records where the local state is once the events up to the header are applied
+func recordSyncedBlock(state ReorgState, header ethTypes.Header) error {
+	journalSeq, err := state.LastJournalSeq()
+	if err != nil {
+		return err
+	}
+	lastBatchID, err := state.GetLastCommittedBatchID()
+	if err != nil {
+		return err
+	}
+	if err := state.AddSyncedBlock(NewSyncedBlock(header, journalSeq, lastBatchID), ReorgDepth); err != nil {
+		return err
+	}
+	return state.PruneJournal()
+}
+
+// unwindIfReorged unwinds the local state to the fork point if the last synced block isn't canonical anymore,
+// the sync then goes on from the fork point. It returns the fork point, nil if there was no reorg
+// The lock is only held while unwinding, not while reading the chain
+func unwindIfReorged(ctx context.Context, chain ChainReader, state ReorgState, lock sync.Locker) (*core.SyncedBlock, error) {
+	synced, err := state.GetSyncedBlocks(ReorgDepth)
+	if err != nil {
+		return nil, err
+	}
+	if len(synced) == 0 {
+		return nil, nil
+	}
+	reorged, err := isReorged(ctx, chain, synced[0])
+	if err != nil || !reorged {
+		return nil, err
+	}
+	fork, err := findForkPoint(ctx, chain, synced)
+	if err != nil {
+		return nil, err
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if err := state.UnwindTo(fork); err != nil {
+		return nil, err
+	}
+	return &fork, nil
+}
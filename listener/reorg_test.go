@@ -0,0 +1,294 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/BOPR/core"
+	"github.com/ethereum/go-ethereum"
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChain is a main chain of linked headers along with the logs emitted in each block
+type fakeChain struct {
+	headers []*ethTypes.Header
+	logs    map[uint64][]ethTypes.Log
+
+	// queries returning more logs fail, 0 for no limit
+	maxLogs int
+}
+
+// newFakeChain builds a chain of the given length, branch makes the hashes differ from other branches
+func newFakeChain(length int, branch string) *fakeChain {
+	chain := &fakeChain{logs: make(map[uint64][]ethTypes.Log)}
+	chain.extend(length, branch)
+	return chain
+}
+
+// fork returns a copy of the chain up to and including the block, extended with a new branch
+func (c *fakeChain) fork(number uint64, length int, branch string) *fakeChain {
+	forked := &fakeChain{headers: append([]*ethTypes.Header{}, c.headers[:number+1]...), logs: make(map[uint64][]ethTypes.Log)}
+	for n, logs := range c.logs {
+		if n <= number {
+			forked.logs[n] = logs
+		}
+	}
+	forked.extend(length, branch)
+	return forked
+}
+
+func (c *fakeChain) extend(length int, branch string) {
+	for i := 0; i < length; i++ {
+		header := &ethTypes.Header{Number: big.NewInt(int64(len(c.headers))), Difficulty: big.NewInt(1), Extra: []byte(branch)}
+		if len(c.headers) != 0 {
+			header.ParentHash = c.headers[len(c.headers)-1].Hash()
+		}
+		c.headers = append(c.headers, header)
+		c.logs[header.Number.Uint64()] = []ethTypes.Log{{BlockNumber: header.Number.Uint64(), BlockHash: header.Hash()}}
+	}
+}
+
+func (c *fakeChain) HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error) {
+	if number == nil {
+		return c.headers[len(c.headers)-1], nil
+	}
+	if number.Uint64() >= uint64(len(c.headers)) {
+		return nil, ethereum.NotFound
+	}
+	return c.headers[number.Uint64()], nil
+}
+
+func (c *fakeChain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) (logs []ethTypes.Log, err error) {
+	for n := q.FromBlock.Uint64(); n <= q.ToBlock.Uint64(); n++ {
+		logs = append(logs, c.logs[n]...)
+	}
+	if c.maxLogs != 0 && len(logs) > c.maxLogs {
+		return nil, fmt.Errorf("query returned more than %v results", c.maxLogs)
+	}
+	return logs, nil
+}
+
+// sync returns the synced blocks from the given range of the chain, newest first
+func (c *fakeChain) sync(from, to uint64) (synced []core.SyncedBlock) {
+	for n := to + 1; n > from; n-- {
+		synced = append(synced, NewSyncedBlock(*c.headers[n-1], n-1, n-1))
+	}
+	return synced
+}
+
+func TestNoReorg(t *testing.T) {
+	chain := newFakeChain(10, "a")
+	synced := chain.sync(0, 6)
+
+	reorged, err := isReorged(context.Background(), chain, synced[0])
+	require.NoError(t, err)
+	require.False(t, reorged)
+
+	// the chain just grew
+	chain.extend(5, "a")
+	reorged, err = isReorged(context.Background(), chain, synced[0])
+	require.NoError(t, err)
+	require.False(t, reorged)
+}
+
+func TestReorgFindsForkPoint(t *testing.T) {
+	chain := newFakeChain(10, "a")
+	synced := chain.sync(0, 8)
+
+	forked := chain.fork(5, 6, "b")
+	reorged, err := isReorged(context.Background(), forked, synced[0])
+	require.NoError(t, err)
+	require.True(t, reorged)
+
+	fork, err := findForkPoint(context.Background(), forked, synced)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), fork.Number)
+	require.Equal(t, forked.headers[5].Hash().Hex(), fork.Hash)
+	require.Equal(t, uint64(5), fork.JournalSeq)
+
+	// re-syncing from the fork point picks up the logs of the new branch
+	logs, err := forked.FilterLogs(context.Background(), ethereum.FilterQuery{FromBlock: big.NewInt(6), ToBlock: big.NewInt(8)})
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	for _, vLog := range logs {
+		require.Equal(t, forked.headers[vLog.BlockNumber].Hash(), vLog.BlockHash)
+		require.NotEqual(t, chain.headers[vLog.BlockNumber].Hash(), vLog.BlockHash)
+	}
+}
+
+func TestReorgOfLastSyncedBlock(t *testing.T) {
+	chain := newFakeChain(10, "a")
+	synced := chain.sync(3, 9)
+
+	forked := chain.fork(8, 2, "b")
+	reorged, err := isReorged(context.Background(), forked, synced[0])
+	require.NoError(t, err)
+	require.True(t, reorged)
+
+	fork, err := findForkPoint(context.Background(), forked, synced)
+	require.NoError(t, err)
+	require.Equal(t, uint64(8), fork.Number)
+}
+
+func TestReorgTooDeep(t *testing.T) {
+	chain := newFakeChain(10, "a")
+	synced := chain.sync(4, 8)
+
+	forked := chain.fork(2, 10, "b")
+	_, err := findForkPoint(context.Background(), forked, synced)
+	require.Equal(t, ErrReorgTooDeep, err)
+}
+
+func TestSyncedBlockLinksToParent(t *testing.T) {
+	chain := newFakeChain(3, "a")
+	synced := chain.sync(1, 2)
+	require.Equal(t, synced[1].Hash, synced[0].ParentHash)
+	require.Equal(t, ethCmn.Hash{}.Hex(), chain.sync(0, 0)[0].ParentHash, "genesis has no parent")
+}
+
+// fakeReorgState is the local state as far as reorgs go, every block synced adds a journal entry
+// and every other block commits a batch
+type fakeReorgState struct {
+	journalSeq  uint64
+	lastBatchID uint64
+
+	// oldest first
+	synced    []core.SyncedBlock
+	unwoundTo []core.SyncedBlock
+}
+
+func (s *fakeReorgState) LastJournalSeq() (uint64, error) {
+	return s.journalSeq, nil
+}
+
+func (s *fakeReorgState) GetLastCommittedBatchID() (uint64, error) {
+	return s.lastBatchID, nil
+}
+
+func (s *fakeReorgState) AddSyncedBlock(block core.SyncedBlock, keep uint64) error {
+	s.synced = append(s.synced, block)
+	for len(s.synced) != 0 && block.Number > keep && s.synced[0].Number < block.Number-keep {
+		s.synced = s.synced[1:]
+	}
+	return nil
+}
+
+func (s *fakeReorgState) PruneJournal() error {
+	return nil
+}
+
+func (s *fakeReorgState) GetSyncedBlocks(limit uint64) (blocks []core.SyncedBlock, err error) {
+	for i := len(s.synced) - 1; i >= 0 && uint64(len(blocks)) < limit; i-- {
+		blocks = append(blocks, s.synced[i])
+	}
+	return blocks, nil
+}
+
+func (s *fakeReorgState) UnwindTo(block core.SyncedBlock) error {
+	s.journalSeq = block.JournalSeq
+	s.lastBatchID = block.LastBatchID
+	for len(s.synced) != 0 && s.synced[len(s.synced)-1].Number > block.Number {
+		s.synced = s.synced[:len(s.synced)-1]
+	}
+	s.unwoundTo = append(s.unwoundTo, block)
+	return nil
+}
+
+// syncBlocks applies the blocks of the chain in the range and records them as synced
+func (s *fakeReorgState) syncBlocks(t *testing.T, chain *fakeChain, from, to uint64) {
+	for n := from; n <= to; n++ {
+		s.journalSeq++
+		if n%2 == 0 {
+			s.lastBatchID++
+		}
+		require.NoError(t, recordSyncedBlock(s, *chain.headers[n]))
+	}
+}
+
+// countingLocker counts the times it was locked and fails on a lock it doesn't hold being released
+type countingLocker struct {
+	locks  int
+	locked bool
+}
+
+func (l *countingLocker) Lock() {
+	l.locks++
+	l.locked = true
+}
+
+func (l *countingLocker) Unlock() {
+	if !l.locked {
+		panic("unlock of unlocked locker")
+	}
+	l.locked = false
+}
+
+func TestUnwindAndResync(t *testing.T) {
+	chain := newFakeChain(12, "a")
+	state := &fakeReorgState{}
+	lock := &countingLocker{}
+	state.syncBlocks(t, chain, 1, 9)
+
+	fork, err := unwindIfReorged(context.Background(), chain, state, lock)
+	require.NoError(t, err)
+	require.Nil(t, fork)
+	require.Zero(t, lock.locks)
+
+	// blocks after 6 are replaced, the state goes back to where it was once block 6 was synced
+	forked := chain.fork(6, 6, "b")
+	fork, err = unwindIfReorged(context.Background(), forked, state, lock)
+	require.NoError(t, err)
+	require.NotNil(t, fork)
+	require.Equal(t, uint64(6), fork.Number)
+	require.Equal(t, forked.headers[6].Hash().Hex(), fork.Hash)
+	require.Equal(t, []core.SyncedBlock{*fork}, state.unwoundTo)
+	require.Equal(t, 1, lock.locks)
+	require.False(t, lock.locked)
+	require.Equal(t, uint64(6), state.journalSeq)
+	require.Equal(t, uint64(3), state.lastBatchID)
+	synced, err := state.GetSyncedBlocks(ReorgDepth)
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), synced[0].Number)
+
+	// re-syncing from the fork point follows the new branch, which isn't seen as a reorg again
+	state.syncBlocks(t, forked, fork.Number+1, 11)
+	fork, err = unwindIfReorged(context.Background(), forked, state, lock)
+	require.NoError(t, err)
+	require.Nil(t, fork)
+	require.Len(t, state.unwoundTo, 1)
+	synced, err = state.GetSyncedBlocks(ReorgDepth)
+	require.NoError(t, err)
+	require.Len(t, synced, 11)
+	for _, block := range synced {
+		require.Equal(t, forked.headers[block.Number].Hash().Hex(), block.Hash)
+	}
+	require.Equal(t, uint64(11), state.journalSeq)
+	require.Equal(t, uint64(5), state.lastBatchID)
+
+	// and a reorg of the new branch unwinds again
+	reforked := forked.fork(9, 4, "c")
+	fork, err = unwindIfReorged(context.Background(), reforked, state, lock)
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), fork.Number)
+	require.Equal(t, forked.headers[9].Hash().Hex(), fork.Hash)
+	require.Equal(t, uint64(9), state.journalSeq)
+}
+
+func TestUnwindReorgTooDeep(t *testing.T) {
+	chain := newFakeChain(ReorgDepth+20, "a")
+	state := &fakeReorgState{}
+	lock := &countingLocker{}
+	state.syncBlocks(t, chain, 1, ReorgDepth+15)
+
+	// the synced blocks the fork point was among were forgotten
+	forked := chain.fork(5, ReorgDepth+20, "b")
+	fork, err := unwindIfReorged(context.Background(), forked, state, lock)
+	require.Equal(t, ErrReorgTooDeep, err)
+	require.Nil(t, fork)
+	require.Empty(t, state.unwoundTo)
+	require.Zero(t, lock.locks)
+}
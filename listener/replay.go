@@ -0,0 +1,184 @@
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// Recording is the main chain data read by a replay, so that the replay can be run again without the main chain
+type Recording struct {
+	// last block replayed
+	ToBlock uint64 `json:"toBlock"`
+
+	// headers of the blocks the replay recorded as synced
+	Headers map[uint64]*ethTypes.Header `json:"headers"`
+
+	// logs of the logger contract, in the order they were emitted
+	Logs []ethTypes.Log `json:"logs"`
+
+	// txs of the batches, by the main chain tx which submitted them
+	Calldata map[ethCmn.Hash][][]byte `json:"calldata"`
+}
+
+// NewRecording creates an empty recording
+func NewRecording() *Recording {
+	return &Recording{
+		Headers:  make(map[uint64]*ethTypes.Header),
+		Calldata: make(map[ethCmn.Hash][][]byte),
+	}
+}
+
+// ReadRecording reads a recording from the file
+func ReadRecording(path string) (*Recording, error) {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	recording := NewRecording()
+	if err := json.Unmarshal(bz, recording); err != nil {
+		return nil, err
+	}
+	return recording, nil
+}
+
+// Write writes the recording to the file
+func (r *Recording) Write(path string) error {
+	bz, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, bz, 0644)
+}
+
+// HeaderByNumber returns the recorded header, the one of the last block replayed if number is nil
+// Blocks without a recorded header get a header carrying just the number, their hash won't match the main chain
+func (r *Recording) HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error) {
+	if number == nil {
+		number = new(big.Int).SetUint64(r.ToBlock)
+	}
+	if header, ok := r.Headers[number.Uint64()]; ok {
+		return header, nil
+	}
+	return &ethTypes.Header{Number: new(big.Int).Set(number), Difficulty: big.NewInt(0)}, nil
+}
+
+// FilterLogs returns the recorded logs of the addresses in the block range
+func (r *Recording) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]ethTypes.Log, error) {
+	var logs []ethTypes.Log
+	for _, vLog := range r.Logs {
+		if q.FromBlock != nil && vLog.BlockNumber < q.FromBlock.Uint64() {
+			continue
+		}
+		if q.ToBlock != nil && vLog.BlockNumber > q.ToBlock.Uint64() {
+			continue
+		}
+		if len(q.Addresses) != 0 && !containsAddress(q.Addresses, vLog.Address) {
+			continue
+		}
+		logs = append(logs, vLog)
+	}
+	return logs, nil
+}
+
+// FetchBatchInputData returns the recorded txs of the batch submitted by the tx
+func (r *Recording) FetchBatchInputData(txHash ethCmn.Hash) ([][]byte, error) {
+	txs, ok := r.Calldata[txHash]
+	if !ok {
+		return nil, fmt.Errorf("calldata of tx %v wasn't recorded", txHash.Hex())
+	}
+	return txs, nil
+}
+
+func containsAddress(addresses []ethCmn.Address, address ethCmn.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+// Recorder reads from the main chain, recording what was read
+type Recorder struct {
+	chain   ChainReader
+	fetcher CalldataFetcher
+
+	mu        sync.Mutex
+	recording *Recording
+}
+
+// NewRecorder creates a recorder reading from the chain and fetcher
+func NewRecorder(chain ChainReader, fetcher CalldataFetcher) *Recorder {
+	return &Recorder{chain: chain, fetcher: fetcher, recording: NewRecording()}
+}
+
+// Recording returns what was read so far
+func (r *Recorder) Recording() *Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.recording
+}
+
+// HeaderByNumber reads the header from the main chain and records it
+func (r *Recorder) HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error) {
+	header, err := r.chain.HeaderByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recording.Headers[header.Number.Uint64()] = header
+	return header, nil
+}
+
+// FilterLogs reads the logs from the main chain and records them
+func (r *Recorder) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]ethTypes.Log, error) {
+	logs, err := r.chain.FilterLogs(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recording.Logs = append(r.recording.Logs, logs...)
+	return logs, nil
+}
+
+// FetchBatchInputData fetches the txs of the batch from the main chain and records them
+func (r *Recorder) FetchBatchInputData(txHash ethCmn.Hash) ([][]byte, error) {
+	txs, err := r.fetcher.FetchBatchInputData(txHash)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recording.Calldata[txHash] = txs
+	return txs, nil
+}
+
+// NewReplayer creates a syncer applying the events read from the chain, with the batch txs from the fetcher
+// It never sends anything to the main chain
+func NewReplayer(chain ChainReader, fetcher CalldataFetcher) *Syncer {
+	replayer := NewSyncer()
+	replayer.replaying = true
+	replayer.chain = chain
+	replayer.prefetcher = NewPrefetcher(fetcher, PrefetchWorkers)
+	return replayer
+}
+
+// Replay applies the events from the block after the last one recorded up to the target block
+// The first event which fails stops the replay, it isn't retried or moved to the dead letters
+func (s *Syncer) Replay(to uint64) error {
+	syncStatus, err := s.DBInstance.GetSyncStatus()
+	if err != nil {
+		return err
+	}
+	return s.applyRange(syncStatus.LastEthBlockRecorded+1, to, func() bool { return true })
+}
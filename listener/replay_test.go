@@ -0,0 +1,91 @@
+package listener
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingReplaysWhatWasRead(t *testing.T) {
+	chain := newFakeChain(20, "a")
+	address := ethCmn.HexToAddress("0x1")
+	for n, logs := range chain.logs {
+		for i := range logs {
+			logs[i].Address = address
+			logs[i].Topics = []ethCmn.Hash{ethCmn.BigToHash(big.NewInt(int64(n)))}
+			logs[i].TxHash = ethCmn.BigToHash(big.NewInt(int64(n + 100)))
+		}
+	}
+	fetcher := &slowFetcher{fetched: make(map[ethCmn.Hash]int)}
+	recorder := NewRecorder(chain, fetcher)
+
+	// read the way the syncer does
+	sizer := NewChunkSizer(4, MinChunkSize, MaxChunkSize)
+	var read []uint64
+	for from := uint64(5); from <= 15; {
+		logs, end, err := fetchChunk(context.Background(), recorder, sizer, address, from, 15)
+		require.NoError(t, err)
+		_, err = recorder.HeaderByNumber(context.Background(), new(big.Int).SetUint64(end))
+		require.NoError(t, err)
+		for _, vLog := range logs {
+			_, err := recorder.FetchBatchInputData(vLog.TxHash)
+			require.NoError(t, err)
+			read = append(read, vLog.BlockNumber)
+		}
+		from = end + 1
+	}
+	require.Len(t, read, 11)
+
+	dir, err := ioutil.TempDir("", "recording")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "recording.json")
+	recording := recorder.Recording()
+	recording.ToBlock = 15
+	require.NoError(t, recording.Write(path))
+	replayed, err := ReadRecording(path)
+	require.NoError(t, err)
+
+	logs, err := replayed.FilterLogs(context.Background(), ethereum.FilterQuery{
+		FromBlock: big.NewInt(7),
+		ToBlock:   big.NewInt(9),
+		Addresses: []ethCmn.Address{address},
+	})
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	for i, vLog := range logs {
+		require.Equal(t, chain.logs[uint64(7+i)][0].TxHash, vLog.TxHash)
+		txs, err := replayed.FetchBatchInputData(vLog.TxHash)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{vLog.TxHash.Bytes()}, txs)
+	}
+
+	// logs of other contracts aren't returned
+	logs, err = replayed.FilterLogs(context.Background(), ethereum.FilterQuery{
+		FromBlock: big.NewInt(5),
+		ToBlock:   big.NewInt(15),
+		Addresses: []ethCmn.Address{ethCmn.HexToAddress("0x2")},
+	})
+	require.NoError(t, err)
+	require.Empty(t, logs)
+
+	// the last block replayed has its header recorded
+	head, err := replayed.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, chain.headers[15].Hash(), head.Hash())
+
+	// blocks without a recorded header only carry the number
+	header, err := replayed.HeaderByNumber(context.Background(), big.NewInt(6))
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), header.Number.Uint64())
+
+	_, err = replayed.FetchBatchInputData(ethCmn.HexToHash("0x3"))
+	require.Error(t, err)
+}
@@ -2,12 +2,16 @@ package listener
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/big"
 	"sync"
 	"time"
 
 	"github.com/BOPR/common"
 	"github.com/BOPR/config"
 
+	"github.com/BOPR/contracts/logger"
 	"github.com/BOPR/core"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -29,8 +33,46 @@ type Syncer struct {
 	// contract caller to interact with contracts
 	loadedBazooka core.Bazooka
 
+	// main chain headers and logs
+	chain ChainReader
+
+	// number of blocks logs are fetched for at once
+	chunkSizer *ChunkSizer
+
+	// fetches the calldata of the batches of a chunk ahead of applying them
+	prefetcher *Prefetcher
+
+	// replaying past events, nothing is sent to the main chain
+	replaying bool
+
+	// event the sync is stuck on, nil if the last event was applied
+	failure *eventFailure
+
+	// set once a batch root mismatched and the policy is to halt, or an event which can't be skipped
+	// kept failing, nothing is applied after that
+	halted bool
+
+	// set by the event being applied, the deposit finalisation is sent once the event is committed
+	finaliseDeposits bool
+
+	// main chain tx whose deposits were finalised before its batch was seen, the batch root is checked against them
+	depositsFinalisedIn ethCmn.Hash
+
+	// batch the local state was last resynced for, nil if it never was
+	resyncedBatch *uint64
+
+	// when the governance params were last read
+	governanceSyncedAt time.Time
+
+	// sync state and latest head seen, see the SYNC_STATE constants
+	stateMu   sync.Mutex
+	syncState uint64
+	headBlock uint64
+
 	// header channel
 	HeaderChannel chan *ethTypes.Header
+	// delivers new heads to the header channel
+	headSource *HeadSource
 	// cancel function for poll/subscription
 	cancelSubscription context.CancelFunc
 
@@ -41,7 +83,15 @@ type Syncer struct {
 	wg sync.WaitGroup
 }
 
-func NewSyncer() Syncer {
+// eventFailure tracks the attempts at applying an event which keeps failing
+type eventFailure struct {
+	blockNumber uint64
+	logIndex    uint64
+	attempts    uint64
+	retryAt     time.Time
+}
+
+func NewSyncer() *Syncer {
 	// create logger
 	logger := common.Logger.With("module", SyncerServiceName)
 
@@ -60,13 +110,17 @@ func NewSyncer() Syncer {
 	// abis for all the events
 	syncerService.abis = abis
 	syncerService.loadedBazooka = loadedBazooka
+	syncerService.chain = loadedBazooka.EthClient
+	syncerService.chunkSizer = NewChunkSizer(DefaultChunkSize, MinChunkSize, MaxChunkSize)
+	syncerService.prefetcher = NewPrefetcher(&syncerService.loadedBazooka, PrefetchWorkers)
 	syncerService.HeaderChannel = make(chan *ethTypes.Header)
+	syncerService.headSource = NewHeadSource(loadedBazooka.EthClient, syncerService.HeaderChannel, config.GlobalCfg.PollingInterval, MinResubscribeBackoff, MaxResubscribeBackoff, logger)
 	syncerService.DBInstance, err = core.NewDB()
 	if err != nil {
 		panic(err)
 	}
 
-	return *syncerService
+	return syncerService
 }
 
 // OnStart starts new block subscription
@@ -77,6 +131,18 @@ func (s *Syncer) OnStart() error {
 		return err
 	}
 
+	// the recorded state is from before the restart, the services started after the syncer
+	// mustn't act on it until the syncer has caught up again
+	syncStatus, err := s.DBInstance.GetSyncStatus()
+	if err != nil {
+		return err
+	}
+	s.headBlock = syncStatus.HeadBlock
+	s.syncState = core.SYNC_STATE_CATCHING_UP
+	if err := s.DBInstance.UpdateSyncState(s.syncState, s.headBlock); err != nil {
+		return err
+	}
+
 	// create cancellable context
 	ctx, cancelSubscription := context.WithCancel(context.Background())
 	s.cancelSubscription = cancelSubscription
@@ -88,15 +154,8 @@ func (s *Syncer) OnStart() error {
 	// start header process
 	go s.startHeaderProcess(headerCtx)
 
-	// subscribe to new head
-	subscription, err := s.loadedBazooka.EthClient.SubscribeNewHead(ctx, s.HeaderChannel)
-	if err != nil {
-		// start go routine to poll for new header using client object
-		go s.startPolling(ctx, config.GlobalCfg.PollingInterval)
-	} else {
-		// start go routine to listen new header using subscription
-		go s.startSubscription(ctx, subscription)
-	}
+	// subscribe to new heads, polling while the subscription is down
+	go s.headSource.Run(ctx)
 	s.Logger.Info("Starting syncer", "LoggingContract", config.GlobalCfg.LoggerAddress)
 	return nil
 }
@@ -117,124 +176,448 @@ func (s *Syncer) OnStop() {
 
 // startHeaderProcess starts header process when they get new header
 func (s *Syncer) startHeaderProcess(ctx context.Context) {
+	ticker := time.NewTicker(config.GlobalCfg.PollingInterval)
+	defer ticker.Stop()
 	for {
 		select {
 		case newHeader := <-s.HeaderChannel:
 			s.processHeader(*newHeader)
-		case <-ctx.Done():
-			return
-		}
-	}
-}
-
-// startPolling starts polling
-func (s *Syncer) startPolling(ctx context.Context, pollInterval time.Duration) {
-	// How often to fire the passed in function in second
-	interval := pollInterval
-
-	// Setup the ticket and the channel to signal
-	// the ending of the interval
-	ticker := time.NewTicker(interval)
-
-	// start listening
-	for {
-		select {
 		case <-ticker.C:
-			s.Logger.Info("Searching for new logs...")
-			header, err := s.loadedBazooka.EthClient.HeaderByNumber(ctx, nil)
-			if err == nil && header != nil {
-				// send data to channel
-				s.HeaderChannel <- header
+			// no new heads for a while, we are falling behind without knowing it
+			health := s.headSource.Health()
+			if !health.LastHeadAt.IsZero() && time.Since(health.LastHeadAt) > StaleHeadTimeout {
+				s.Logger.Error("No new heads received", "since", health.LastHeadAt, "lastHead", health.LastHead)
+				s.setSyncState(core.SYNC_STATE_DEGRADED)
 			}
 		case <-ctx.Done():
-			ticker.Stop()
 			return
 		}
 	}
 }
 
-func (s *Syncer) startSubscription(ctx context.Context, subscription ethereum.Subscription) {
-	for {
-		select {
-		case err := <-subscription.Err():
-			// stop service
-			s.Logger.Error("Error while subscribing new blocks", "error", err)
-			s.Stop()
-
-			// cancel subscription
-			s.cancelSubscription()
-			return
-		case <-ctx.Done():
-			return
-		}
+// syncGovernanceParams reads the governance params again once the sync interval has passed since they last were
+// The governance contract emits no event when they change, a failed read is retried on the next header
+func (s *Syncer) syncGovernanceParams(now time.Time) {
+	if now.Sub(s.governanceSyncedAt) < config.GlobalCfg.GetGovernanceSyncInterval() {
+		return
 	}
+	if _, err := s.loadedBazooka.SyncGovernanceParams(s.DBInstance); err != nil {
+		s.Logger.Error("Unable to sync governance params", "error", err)
+		return
+	}
+	s.governanceSyncedAt = now
+}
+
+// HeadSourceHealth returns the state of the subscription to new heads
+func (s *Syncer) HeadSourceHealth() HeadSourceHealth {
+	return s.headSource.Health()
 }
 
 func (s *Syncer) processHeader(header ethTypes.Header) {
+	// events of the previous header need to be applied first
+	s.wg.Wait()
+	s.setHeadBlock(header.Number.Uint64())
+
+	s.syncGovernanceParams(time.Now())
+
+	// unwind to the fork point first so that we re-sync from there
+	if err := s.handleReorg(); err != nil {
+		s.Logger.Error("Unable to handle reorg", "error", err)
+		s.setSyncState(core.SYNC_STATE_DEGRADED)
+		return
+	}
+
 	syncStatus, err := s.DBInstance.GetSyncStatus()
 	if err != nil {
 		s.Logger.Error("Unable to fetch listener log", "error", err)
 		return
 	}
 	s.Logger.Info("Sync status", "LastLogIndexed", syncStatus.LastEthBlockBigInt().String(), "LastBatch", syncStatus.LastBatchRecorded)
-	if header.Number.Uint64() <= syncStatus.LastEthBlockBigInt().Uint64() {
-		s.Logger.Error("No need to sync more events", "currentEthBlock", header.Number.String(), "lastSyncedBlock", syncStatus.LastEthBlockBigInt().String())
+
+	// only confirmed blocks are applied, events in the blocks after are exposed as unsafe
+	if header.Number.Uint64() <= config.GlobalCfg.ConfirmationBlocks {
+		s.Logger.Debug("No confirmed blocks yet", "currentEthBlock", header.Number.String())
+		return
+	}
+	confirmedHeader, err := s.chain.HeaderByNumber(context.Background(), new(big.Int).Sub(header.Number, new(big.Int).SetUint64(config.GlobalCfg.ConfirmationBlocks)))
+	if err != nil {
+		s.Logger.Error("Unable to fetch confirmed header", "error", err)
+		s.setSyncState(core.SYNC_STATE_DEGRADED)
 		return
 	}
-	// we need to filter only by logger contracts
-	// since all events are emitted by it
-	query := ethereum.FilterQuery{
-		FromBlock: syncStatus.LastEthBlockBigInt(),
-		ToBlock:   header.Number,
-		Addresses: []ethCmn.Address{
-			ethCmn.HexToAddress(config.GlobalCfg.LoggerAddress),
-		},
+	if err := s.updateUnsafeEvents(confirmedHeader.Number, header.Number); err != nil {
+		s.Logger.Error("Unable to update unsafe events", "error", err)
 	}
 
-	// get all logs
-	logs, err := s.loadedBazooka.EthClient.FilterLogs(context.Background(), query)
-	if err != nil {
-		s.Logger.Error("Error while filtering logs from syncer", "error", err)
+	var lag uint64
+	if confirmedHeader.Number.Uint64() > syncStatus.LastEthBlockRecorded {
+		lag = confirmedHeader.Number.Uint64() - syncStatus.LastEthBlockRecorded
+	}
+	s.setSyncState(NextSyncState(lag, s.failure != nil || s.halted))
+	if s.halted {
+		s.Logger.Error("Syncer halted, restart the node once the state is fixed")
+		return
+	}
+
+	if confirmedHeader.Number.Uint64() <= syncStatus.LastEthBlockBigInt().Uint64() {
+		s.Logger.Error("No need to sync more events", "confirmedEthBlock", confirmedHeader.Number.String(), "lastSyncedBlock", syncStatus.LastEthBlockBigInt().String())
+		return
+	}
+	if s.failure != nil && time.Now().Before(s.failure.retryAt) {
+		s.Logger.Debug("Backing off after a failed event", "block", s.failure.blockNumber, "logIndex", s.failure.logIndex, "retryAt", s.failure.retryAt)
 		return
-	} else if len(logs) > 0 {
-		s.Logger.Debug("New logs found", "numberOfLogs", len(logs))
 	}
-	s.wg.Wait()
 	s.wg.Add(1)
-	go s.processEvents(logs, header)
+	go s.syncRange(syncStatus.LastEthBlockRecorded+1, confirmedHeader.Number.Uint64())
 }
 
-func (s *Syncer) processEvents(logs []ethTypes.Log, header ethTypes.Header) {
+// syncRange applies the events from the given block up to the last block, see applyRange
+func (s *Syncer) syncRange(from, to uint64) {
 	defer s.wg.Done()
-	for _, vLog := range logs {
-		topic := vLog.Topics[0].Bytes()
-		for _, abiObject := range s.abis {
-			selectedEvent := EventByID(&abiObject, topic)
-			if selectedEvent != nil {
-				s.Logger.Debug("Found an event", "name", selectedEvent.Name)
-				switch selectedEvent.Name {
-				case "RegisteredToken":
-					s.processRegisteredToken(selectedEvent.Name, &abiObject, &vLog)
-				case "NewBatch":
-					s.processNewBatch(selectedEvent.Name, &abiObject, &vLog)
-				case "NewPubkeyAdded":
-					s.processNewPubkeyAddition(selectedEvent.Name, &abiObject, &vLog)
-				case "DepositQueued":
-					s.processDepositQueued(selectedEvent.Name, &abiObject, &vLog)
-				case "DepositSubTreeReady":
-					s.processDepositSubtreeCreated(selectedEvent.Name, &abiObject, &vLog)
-				case "DepositsFinalised":
-					s.processDepositFinalised(selectedEvent.Name, &abiObject, &vLog)
-				default:
-					s.Logger.Debug("Unable to match with any event", "event", selectedEvent.Name)
-				}
-			} else {
-				s.Logger.Info("Unable to find an event", "topic", topic)
+	if err := s.applyRange(from, to, s.IsRunning); err != nil {
+		s.setSyncState(core.SYNC_STATE_DEGRADED)
+		return
+	}
+	if s.IsRunning() {
+		s.setSyncState(core.SYNC_STATE_SYNCED)
+		// subtrees skipped while catching up
+		if !s.replaying {
+			s.finaliseReadyDeposits()
+		}
+	}
+}
+
+// applyRange applies the events from the given block up to the last block, a chunk of blocks at a time,
+// for as long as keepGoing returns true
+// Sync status is updated after every chunk so the sync resumes from the last chunk applied
+func (s *Syncer) applyRange(from, to uint64, keepGoing func() bool) error {
+	for from <= to && keepGoing() {
+		// we need to filter only by logger contracts
+		// since all events are emitted by it
+		logs, end, err := fetchChunk(context.Background(), s.chain, s.chunkSizer, ethCmn.HexToAddress(config.GlobalCfg.LoggerAddress), from, to)
+		if err != nil {
+			s.Logger.Error("Error while filtering logs from syncer", "from", from, "chunkSize", s.chunkSizer.Size(), "error", err)
+			return err
+		} else if len(logs) > 0 {
+			s.Logger.Debug("New logs found", "numberOfLogs", len(logs), "from", from, "to", end)
+		}
+		header, err := s.chain.HeaderByNumber(context.Background(), new(big.Int).SetUint64(end))
+		if err != nil {
+			s.Logger.Error("Unable to fetch header", "number", end, "error", err)
+			return err
+		}
+		s.prefetcher.Prefetch(s.batchCalldataTxs(logs))
+		err = s.processEvents(logs, *header)
+		s.prefetcher.Clear()
+		if err != nil {
+			s.Logger.Error("Unable to apply events", "from", from, "to", end, "error", err)
+			return err
+		}
+		from = end + 1
+	}
+	return nil
+}
+
+// batchCalldataTxs returns the main chain txs which submitted the batches with txs among the logs, in order
+func (s *Syncer) batchCalldataTxs(logs []ethTypes.Log) (txHashes []ethCmn.Hash) {
+	for i := range logs {
+		for j := range s.abis {
+			selectedEvent := EventByID(&s.abis[j], logs[i].Topics[0].Bytes())
+			if selectedEvent == nil || selectedEvent.Name != "NewBatch" {
+				continue
+			}
+			event := new(logger.LoggerNewBatch)
+			// the processor reports logs which can't be unpacked
+			if err := common.UnpackLog(&s.abis[j], event, selectedEvent.Name, &logs[i]); err != nil {
+				continue
+			}
+			if core.ByteArray(event.Txroot).String() != ZEROROOT {
+				txHashes = append(txHashes, logs[i].TxHash)
 			}
 		}
 	}
-	err := s.DBInstance.UpdateSyncStatusWithBlockNumber(header.Number.Uint64())
+	return txHashes
+}
+
+// NextSyncState returns the state of a syncer lagging the given number of blocks behind the
+// confirmed head, stuck is true if it is backing off after a failed event
+func NextSyncState(lag uint64, stuck bool) uint64 {
+	if stuck {
+		return core.SYNC_STATE_DEGRADED
+	}
+	if lag > MaxSyncedLag {
+		return core.SYNC_STATE_CATCHING_UP
+	}
+	return core.SYNC_STATE_SYNCED
+}
+
+// setSyncState records the sync state, logging changes
+func (s *Syncer) setSyncState(state uint64) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if state != s.syncState {
+		s.Logger.Info("Sync state changed", "from", core.SyncStateName(s.syncState), "to", core.SyncStateName(state), "head", s.headBlock)
+	}
+	s.syncState = state
+	if err := s.DBInstance.UpdateSyncState(state, s.headBlock); err != nil {
+		s.Logger.Error("Unable to record sync state", "error", err)
+	}
+}
+
+func (s *Syncer) setHeadBlock(head uint64) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.headBlock = head
+}
+
+// updateUnsafeEvents replaces the unsafe events with the ones emitted after the confirmed block, up to the head
+func (s *Syncer) updateUnsafeEvents(confirmed, head *big.Int) error {
+	var logs []ethTypes.Log
+	if head.Cmp(confirmed) > 0 {
+		query := ethereum.FilterQuery{
+			FromBlock: new(big.Int).Add(confirmed, big.NewInt(1)),
+			ToBlock:   head,
+			Addresses: []ethCmn.Address{
+				ethCmn.HexToAddress(config.GlobalCfg.LoggerAddress),
+			},
+		}
+		var err error
+		logs, err = s.chain.FilterLogs(context.Background(), query)
+		if err != nil {
+			return err
+		}
+	}
+	events, err := s.decodeUnsafeEvents(logs)
+	if err != nil {
+		return err
+	}
+	return s.DBInstance.ReplaceUnsafeEvents(events)
+}
+
+// processEvents applies the events and records the header as the last synced block
+// Stops at the first event which fails, unless it failed too many times and was moved to the dead letters
+func (s *Syncer) processEvents(logs []ethTypes.Log, header ethTypes.Header) error {
+	syncStatus, err := s.DBInstance.GetSyncStatus()
 	if err != nil {
-		s.Logger.Error("Unable to update listener log", "error", err)
+		return err
+	}
+	for i := range logs {
+		vLog := &logs[i]
+		// applied before an earlier attempt at the range failed
+		if syncStatus.IsApplied(vLog.BlockNumber, uint64(vLog.Index)) {
+			continue
+		}
+		if err := s.processLog(vLog, true); err != nil {
+			var mismatch *RootMismatchError
+			if errors.As(err, &mismatch) {
+				return s.handleRootMismatch(mismatch)
+			}
+			if s.replaying || !s.handleFailure(vLog, err) {
+				return err
+			}
+			// moved to the dead letters, the sync goes on past it
+			if err := s.DBInstance.UpdateSyncCursor(vLog.BlockNumber, uint64(vLog.Index)); err != nil {
+				return err
+			}
+		}
+		s.failure = nil
+	}
+	err = s.DBInstance.UpdateSyncStatusWithBlockNumber(header.Number.Uint64())
+	if err != nil {
+		return err
+	}
+
+	// batches past their finalisation block can't be disputed anymore
+	finalised, err := s.DBInstance.FinaliseBatches(header.Number.Uint64())
+	if err != nil {
+		s.Logger.Error("Unable to finalise batches", "error", err)
+	} else if len(finalised) > 0 {
+		s.Logger.Info("Batches finalised", "batches", finalised)
+	}
+
+	if err := recordSyncedBlock(&s.DBInstance, header); err != nil {
+		s.Logger.Error("Unable to record synced block", "error", err)
+	}
+	return nil
+}
+
+// processLog applies the event of the log within a single DB transaction, logs of unknown events are ignored
+// Nothing the event changed is kept if it fails, so that it can be applied again
+// The sync cursor is moved past the log along with the changes if updateCursor is set
+func (s *Syncer) processLog(vLog *ethTypes.Log, updateCursor bool) error {
+	s.finaliseDeposits = false
+	err := func() error {
+		core.StateLock.Lock()
+		defer core.StateLock.Unlock()
+		return s.DBInstance.Transaction(func(tx core.DB) error {
+			if err := s.applyLog(tx, vLog); err != nil {
+				return err
+			}
+			if !updateCursor {
+				return nil
+			}
+			return tx.UpdateSyncCursor(vLog.BlockNumber, uint64(vLog.Index))
+		})
+	}()
+	if err != nil {
+		return err
+	}
+
+	// main chain txs are only sent once what led to them is committed
+	if s.finaliseDeposits {
+		s.finaliseDeposits = false
+		s.finaliseReadyDeposits()
+	}
+	return nil
+}
+
+// finaliseReadyDeposits sends the finalisation of a deposit subtree which is ready, unless one is in flight already
+// Subtrees are finalised one at a time as each finalisation fills the next empty subtree, the next one is sent
+// once the previous one landed. Failing to send is only logged, the subtree stays ready for the next attempt
+func (s *Syncer) finaliseReadyDeposits() {
+	ready, err := s.DBInstance.HasReadyDepositSubTree()
+	if err != nil {
+		s.Logger.Error("Unable to check for ready deposit subtrees", "error", err)
+		return
 	}
+	inFlight, err := s.DBInstance.HasDepositFinalisationInFlight()
+	if err != nil {
+		s.Logger.Error("Unable to check for deposit finalisations in flight", "error", err)
+		return
+	}
+	if !ready || inFlight {
+		return
+	}
+	if err := s.SendDepositFinalisationTx(); err != nil {
+		s.Logger.Error("Unable to send deposit finalisation", "error", err)
+	}
+}
+
+// applyLog runs the processor of the event of the log against the given DB
+func (s *Syncer) applyLog(db core.DB, vLog *ethTypes.Log) error {
+	topic := vLog.Topics[0].Bytes()
+	for i := range s.abis {
+		abiObject := &s.abis[i]
+		selectedEvent := EventByID(abiObject, topic)
+		if selectedEvent == nil {
+			s.Logger.Info("Unable to find an event", "topic", topic)
+			continue
+		}
+		s.Logger.Debug("Found an event", "name", selectedEvent.Name)
+		switch selectedEvent.Name {
+		case "RegistrationRequest":
+			return s.processRegistrationRequest(db, selectedEvent.Name, abiObject, vLog)
+		case "RegisteredToken":
+			return s.processRegisteredToken(db, selectedEvent.Name, abiObject, vLog)
+		case "NewBatch":
+			return s.processNewBatch(db, selectedEvent.Name, abiObject, vLog)
+		case "NewPubkeyAdded":
+			return s.processNewPubkeyAddition(db, selectedEvent.Name, abiObject, vLog)
+		case "DepositQueued":
+			return s.processDepositQueued(db, selectedEvent.Name, abiObject, vLog)
+		case "DepositSubTreeReady":
+			return s.processDepositSubtreeCreated(db, selectedEvent.Name, abiObject, vLog)
+		case "DepositsFinalised":
+			return s.processDepositFinalised(db, selectedEvent.Name, abiObject, vLog)
+		case "BatchRollback":
+			return s.processBatchRollback(db, selectedEvent.Name, abiObject, vLog)
+		case "StakeWithdraw":
+			return s.processStakeWithdraw(db, selectedEvent.Name, abiObject, vLog)
+		default:
+			s.Logger.Debug("Unable to match with any event", "event", selectedEvent.Name)
+		}
+	}
+	return nil
+}
+
+// eventName returns the name of the event of the log, empty if it isn't known
+func (s *Syncer) eventName(vLog *ethTypes.Log) string {
+	for i := range s.abis {
+		if selectedEvent := EventByID(&s.abis[i], vLog.Topics[0].Bytes()); selectedEvent != nil {
+			return selectedEvent.Name
+		}
+	}
+	return ""
+}
+
+// handleFailure records a failed attempt at applying the event and schedules the next one
+// returns true once the event failed too many times and was moved to the dead letters
+// Events which can't be skipped halt the syncer instead, see CanDeadLetter
+func (s *Syncer) handleFailure(vLog *ethTypes.Log, err error) bool {
+	if s.failure == nil || s.failure.blockNumber != vLog.BlockNumber || s.failure.logIndex != uint64(vLog.Index) {
+		s.failure = &eventFailure{blockNumber: vLog.BlockNumber, logIndex: uint64(vLog.Index)}
+	}
+	s.failure.attempts++
+	if s.failure.attempts < MaxEventAttempts {
+		backoff := RetryBackoff(s.failure.attempts)
+		s.failure.retryAt = time.Now().Add(backoff)
+		s.Logger.Error("Unable to apply event, retrying", "block", vLog.BlockNumber, "logIndex", vLog.Index, "attempts", s.failure.attempts, "retryIn", backoff, "error", err)
+		return false
+	}
+
+	eventName := s.eventName(vLog)
+	if !CanDeadLetter(eventName) {
+		s.Logger.Error("Event keeps failing and skipping it would diverge the local state, halting the syncer until the node is restarted", "event", eventName, "block", vLog.BlockNumber, "logIndex", vLog.Index, "attempts", s.failure.attempts, "error", err)
+		s.halted = true
+		return false
+	}
+	deadLetter := core.NewDeadLetter(eventName, *vLog, err, s.failure.attempts)
+	if dbErr := s.DBInstance.AddDeadLetter(deadLetter); dbErr != nil {
+		s.Logger.Error("Unable to record dead letter", "error", dbErr)
+		s.failure.retryAt = time.Now().Add(RetryBackoff(s.failure.attempts))
+		return false
+	}
+	s.Logger.Error("Giving up on event, moved to dead letters", "event", deadLetter.EventName, "block", vLog.BlockNumber, "logIndex", vLog.Index, "error", err)
+	return true
+}
+
+// CanDeadLetter returns true if the event can be skipped and replayed later on top of the state at that point
+// Events which change the balance or pubkey trees, or the batches, have to be applied in order
+func CanDeadLetter(eventName string) bool {
+	switch eventName {
+	case "RegistrationRequest", "RegisteredToken":
+		return true
+	default:
+		return false
+	}
+}
+
+// RetryBackoff returns how long to wait before retrying an event which failed the given number of times
+func RetryBackoff(attempts uint64) time.Duration {
+	backoff := BaseRetryBackoff
+	for i := uint64(1); i < attempts && backoff < MaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > MaxRetryBackoff {
+		backoff = MaxRetryBackoff
+	}
+	return backoff
+}
+
+// ReplayDeadLetter applies the event of the dead letter again
+// Dead letters are replayed out of order, on top of the current state
+func (s *Syncer) ReplayDeadLetter(id uint64) error {
+	deadLetter, err := s.DBInstance.GetDeadLetterByID(id)
+	if err != nil {
+		return err
+	}
+	if deadLetter.Replayed {
+		return fmt.Errorf("dead letter %v was replayed already", id)
+	}
+	vLog := deadLetter.Log()
+	err = s.processLog(&vLog, false)
+	if dbErr := s.DBInstance.RecordDeadLetterReplay(id, err); dbErr != nil {
+		return dbErr
+	}
+	return err
+}
+
+// handleReorg unwinds the local state to the fork point if the last synced block isn't canonical anymore
+func (s *Syncer) handleReorg() error {
+	fork, err := unwindIfReorged(context.Background(), s.chain, &s.DBInstance, &core.StateLock)
+	if err != nil || fork == nil {
+		return err
+	}
+	s.Logger.Info("Main chain reorganised, unwound local state", "forkPoint", fork.Number, "lastBatch", fork.LastBatchID)
+	return nil
 }
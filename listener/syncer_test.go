@@ -0,0 +1,32 @@
+package listener
+
+import (
+	"testing"
+
+	"github.com/BOPR/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	require.Equal(t, BaseRetryBackoff, RetryBackoff(1))
+	require.Equal(t, 2*BaseRetryBackoff, RetryBackoff(2))
+	require.Equal(t, 8*BaseRetryBackoff, RetryBackoff(4))
+	require.Equal(t, MaxRetryBackoff, RetryBackoff(100))
+}
+
+func TestCanDeadLetter(t *testing.T) {
+	require.True(t, CanDeadLetter("RegistrationRequest"))
+	require.True(t, CanDeadLetter("RegisteredToken"))
+	require.False(t, CanDeadLetter("NewBatch"))
+	require.False(t, CanDeadLetter("DepositsFinalised"))
+	require.False(t, CanDeadLetter("BatchRollback"))
+	require.False(t, CanDeadLetter(""))
+}
+
+func TestNextSyncState(t *testing.T) {
+	require.Equal(t, uint64(core.SYNC_STATE_SYNCED), NextSyncState(0, false))
+	require.Equal(t, uint64(core.SYNC_STATE_SYNCED), NextSyncState(MaxSyncedLag, false))
+	require.Equal(t, uint64(core.SYNC_STATE_CATCHING_UP), NextSyncState(MaxSyncedLag+1, false))
+	require.Equal(t, uint64(core.SYNC_STATE_DEGRADED), NextSyncState(0, true))
+	require.Equal(t, uint64(core.SYNC_STATE_DEGRADED), NextSyncState(1000, true))
+}
@@ -0,0 +1,68 @@
+package listener
+
+import (
+	"encoding/json"
+
+	"github.com/BOPR/core"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// NewUnsafeEvent decodes a log emitted in a block which isn't confirmed yet
+func NewUnsafeEvent(event *abi.Event, vLog *ethTypes.Log) (core.UnsafeEvent, error) {
+	fields := make(map[string]interface{})
+	if len(vLog.Data) > 0 {
+		if err := event.Inputs.NonIndexed().UnpackIntoMap(fields, vLog.Data); err != nil {
+			return core.UnsafeEvent{}, err
+		}
+	}
+	// indexed fields are only available as topics
+	topics := vLog.Topics[1:]
+	for _, arg := range event.Inputs {
+		if arg.Indexed && len(topics) > 0 {
+			fields[arg.Name] = topics[0].Hex()
+			topics = topics[1:]
+		}
+	}
+	for name, value := range fields {
+		switch v := value.(type) {
+		case [32]byte:
+			fields[name] = core.ByteArray(v).String()
+		case ethCmn.Address:
+			fields[name] = v.Hex()
+		}
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return core.UnsafeEvent{}, err
+	}
+	return core.UnsafeEvent{
+		Name:        event.Name,
+		BlockNumber: vLog.BlockNumber,
+		BlockHash:   vLog.BlockHash.Hex(),
+		TxHash:      vLog.TxHash.Hex(),
+		LogIndex:    uint64(vLog.Index),
+		Fields:      encoded,
+	}, nil
+}
+
+// decodeUnsafeEvents decodes the logs of the known events, skipping the others
+func (s *Syncer) decodeUnsafeEvents(logs []ethTypes.Log) (events []core.UnsafeEvent, err error) {
+	for i := range logs {
+		topic := logs[i].Topics[0].Bytes()
+		for j := range s.abis {
+			selectedEvent := EventByID(&s.abis[j], topic)
+			if selectedEvent == nil {
+				continue
+			}
+			event, err := NewUnsafeEvent(selectedEvent, &logs[i])
+			if err != nil {
+				return events, err
+			}
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,47 @@
+package listener
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/BOPR/contracts/logger"
+	"github.com/BOPR/core"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUnsafeEvent(t *testing.T) {
+	loggerABI, err := abi.JSON(strings.NewReader(logger.LoggerABI))
+	require.NoError(t, err)
+	event := loggerABI.Events["BatchRollback"]
+
+	committer := ethCmn.HexToAddress("0x1")
+	stateRoot := [32]byte{1}
+	data, err := event.Inputs.NonIndexed().Pack(big.NewInt(7), committer, stateRoot, [32]byte{2}, big.NewInt(100))
+	require.NoError(t, err)
+	vLog := ethTypes.Log{
+		Topics:      []ethCmn.Hash{event.ID()},
+		Data:        data,
+		BlockNumber: 42,
+		BlockHash:   ethCmn.HexToHash("0xb"),
+		TxHash:      ethCmn.HexToHash("0xa"),
+		Index:       3,
+	}
+
+	unsafeEvent, err := NewUnsafeEvent(&event, &vLog)
+	require.NoError(t, err)
+	require.Equal(t, "BatchRollback", unsafeEvent.Name)
+	require.Equal(t, uint64(42), unsafeEvent.BlockNumber)
+	require.Equal(t, uint64(3), unsafeEvent.LogIndex)
+	require.Equal(t, vLog.TxHash.Hex(), unsafeEvent.TxHash)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(unsafeEvent.Fields, &fields))
+	require.Equal(t, float64(7), fields["batch_id"])
+	require.Equal(t, committer.Hex(), fields["committer"])
+	require.Equal(t, core.ByteArray(stateRoot).String(), fields["stateRoot"])
+}
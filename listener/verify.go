@@ -0,0 +1,79 @@
+package listener
+
+import (
+	"fmt"
+
+	"github.com/BOPR/config"
+	"github.com/BOPR/core"
+)
+
+// RootMismatchError is returned when the state root after applying a batch isn't the one committed on chain
+type RootMismatchError struct {
+	BatchID   uint64
+	Committed string
+	Computed  string
+
+	// set instead of the computed root when the batch doesn't even apply locally
+	Reason string
+
+	// the local state is unwound and the events applied again, otherwise the syncer halts
+	Resync bool
+}
+
+func (e *RootMismatchError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("batch %v doesn't apply locally, committed: %v reason: %v", e.BatchID, e.Committed, e.Reason)
+	}
+	return fmt.Sprintf("state root mismatch for batch %v, committed: %v computed: %v", e.BatchID, e.Committed, e.Computed)
+}
+
+// RootMismatchAction returns what to do about a batch whose committed state root isn't the one computed locally
+// Batches we committed are never disputed, it is our local state which is off, resyncing makes us apply
+// the batch like any other, if it mismatches again the syncer halts. A batch of someone else which
+// mismatches again right after a resync is disputed.
+func RootMismatchAction(policy string, own bool, resyncedAlready bool) string {
+	switch {
+	case policy == config.RootMismatchHalt:
+		return config.RootMismatchHalt
+	case own && resyncedAlready:
+		return config.RootMismatchHalt
+	case own:
+		return config.RootMismatchResync
+	case policy == config.RootMismatchResync && !resyncedAlready:
+		return config.RootMismatchResync
+	default:
+		return config.RootMismatchDispute
+	}
+}
+
+// rootMismatchAction returns what to do about the batch, see RootMismatchAction
+func (s *Syncer) rootMismatchAction(batchID uint64, own bool) string {
+	return RootMismatchAction(config.GlobalCfg.GetRootMismatchPolicy(), own, s.resyncedBatch != nil && *s.resyncedBatch == batchID)
+}
+
+// handleRootMismatch halts the syncer or unwinds the local state to the last synced block
+// The mismatch is always returned so that the events after it aren't applied
+func (s *Syncer) handleRootMismatch(mismatch *RootMismatchError) error {
+	if mismatch.Resync {
+		synced, err := s.DBInstance.GetSyncedBlocks(1)
+		if err != nil {
+			return err
+		}
+		if len(synced) != 0 {
+			s.Logger.Error("State root mismatch, resyncing from the last synced block", "batch", mismatch.BatchID, "committed", mismatch.Committed, "computed", mismatch.Computed, "block", synced[0].Number)
+			batchID := mismatch.BatchID
+			s.resyncedBatch = &batchID
+			core.StateLock.Lock()
+			err := s.DBInstance.UnwindTo(synced[0])
+			core.StateLock.Unlock()
+			if err != nil {
+				return err
+			}
+			return mismatch
+		}
+		s.Logger.Error("No synced block to resync from, halting instead")
+	}
+	s.Logger.Error("State root mismatch, halting the syncer until the node is restarted", "batch", mismatch.BatchID, "committed", mismatch.Committed, "computed", mismatch.Computed)
+	s.halted = true
+	return mismatch
+}
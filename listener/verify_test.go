@@ -0,0 +1,35 @@
+package listener
+
+import (
+	"testing"
+
+	"github.com/BOPR/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRootMismatchAction(t *testing.T) {
+	// halting wins over everything
+	require.Equal(t, config.RootMismatchHalt, RootMismatchAction(config.RootMismatchHalt, false, false))
+	require.Equal(t, config.RootMismatchHalt, RootMismatchAction(config.RootMismatchHalt, true, false))
+
+	// our own batches are never disputed, the syncer halts if one mismatches again after a resync
+	require.Equal(t, config.RootMismatchResync, RootMismatchAction(config.RootMismatchDispute, true, false))
+	require.Equal(t, config.RootMismatchResync, RootMismatchAction(config.RootMismatchResync, true, false))
+	require.Equal(t, config.RootMismatchHalt, RootMismatchAction(config.RootMismatchResync, true, true))
+	require.Equal(t, config.RootMismatchHalt, RootMismatchAction(config.RootMismatchDispute, true, true))
+
+	require.Equal(t, config.RootMismatchDispute, RootMismatchAction(config.RootMismatchDispute, false, false))
+	require.Equal(t, config.RootMismatchResync, RootMismatchAction(config.RootMismatchResync, false, false))
+
+	// mismatching again after a resync, the batch is the problem
+	require.Equal(t, config.RootMismatchDispute, RootMismatchAction(config.RootMismatchResync, false, true))
+}
+
+func TestRootMismatchPolicyDefaults(t *testing.T) {
+	cfg := config.Configuration{}
+	require.Equal(t, config.DefaultRootMismatchPolicy, cfg.GetRootMismatchPolicy())
+	cfg.RootMismatchPolicy = config.RootMismatchResync
+	require.Equal(t, config.RootMismatchResync, cfg.GetRootMismatchPolicy())
+	cfg.RootMismatchPolicy = "retry"
+	require.Equal(t, config.RootMismatchHalt, cfg.GetRootMismatchPolicy())
+}
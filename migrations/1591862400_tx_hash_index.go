@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1591862400",
+		Up: func(db *gorm.DB) error {
+			// tx hashes are derived from the signed message, so a duplicate hash is a duplicate tx
+			return db.Model(&types.Tx{}).AddUniqueIndex("idx_tx_hash", "tx_hash").Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Model(&types.Tx{}).RemoveIndex("idx_tx_hash").Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1592035200",
+		Up: func(db *gorm.DB) error {
+			// used to add the fee column, txs don't carry a signed fee so it was never used and is dropped by 1593590400
+			return db.AutoMigrate(&types.Tx{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			if !db.Dialect().HasColumn("txes", "fee") {
+				return nil
+			}
+			return db.Model(&types.Tx{}).DropColumn("fee").Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
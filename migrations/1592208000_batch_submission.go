@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1592208000",
+		Up: func(db *gorm.DB) error {
+			// adds the columns tracking L1 submission of batches
+			return db.AutoMigrate(&types.Batch{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			for _, column := range []string{"submission_nonce", "submission_gas_price", "submission_attempts", "submission_status", "included_in_block"} {
+				if err := db.Model(&types.Batch{}).DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
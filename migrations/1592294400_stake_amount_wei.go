@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+// weiDigits is the number of zeros to append to an amount in ETH to get it in wei
+const weiDigits = "000000000000000000"
+
+func init() {
+	m := &Migration{
+		ID: "1592294400",
+		Up: func(db *gorm.DB) error {
+			// stake amounts are stored in wei as decimal strings, the existing ones are in ETH
+			// appending the zeros keeps them exact, multiplying in SQL would go through a double
+			for _, model := range []interface{}{&types.Params{}, &types.Batch{}} {
+				if err := db.Model(model).ModifyColumn("stake_amount", "varchar(78)").Error; err != nil {
+					return err
+				}
+				if err := db.Model(model).Where("stake_amount != ?", "0").UpdateColumn("stake_amount", gorm.Expr("CONCAT(stake_amount, ?)", weiDigits)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(db *gorm.DB) error {
+			// back to whole ETH, anything below 1 ETH is dropped
+			for _, model := range []interface{}{&types.Params{}, &types.Batch{}} {
+				if err := db.Model(model).UpdateColumn("stake_amount", gorm.Expr("IF(LENGTH(stake_amount) > ?, LEFT(stake_amount, LENGTH(stake_amount) - ?), '0')", len(weiDigits), len(weiDigits))).Error; err != nil {
+					return err
+				}
+				if err := db.Model(model).ModifyColumn("stake_amount", "bigint unsigned").Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1592380800",
+		Up: func(db *gorm.DB) error {
+			// disputes raised against invalid batches
+			return db.CreateTable(&types.Dispute{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTableIfExists(&types.Dispute{}).Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
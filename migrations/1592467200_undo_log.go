@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1592467200",
+		Up: func(db *gorm.DB) error {
+			// journal of account leaf updates, used to rebuild historical state
+			return db.CreateTable(&types.UndoLog{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTableIfExists(&types.UndoLog{}).Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
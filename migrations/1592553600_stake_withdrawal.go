@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1592553600",
+		Up: func(db *gorm.DB) error {
+			// adds the block batches finalise on
+			if err := db.AutoMigrate(&types.Batch{}).Error; err != nil {
+				return err
+			}
+			return db.CreateTable(&types.StakeWithdrawal{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.DropTableIfExists(&types.StakeWithdrawal{}).Error; err != nil {
+				return err
+			}
+			return db.Model(&types.Batch{}).DropColumn("finalises_on").Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
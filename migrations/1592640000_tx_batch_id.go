@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1592640000",
+		Up: func(db *gorm.DB) error {
+			// adds the batch txs were submitted in
+			return db.AutoMigrate(&types.Tx{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Model(&types.Tx{}).DropColumn("batch_id").Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
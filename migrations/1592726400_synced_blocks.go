@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1592726400",
+		Up: func(db *gorm.DB) error {
+			// blocks the syncer went through and batch status changes made by them, to unwind reorgs
+			if err := db.CreateTable(&types.SyncedBlock{}).Error; err != nil {
+				return err
+			}
+			return db.CreateTable(&types.BatchStatusUndo{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.DropTableIfExists(&types.BatchStatusUndo{}).Error; err != nil {
+				return err
+			}
+			return db.DropTableIfExists(&types.SyncedBlock{}).Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
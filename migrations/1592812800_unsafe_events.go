@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1592812800",
+		Up: func(db *gorm.DB) error {
+			// events in blocks which aren't confirmed yet
+			return db.CreateTable(&types.UnsafeEvent{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTableIfExists(&types.UnsafeEvent{}).Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
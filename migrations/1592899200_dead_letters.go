@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1592899200",
+		Up: func(db *gorm.DB) error {
+			// events the syncer gave up on, and the position of the last event applied
+			if err := db.CreateTable(&types.DeadLetter{}).Error; err != nil {
+				return err
+			}
+			return db.AutoMigrate(&types.SyncStatus{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.Model(&types.SyncStatus{}).DropColumn("cursor_block").Error; err != nil {
+				return err
+			}
+			if err := db.Model(&types.SyncStatus{}).DropColumn("cursor_log_index").Error; err != nil {
+				return err
+			}
+			return db.DropTableIfExists(&types.DeadLetter{}).Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
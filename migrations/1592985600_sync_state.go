@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1592985600",
+		Up: func(db *gorm.DB) error {
+			// adds the state of the syncer and the latest head seen
+			return db.AutoMigrate(&types.SyncStatus{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.Model(&types.SyncStatus{}).DropColumn("state").Error; err != nil {
+				return err
+			}
+			return db.Model(&types.SyncStatus{}).DropColumn("head_block").Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
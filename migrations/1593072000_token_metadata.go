@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1593072000",
+		Up: func(db *gorm.DB) error {
+			// tokens waiting to be registered, and the ERC20 metadata of registered tokens
+			if err := db.CreateTable(&types.TokenRegistrationRequest{}).Error; err != nil {
+				return err
+			}
+			return db.AutoMigrate(&types.Token{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.Model(&types.Token{}).DropColumn("symbol").Error; err != nil {
+				return err
+			}
+			if err := db.Model(&types.Token{}).DropColumn("decimals").Error; err != nil {
+				return err
+			}
+			return db.DropTableIfExists(&types.TokenRegistrationRequest{}).Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1593158400",
+		Up: func(db *gorm.DB) error {
+			// deposits and pubkeys changed per block, and the block tokens were registered in, to unwind reorgs
+			if err := db.CreateTable(&types.DepositUndo{}).Error; err != nil {
+				return err
+			}
+			if err := db.CreateTable(&types.PubkeyUndo{}).Error; err != nil {
+				return err
+			}
+			return db.AutoMigrate(&types.Token{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.Model(&types.Token{}).DropColumn("block_number").Error; err != nil {
+				return err
+			}
+			if err := db.DropTableIfExists(&types.PubkeyUndo{}).Error; err != nil {
+				return err
+			}
+			return db.DropTableIfExists(&types.DepositUndo{}).Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1593244800",
+		Up: func(db *gorm.DB) error {
+			// token IDs are allocated by the registry, so a duplicate ID is a duplicate registration
+			return db.Model(&types.Token{}).AddUniqueIndex("idx_token_id", "token_id").Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Model(&types.Token{}).RemoveIndex("idx_token_id").Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
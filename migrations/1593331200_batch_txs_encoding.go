@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1593331200",
+		Up: func(db *gorm.DB) error {
+			// txs were stored concatenated and can't be reliably told apart, they are stored encoded from now on
+			return db.Model(&types.Batch{}).Update("transactions_included", nil).Error
+		},
+		Down: func(db *gorm.DB) error {
+			// the concatenated txs are gone, batches stored since are left encoded
+			return nil
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1593417600",
+		Up: func(db *gorm.DB) error {
+			// latest journal entry of each batch, batches stored before keep the journal from being pruned
+			return db.AutoMigrate(&types.Batch{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Model(&types.Batch{}).DropColumn("journal_seq").Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1593504000",
+		Up: func(db *gorm.DB) error {
+			// every tx sent to submit one of our batches, our batches are matched by them when they land
+			if err := db.CreateTable(&types.BatchSubmissionHash{}).Error; err != nil {
+				return err
+			}
+			// only the latest version of the submissions sent before is known
+			return db.Exec(
+				"INSERT INTO batch_submission_hashes (tx_hash, nonce) SELECT submission_hash, submission_nonce FROM batches WHERE submission_attempts > 0 AND submission_hash != ''",
+			).Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTableIfExists(&types.BatchSubmissionHash{}).Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	types "github.com/BOPR/core"
+	"github.com/jinzhu/gorm"
+)
+
+func init() {
+	m := &Migration{
+		ID: "1593590400",
+		Up: func(db *gorm.DB) error {
+			// the fee isn't part of the signed tx, it can't be relied on
+			if !db.Dialect().HasColumn("txes", "fee") {
+				return nil
+			}
+			return db.Model(&types.Tx{}).DropColumn("fee").Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Exec("ALTER TABLE txes ADD COLUMN fee bigint unsigned").Error
+		},
+	}
+
+	// add migration to list
+	addMigration(m)
+}
@@ -0,0 +1,133 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BOPR/core"
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/require"
+)
+
+type accountStore struct {
+	accounts map[uint64]core.UserAccount
+	pending  map[uint64]uint64
+	pubkeys  map[uint64]string
+}
+
+func (s *accountStore) GetAccountLeafByID(ID uint64) (core.UserAccount, error) {
+	account, ok := s.accounts[ID]
+	if !ok {
+		return account, gorm.ErrRecordNotFound
+	}
+	return account, nil
+}
+
+func (s *accountStore) GetPendingTxCountFrom(accountID uint64) (uint64, error) {
+	return s.pending[accountID], nil
+}
+
+func (s *accountStore) GetPDALeafByID(ID uint64) (core.PDA, error) {
+	pubkey, ok := s.pubkeys[ID]
+	if !ok {
+		return core.PDA{}, errors.New("not found")
+	}
+	return core.PDA{PublicKey: pubkey}, nil
+}
+
+func (s *accountStore) GetSiblings(path string) (siblings []core.UserAccount, err error) {
+	for range path {
+		siblings = append(siblings, core.UserAccount{Hash: "0xsibling"})
+	}
+	return siblings, nil
+}
+
+// fakeCodec decodes every account the same, the leaf data of the fake accounts isn't encoded
+// Fake compressed txs are the sender, the receiver and then the amount big endian
+// Fake encoded proofs are the path of the leaf
+type fakeCodec struct {
+	balance *big.Int
+}
+
+func (d fakeCodec) DecodeAccount(data []byte) (ID, balance, nonce, token *big.Int, err error) {
+	return big.NewInt(0), d.balance, big.NewInt(5), big.NewInt(3), nil
+}
+
+func (d fakeCodec) DecompressTransferTx(compressedTx []byte) (from, to, amount *big.Int, sig []byte, err error) {
+	if len(compressedTx) < 3 {
+		return nil, nil, nil, nil, errors.New("tx too short")
+	}
+	from = big.NewInt(int64(compressedTx[0]))
+	to = big.NewInt(int64(compressedTx[1]))
+	return from, to, new(big.Int).SetBytes(compressedTx[2:]), []byte{0xab}, nil
+}
+
+func (d fakeCodec) EncodeAccountProof(proof core.AccountMerkleProof) ([]byte, error) {
+	return []byte(proof.Account.Path), nil
+}
+
+func (d fakeCodec) EncodePubkeyProof(proof core.PDAMerkleProof) ([]byte, error) {
+	if proof.PublicKey == "invalid" {
+		return nil, errors.New("invalid pubkey")
+	}
+	return []byte(proof.Path), nil
+}
+
+func serveAccount(service *Service, path string) *httptest.ResponseRecorder {
+	r := mux.NewRouter()
+	r.HandleFunc("/accounts/{id}", service.GetAccountHandler).Methods("GET")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestGetAccountHandler(t *testing.T) {
+	store := &accountStore{
+		accounts: map[uint64]core.UserAccount{
+			1: {AccountID: 1, Path: "01", Status: core.STATUS_ACTIVE, Hash: "0xleaf"},
+			2: {AccountID: 2, Path: "10", Status: core.STATUS_PENDING, Hash: "0xdeposit"},
+		},
+		pending: map[uint64]uint64{1: 2},
+		pubkeys: map[uint64]string{1: "0xpubkey"},
+	}
+	// more than fits in a uint64
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10)
+	service := NewService(store, nil, nil, nil, fakeCodec{balance: balance})
+
+	w := serveAccount(service, "/accounts/1")
+	require.Equal(t, http.StatusOK, w.Code)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fields))
+	require.Equal(t, "100000000000000000000", fields["balance"])
+	var account AccountResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+	require.Equal(t, balance, account.Balance.BigInt())
+	require.Equal(t, uint64(3), account.TokenType)
+	// two txs waiting in the mempool
+	require.Equal(t, uint64(8), account.PendingNonce)
+	require.Equal(t, "0xpubkey", account.PublicKey)
+	require.Equal(t, "0xleaf", account.Hash)
+	require.Nil(t, account.Proof)
+
+	w = serveAccount(service, "/accounts/1?proof=true")
+	require.Equal(t, http.StatusOK, w.Code)
+	account = AccountResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+	require.Len(t, account.Proof, 2)
+
+	// pending deposits have no key and aren't in the tree yet
+	w = serveAccount(service, "/accounts/2?proof=true")
+	require.Equal(t, http.StatusOK, w.Code)
+	account = AccountResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+	require.Equal(t, "", account.PublicKey)
+	require.Nil(t, account.Proof)
+
+	require.Equal(t, http.StatusNotFound, serveAccount(service, "/accounts/3").Code)
+	require.Equal(t, http.StatusBadRequest, serveAccount(service, "/accounts/abc").Code)
+}
@@ -6,7 +6,11 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/BOPR/config"
 	"github.com/BOPR/core"
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
 )
 
 type (
@@ -29,54 +33,75 @@ type (
 )
 
 // TxReceiverHandler handles user txs
-func TxReceiverHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Service) TxReceiverHandler(w http.ResponseWriter, r *http.Request) {
 	// receive the payload and read
 	var tx TxReceiver
 	if !ReadRESTReq(w, r, &tx) {
-		WriteErrorResponse(w, http.StatusBadRequest, "Cannot read request")
+		return
 	}
-
-	// create a new pending transaction
-	userTx := core.NewPendingTx(tx.From, tx.To, core.TX_TRANSFER_TYPE, tx.Signature, tx.Message)
-
-	// add the transaction to pool
-	err := core.DBInstance.InsertTx(&userTx)
+	response, err := s.SendTx(tx)
 	if err != nil {
-		WriteErrorResponse(w, http.StatusBadRequest, "Cannot read request")
+		WriteError(w, err)
+		return
 	}
-
-	output, err := json.Marshal(userTx)
+	output, err := json.Marshal(response)
 	if err != nil {
-		WriteErrorResponse(w, http.StatusBadRequest, "Unable to marshall account")
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to marshall transaction")
+		return
 	}
-
-	// write headers and data
 	w.Header().Set("Content-Type", "application/json")
 	_, _ = w.Write(output)
-	return
+}
+
+// AccountStore reads the accounts, their pending txs and their registered keys
+type AccountStore interface {
+	GetAccountLeafByID(ID uint64) (core.UserAccount, error)
+	GetPendingTxCountFrom(accountID uint64) (uint64, error)
+	GetPDALeafByID(ID uint64) (core.PDA, error)
+	GetSiblings(path string) ([]core.UserAccount, error)
+}
+
+// AccountResponse is the decoded state of an account
+type AccountResponse struct {
+	ID        uint64      `json:"ID"`
+	Balance   core.BigInt `json:"balance"`
+	Nonce     uint64      `json:"nonce"`
+	TokenType uint64      `json:"tokenType"`
+
+	// nonce the next tx of the account should carry, counting its txs waiting in the mempool
+	PendingNonce uint64 `json:"pendingNonce"`
+
+	Path   string `json:"path"`
+	Status uint64 `json:"status"`
+	Hash   string `json:"hash"`
+
+	// registered public key, empty if none was registered
+	PublicKey string `json:"pubkey"`
+
+	// hashes of the siblings from the leaf up to the root, only if asked for
+	Proof []string `json:"proof,omitempty"`
 }
 
 // GetAccountHandler fetches the user account data like balance, token type and nonce
-func GetAccountHandler(w http.ResponseWriter, r *http.Request) {
-	vars := r.URL.Query()
-	IDstr := vars.Get("ID")
-	ID, err := strconv.ParseUint(IDstr, 0, 64)
+// ?proof=true includes the merkle siblings of the account leaf
+func (s *Service) GetAccountHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.ParseUint(mux.Vars(r)["id"], 0, 64)
 	if err != nil {
 		WriteErrorResponse(w, http.StatusBadRequest, "Invalid ID")
+		return
 	}
-	fmt.Println(ID)
-	var account core.UserAccount
-	// account, err := core.DBInstance.GetAccount(ID)
-	// if err != nil {
-	// 	WriteErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Account with ID %v not found", ID))
-	// }
-	output, err := json.Marshal(account)
+	response, err := s.GetAccount(ID, r.URL.Query().Get("proof") == "true")
 	if err != nil {
-		WriteErrorResponse(w, http.StatusBadRequest, "Unable to marshall account")
+		WriteError(w, err)
+		return
+	}
+	output, err := json.Marshal(response)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to marshall account")
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	_, _ = w.Write(output)
-	return
 }
 
 // GetAccountHandler fetches the user account data like balance, token type and nonce
@@ -100,3 +125,441 @@ func GetTxTemplateForTxType(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(output)
 	return
 }
+
+// GetOperatorNonceHandler returns the operator nonce and the L1 sends waiting for it
+func GetOperatorNonceHandler(w http.ResponseWriter, r *http.Request) {
+	if core.OperatorNonceManager == nil {
+		WriteErrorResponse(w, http.StatusServiceUnavailable, "Nonce manager not initialised")
+		return
+	}
+	output, err := json.Marshal(core.OperatorNonceManager.Queue())
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to marshall nonce queue")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(output)
+}
+
+// GetStakeHandler returns the stake locked by the batches committed by the operator
+// and how much of it can be withdrawn as of the last synced block
+func GetStakeHandler(w http.ResponseWriter, r *http.Request) {
+	syncStatus, err := core.DBInstance.GetSyncStatus()
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to fetch sync status")
+		return
+	}
+	summary, err := core.DBInstance.GetStakeSummary(config.OperatorAddress.String(), syncStatus.LastEthBlockRecorded)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to fetch stake summary")
+		return
+	}
+	output, err := json.Marshal(summary)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to marshall stake summary")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(output)
+}
+
+type (
+	// UnsafeEvent is an event in a block which isn't confirmed yet, along with its fields
+	UnsafeEvent struct {
+		core.UnsafeEvent
+		Fields json.RawMessage `json:"fields"`
+	}
+
+	// UnsafeEventsResponse lists the events emitted after the last confirmed block
+	UnsafeEventsResponse struct {
+		ConfirmedBlock uint64        `json:"confirmedBlock"`
+		Events         []UnsafeEvent `json:"events"`
+	}
+)
+
+// GetUnsafeEventsHandler returns the events in the blocks which don't have enough confirmations
+// to be applied to the local state yet, they might still be reorged away
+func GetUnsafeEventsHandler(w http.ResponseWriter, r *http.Request) {
+	syncStatus, err := core.DBInstance.GetSyncStatus()
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to fetch sync status")
+		return
+	}
+	events, err := core.DBInstance.GetUnsafeEvents()
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to fetch unsafe events")
+		return
+	}
+	response := UnsafeEventsResponse{ConfirmedBlock: syncStatus.LastEthBlockRecorded, Events: []UnsafeEvent{}}
+	for _, event := range events {
+		response.Events = append(response.Events, UnsafeEvent{UnsafeEvent: event, Fields: event.Fields})
+	}
+	output, err := json.Marshal(response)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to marshall unsafe events")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(output)
+}
+
+// SyncStatusResponse is the state of the syncer, on-chain actions are only taken once synced
+type SyncStatusResponse struct {
+	State           string `json:"state"`
+	Synced          bool   `json:"synced"`
+	HeadBlock       uint64 `json:"headBlock"`
+	LastSyncedBlock uint64 `json:"lastSyncedBlock"`
+	LastBatch       uint64 `json:"lastBatch"`
+}
+
+// GetStatusHandler returns the sync state of the node
+func GetStatusHandler(w http.ResponseWriter, r *http.Request) {
+	response, err := GetSyncStatus()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	output, err := json.Marshal(response)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to marshall sync status")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(output)
+}
+
+// TokenStore reads the registered tokens and the ones waiting to be registered
+type TokenStore interface {
+	GetTokenByID(id uint64) (core.Token, error)
+	GetTokens() ([]core.Token, error)
+	GetTokenRegistrationRequests() ([]core.TokenRegistrationRequest, error)
+}
+
+// TokensResponse lists the registered tokens and the ones waiting to be registered
+type TokensResponse struct {
+	Tokens  []core.Token                    `json:"tokens"`
+	Pending []core.TokenRegistrationRequest `json:"pending"`
+}
+
+// GetTokensHandler returns the registered tokens along with the pending registration requests
+func (s *Service) GetTokensHandler(w http.ResponseWriter, r *http.Request) {
+	tokens, err := s.tokens.GetTokens()
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to fetch tokens")
+		return
+	}
+	pending, err := s.tokens.GetTokenRegistrationRequests()
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to fetch token registration requests")
+		return
+	}
+	response := TokensResponse{Tokens: tokens, Pending: pending}
+	if response.Tokens == nil {
+		response.Tokens = []core.Token{}
+	}
+	if response.Pending == nil {
+		response.Pending = []core.TokenRegistrationRequest{}
+	}
+	output, err := json.Marshal(response)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to marshall tokens")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(output)
+}
+
+// GetTokenHandler returns the registered token with the ID
+func (s *Service) GetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.ParseUint(mux.Vars(r)["id"], 0, 64)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	token, err := s.tokens.GetTokenByID(ID)
+	if gorm.IsRecordNotFoundError(err) {
+		WriteErrorResponse(w, http.StatusNotFound, fmt.Sprintf("Token with ID %v not found", ID))
+		return
+	} else if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to fetch token")
+		return
+	}
+	output, err := json.Marshal(token)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to marshall token")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(output)
+}
+
+const (
+	// batches listed per page unless asked otherwise, and at most
+	DefaultBatchesLimit = 20
+	MaxBatchesLimit     = 100
+)
+
+type (
+	// BatchTx is a tx decoded from the calldata of a batch
+	BatchTx struct {
+		From      uint64      `json:"from"`
+		To        uint64      `json:"to"`
+		Amount    core.BigInt `json:"amount"`
+		Signature string      `json:"sig"`
+	}
+
+	// BatchResponse is a batch along with its finalisation status
+	BatchResponse struct {
+		BatchID     uint64      `json:"batchID"`
+		StateRoot   string      `json:"stateRoot"`
+		TxRoot      string      `json:"txRoot"`
+		Committer   string      `json:"committer"`
+		StakeAmount core.BigInt `json:"stakeAmount"`
+		BatchType   uint64      `json:"batchType"`
+		Status      uint64      `json:"status"`
+		StatusName  string      `json:"statusName"`
+
+		// main chain block after which the batch can't be disputed, and whether it was reached
+		FinalisesOn uint64 `json:"finalisesOn"`
+		Final       bool   `json:"final"`
+
+		// main chain tx which submitted the batch
+		L1TxHash string `json:"l1TxHash"`
+
+		// whether the txs of the batch are stored, they aren't for transfer batches stored before
+		// txs were kept decodable, nor for ours until they are committed
+		TxsAvailable bool `json:"txsAvailable"`
+
+		// only returned for a single batch whose txs are available
+		Txs []BatchTx `json:"txs,omitempty"`
+	}
+
+	// BatchesResponse is a page of batches, Total counts all batches matching the filters
+	BatchesResponse struct {
+		Total   uint64          `json:"total"`
+		Offset  uint64          `json:"offset"`
+		Limit   uint64          `json:"limit"`
+		Batches []BatchResponse `json:"batches"`
+	}
+)
+
+// NewBatchResponse returns the batch as served, lastSyncedBlock decides whether it is final
+func NewBatchResponse(batch core.Batch, lastSyncedBlock uint64) BatchResponse {
+	return BatchResponse{
+		BatchID:     batch.BatchID,
+		StateRoot:   batch.StateRoot,
+		TxRoot:      batch.TxRoot,
+		Committer:   batch.Committer,
+		StakeAmount: batch.StakeAmount,
+		BatchType:   batch.BatchType,
+		Status:      batch.Status,
+		StatusName:  core.BatchStatusName(batch.Status),
+		FinalisesOn: batch.FinalisesOn,
+		Final:       batch.Status == core.BATCH_FINALISED || batch.Status == core.BATCH_STAKE_WITHDRAWN || (batch.Status == core.BATCH_COMMITTED && batch.IsFinal(lastSyncedBlock)),
+		L1TxHash:    batch.SubmissionHash,
+		// stored txs always decode to a list, even an empty one
+		TxsAvailable: batch.BatchType != core.TX_TRANSFER_TYPE || len(batch.TransactionsIncluded) != 0,
+	}
+}
+
+// BatchStore reads the batches and the last synced block, which decides whether they are final
+type BatchStore interface {
+	GetBatchByIndex(index uint64) (core.Batch, error)
+	GetBatches(filter core.BatchFilter, offset, limit uint64) ([]core.Batch, uint64, error)
+	GetSyncStatus() (core.SyncStatus, error)
+}
+
+// uintQueryParam parses the query parameter, returning the default if it isn't set
+func uintQueryParam(r *http.Request, name string, defaultValue uint64) (uint64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.ParseUint(value, 0, 64)
+}
+
+// GetBatchesHandler returns a page of batches, newest first
+// ?offset and ?limit page through them, ?committer, ?status and ?type filter them
+func (s *Service) GetBatchesHandler(w http.ResponseWriter, r *http.Request) {
+	offset, err := uintQueryParam(r, "offset", 0)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, "Invalid offset")
+		return
+	}
+	limit, err := uintQueryParam(r, "limit", DefaultBatchesLimit)
+	if err != nil || limit == 0 || limit > MaxBatchesLimit {
+		WriteErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Limit must be between 1 and %v", MaxBatchesLimit))
+		return
+	}
+	var filter core.BatchFilter
+	// committers are stored checksummed
+	if committer := r.URL.Query().Get("committer"); committer != "" {
+		if !ethCmn.IsHexAddress(committer) {
+			WriteErrorResponse(w, http.StatusBadRequest, "Invalid committer")
+			return
+		}
+		filter.Committer = ethCmn.HexToAddress(committer).String()
+	}
+	if filter.Status, err = uintQueryParam(r, "status", 0); err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+	if r.URL.Query().Get("type") != "" {
+		batchType, err := uintQueryParam(r, "type", 0)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, "Invalid type")
+			return
+		}
+		filter.BatchType = &batchType
+	}
+
+	syncStatus, err := s.batches.GetSyncStatus()
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to fetch sync status")
+		return
+	}
+	batches, total, err := s.batches.GetBatches(filter, offset, limit)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to fetch batches")
+		return
+	}
+	response := BatchesResponse{Total: total, Offset: offset, Limit: limit, Batches: []BatchResponse{}}
+	for _, batch := range batches {
+		response.Batches = append(response.Batches, NewBatchResponse(batch, syncStatus.LastEthBlockRecorded))
+	}
+	output, err := json.Marshal(response)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to marshall batches")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(output)
+}
+
+// GetBatchHandler returns the batch with the ID along with the txs it includes
+func (s *Service) GetBatchHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.ParseUint(mux.Vars(r)["id"], 0, 64)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	response, err := s.GetBatch(ID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	output, err := json.Marshal(response)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to marshall batch")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(output)
+}
+
+type (
+	// AccountProofJSON is an account merkle proof, siblings from the leaf up to the root
+	AccountProofJSON struct {
+		AccountID uint64   `json:"accountID"`
+		Path      string   `json:"path"`
+		Data      string   `json:"data"`
+		Hash      string   `json:"hash"`
+		Siblings  []string `json:"siblings"`
+	}
+
+	// AccountProofResponse is the proof of an account against Root, the balance root after BatchID if one was asked for
+	AccountProofResponse struct {
+		Root    string           `json:"root"`
+		BatchID *uint64          `json:"batchID,omitempty"`
+		Proof   AccountProofJSON `json:"proof"`
+
+		// proof as taken by the rollup contract
+		ABIEncoded string `json:"abiEncoded"`
+	}
+
+	// PubkeyProofJSON is a PDA merkle proof, siblings from the leaf up to the root
+	PubkeyProofJSON struct {
+		Path      string   `json:"path"`
+		PublicKey string   `json:"pubkey"`
+		Siblings  []string `json:"siblings"`
+	}
+
+	// PubkeyProofResponse is the proof of a registered pubkey against Root, the current PDA root
+	PubkeyProofResponse struct {
+		Root  string          `json:"root"`
+		Proof PubkeyProofJSON `json:"proof"`
+
+		// proof as taken by the rollup contract
+		ABIEncoded string `json:"abiEncoded"`
+	}
+)
+
+// ProofStore reads the trees the proofs are built from, historical account proofs are rebuilt from the journal
+type ProofStore interface {
+	GetAccountLeafByID(ID uint64) (core.UserAccount, error)
+	GetRoot() (core.UserAccount, error)
+	GetSiblings(path string) ([]core.UserAccount, error)
+	GetBatchByIndex(index uint64) (core.Batch, error)
+	GetLastCommittedBatchID() (uint64, error)
+	AccountProofAt(batchID uint64, path string) (core.AccountMerkleProof, error)
+	GetPDALeafByID(ID uint64) (core.PDA, error)
+	GetPDARoot() (core.PDA, error)
+	GetPDASiblings(path string) ([]core.PDA, error)
+}
+
+// GetAccountProofHandler returns the merkle proof of the account with the ID
+// ?batch=N proves the account as it was after the batch, against the batch state root
+func (s *Service) GetAccountProofHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.ParseUint(mux.Vars(r)["id"], 0, 64)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	var batchID *uint64
+	if r.URL.Query().Get("batch") != "" {
+		batch, err := uintQueryParam(r, "batch", 0)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, "Invalid batch")
+			return
+		}
+		batchID = &batch
+	}
+	response, err := s.GetAccountProof(ID, batchID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	output, err := json.Marshal(response)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to marshall proof")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(output)
+}
+
+// GetPubkeyProofHandler returns the merkle proof of the pubkey registered for the account with the ID
+// Proofs are only built against the current PDA root, which is the one disputes check pubkeys against
+func (s *Service) GetPubkeyProofHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.ParseUint(mux.Vars(r)["id"], 0, 64)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	if r.URL.Query().Get("batch") != "" {
+		WriteErrorResponse(w, http.StatusBadRequest, "Pubkey proofs are only against the current PDA root, batches don't commit to one")
+		return
+	}
+	response, err := s.GetPubkeyProof(ID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	output, err := json.Marshal(response)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusInternalServerError, "Unable to marshall proof")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(output)
+}
@@ -0,0 +1,198 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/BOPR/core"
+	ethCmn "github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/require"
+)
+
+type batchStore struct {
+	batches   []core.Batch
+	lastBlock uint64
+	err       error
+}
+
+func (s *batchStore) GetBatchByIndex(index uint64) (core.Batch, error) {
+	if s.err != nil {
+		return core.Batch{}, s.err
+	}
+	for _, batch := range s.batches {
+		if batch.BatchID == index {
+			return batch, nil
+		}
+	}
+	return core.Batch{}, gorm.ErrRecordNotFound
+}
+
+func (s *batchStore) GetBatches(filter core.BatchFilter, offset, limit uint64) (batches []core.Batch, total uint64, err error) {
+	if s.err != nil {
+		return nil, 0, s.err
+	}
+	var matching []core.Batch
+	for _, batch := range s.batches {
+		if filter.Committer != "" && batch.Committer != filter.Committer {
+			continue
+		}
+		if filter.Status != 0 && batch.Status != filter.Status {
+			continue
+		}
+		if filter.BatchType != nil && batch.BatchType != *filter.BatchType {
+			continue
+		}
+		matching = append(matching, batch)
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].BatchID > matching[j].BatchID })
+	total = uint64(len(matching))
+	if offset >= total {
+		return nil, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return matching[offset:end], total, nil
+}
+
+func (s *batchStore) GetSyncStatus() (core.SyncStatus, error) {
+	return core.SyncStatus{LastEthBlockRecorded: s.lastBlock}, nil
+}
+
+func encodeBatchTxs(t *testing.T, txs ...[]byte) []byte {
+	encoded, err := core.EncodeBatchTxs(txs)
+	require.NoError(t, err)
+	return encoded
+}
+
+func serveBatches(store BatchStore, path string) *httptest.ResponseRecorder {
+	service := NewService(nil, nil, store, nil, fakeCodec{})
+	r := mux.NewRouter()
+	r.HandleFunc("/batches", service.GetBatchesHandler).Methods("GET")
+	r.HandleFunc("/batches/{id}", service.GetBatchHandler).Methods("GET")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func decodeBatches(t *testing.T, w *httptest.ResponseRecorder) BatchesResponse {
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response BatchesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func batchIDs(response BatchesResponse) (IDs []uint64) {
+	for _, batch := range response.Batches {
+		IDs = append(IDs, batch.BatchID)
+	}
+	return IDs
+}
+
+func TestGetBatchesHandler(t *testing.T) {
+	// committers are stored checksummed
+	committer := ethCmn.HexToAddress("0x5aeda56215b167893e80b4fe645ba6d5bab767de").String()
+	store := &batchStore{lastBlock: 93}
+	for i := uint64(1); i <= 25; i++ {
+		store.batches = append(store.batches, core.Batch{BatchID: i, BatchType: core.TX_TRANSFER_TYPE, Status: core.BATCH_COMMITTED, FinalisesOn: 90 + i})
+	}
+	store.batches[0].BatchType = core.BATCH_TYPE_DEPOSIT_FINALISATION
+	store.batches[1].Committer = committer
+	store.batches[2].Status = core.BATCH_ROLLED_BACK
+
+	// first page, newest first
+	response := decodeBatches(t, serveBatches(store, "/batches"))
+	require.Equal(t, uint64(25), response.Total)
+	require.Equal(t, uint64(DefaultBatchesLimit), response.Limit)
+	require.Len(t, response.Batches, DefaultBatchesLimit)
+	require.Equal(t, uint64(25), response.Batches[0].BatchID)
+
+	response = decodeBatches(t, serveBatches(store, "/batches?offset=20&limit=10"))
+	require.Equal(t, []uint64{5, 4, 3, 2, 1}, batchIDs(response))
+	// committed batches are final once the finalisation block was synced
+	require.True(t, response.Batches[4].Final)
+	require.False(t, response.Batches[0].Final)
+	require.Equal(t, "rolled_back", response.Batches[2].StatusName)
+
+	// an empty page past the end rather than null
+	response = decodeBatches(t, serveBatches(store, "/batches?offset=30"))
+	require.Equal(t, uint64(25), response.Total)
+	require.NotNil(t, response.Batches)
+	require.Empty(t, response.Batches)
+
+	// committers match whatever their case
+	response = decodeBatches(t, serveBatches(store, "/batches?committer=0x5AEDA56215B167893E80B4FE645BA6D5BAB767DE"))
+	require.Equal(t, []uint64{2}, batchIDs(response))
+	response = decodeBatches(t, serveBatches(store, "/batches?status=400"))
+	require.Equal(t, []uint64{3}, batchIDs(response))
+	// deposit finalisations are type 0
+	response = decodeBatches(t, serveBatches(store, "/batches?type=0"))
+	require.Equal(t, []uint64{1}, batchIDs(response))
+	response = decodeBatches(t, serveBatches(store, "/batches?type=1&limit=100"))
+	require.Equal(t, uint64(24), response.Total)
+
+	for _, path := range []string{
+		"/batches?offset=-1",
+		"/batches?limit=0",
+		"/batches?limit=101",
+		"/batches?committer=0x01",
+		"/batches?status=committed",
+		"/batches?type=transfer",
+	} {
+		require.Equal(t, http.StatusBadRequest, serveBatches(store, path).Code, path)
+	}
+
+	store.err = errors.New("connection lost")
+	require.Equal(t, http.StatusInternalServerError, serveBatches(store, "/batches").Code)
+}
+
+func TestGetBatchHandler(t *testing.T) {
+	// more than fits in a uint64
+	amount, _ := new(big.Int).SetString("100000000000000000000", 10)
+	store := &batchStore{batches: []core.Batch{
+		{BatchID: 1, BatchType: core.TX_TRANSFER_TYPE, Status: core.BATCH_COMMITTED, TransactionsIncluded: encodeBatchTxs(t, append([]byte{1, 2}, amount.Bytes()...), []byte{3, 4, 5})},
+		{BatchID: 2, BatchType: core.TX_TRANSFER_TYPE, Status: core.BATCH_COMMITTED, TransactionsIncluded: encodeBatchTxs(t)},
+		// stored before txs were kept decodable
+		{BatchID: 3, BatchType: core.TX_TRANSFER_TYPE, Status: core.BATCH_COMMITTED, TxRoot: "0x01"},
+		{BatchID: 4, BatchType: core.BATCH_TYPE_DEPOSIT_FINALISATION, Status: core.BATCH_COMMITTED},
+		{BatchID: 5, BatchType: core.TX_TRANSFER_TYPE, Status: core.BATCH_COMMITTED, TransactionsIncluded: []byte{0xff}},
+	}}
+
+	w := serveBatches(store, "/batches/1")
+	require.Equal(t, http.StatusOK, w.Code)
+	var fields struct {
+		Txs []map[string]interface{} `json:"txs"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fields))
+	require.Len(t, fields.Txs, 2)
+	require.Equal(t, "100000000000000000000", fields.Txs[0]["amount"])
+	var batch BatchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &batch))
+	require.True(t, batch.TxsAvailable)
+	require.Equal(t, uint64(1), batch.Txs[0].From)
+	require.Equal(t, uint64(2), batch.Txs[0].To)
+	require.Equal(t, "ab", batch.Txs[0].Signature)
+	require.Equal(t, uint64(5), batch.Txs[1].Amount.Uint64())
+
+	// batches without txs are told apart from the ones whose txs weren't kept
+	for ID, available := range map[string]bool{"2": true, "3": false, "4": true} {
+		w = serveBatches(store, "/batches/"+ID)
+		require.Equal(t, http.StatusOK, w.Code)
+		batch = BatchResponse{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &batch))
+		require.Equal(t, available, batch.TxsAvailable, ID)
+		require.Empty(t, batch.Txs, ID)
+	}
+
+	require.Equal(t, http.StatusInternalServerError, serveBatches(store, "/batches/5").Code)
+	require.Equal(t, http.StatusNotFound, serveBatches(store, "/batches/6").Code)
+	require.Equal(t, http.StatusBadRequest, serveBatches(store, "/batches/abc").Code)
+}
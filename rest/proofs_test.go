@@ -0,0 +1,195 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BOPR/core"
+	"github.com/BOPR/fraudproof"
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/require"
+)
+
+type proofStore struct {
+	accounts    map[uint64]core.UserAccount
+	batches     map[uint64]core.Batch
+	lastBatchID uint64
+
+	// historical proofs fail with the error of their batch, if any
+	historyErrs map[uint64]error
+
+	pdas   map[uint64]core.PDA
+	pdaErr error
+}
+
+func (s *proofStore) GetAccountLeafByID(ID uint64) (core.UserAccount, error) {
+	account, ok := s.accounts[ID]
+	if !ok {
+		return account, gorm.ErrRecordNotFound
+	}
+	return account, nil
+}
+
+func (s *proofStore) GetRoot() (core.UserAccount, error) {
+	return core.UserAccount{Hash: "0xroot"}, nil
+}
+
+func (s *proofStore) GetSiblings(path string) (siblings []core.UserAccount, err error) {
+	for range path {
+		siblings = append(siblings, core.UserAccount{Hash: "0xsibling"})
+	}
+	return siblings, nil
+}
+
+func (s *proofStore) GetBatchByIndex(index uint64) (core.Batch, error) {
+	batch, ok := s.batches[index]
+	if !ok {
+		return batch, gorm.ErrRecordNotFound
+	}
+	return batch, nil
+}
+
+func (s *proofStore) GetLastCommittedBatchID() (uint64, error) {
+	return s.lastBatchID, nil
+}
+
+func (s *proofStore) AccountProofAt(batchID uint64, path string) (core.AccountMerkleProof, error) {
+	if err := s.historyErrs[batchID]; err != nil {
+		return core.AccountMerkleProof{}, err
+	}
+	// the leaf as it was then, with its old siblings
+	account := core.UserAccount{AccountID: 1, Path: path, Status: core.STATUS_ACTIVE, Hash: "0xold"}
+	return core.NewAccountMerkleProof(account, []core.UserAccount{{Hash: "0xoldsibling"}}), nil
+}
+
+func (s *proofStore) GetPDALeafByID(ID uint64) (core.PDA, error) {
+	if s.pdaErr != nil {
+		return core.PDA{}, s.pdaErr
+	}
+	pda, ok := s.pdas[ID]
+	if !ok {
+		return pda, gorm.ErrRecordNotFound
+	}
+	return pda, nil
+}
+
+func (s *proofStore) GetPDARoot() (core.PDA, error) {
+	return core.PDA{Hash: "0xpdaroot"}, nil
+}
+
+func (s *proofStore) GetPDASiblings(path string) (siblings []core.PDA, err error) {
+	for range path {
+		siblings = append(siblings, core.PDA{Hash: "0xpdasibling"})
+	}
+	return siblings, nil
+}
+
+func newProofStore() *proofStore {
+	return &proofStore{
+		accounts: map[uint64]core.UserAccount{
+			1: {AccountID: 1, Path: "01", Status: core.STATUS_ACTIVE, Hash: "0xleaf"},
+			2: {AccountID: 2, Path: "10", Status: core.STATUS_PENDING},
+		},
+		batches: map[uint64]core.Batch{
+			1: {BatchID: 1, StateRoot: "0xbatch1"},
+			2: {BatchID: 2, StateRoot: "0xbatch2"},
+			3: {BatchID: 3, StateRoot: "0xbatch3"},
+			4: {BatchID: 4, StateRoot: "0xbatch4"},
+		},
+		lastBatchID: 4,
+		historyErrs: map[uint64]error{
+			2: fraudproof.ErrBatchNotApplied,
+			3: fraudproof.ErrPreStateNotFound,
+			4: errors.New("connection lost"),
+		},
+		pdas: map[uint64]core.PDA{
+			1: {AccountID: 1, Path: "01", PublicKey: "abcd"},
+			// leaves are looked up by account, an empty one has no key
+			3: {Path: "11"},
+			4: {AccountID: 4, Path: "00", PublicKey: "invalid"},
+		},
+	}
+}
+
+func serveProofs(store ProofStore, path string) *httptest.ResponseRecorder {
+	service := NewService(nil, nil, nil, store, fakeCodec{})
+	r := mux.NewRouter()
+	r.HandleFunc("/proofs/account/{id}", service.GetAccountProofHandler).Methods("GET")
+	r.HandleFunc("/proofs/pubkey/{id}", service.GetPubkeyProofHandler).Methods("GET")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestGetAccountProofHandler(t *testing.T) {
+	store := newProofStore()
+
+	w := serveProofs(store, "/proofs/account/1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var proof AccountProofResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &proof))
+	require.Equal(t, "0xroot", proof.Root)
+	require.Nil(t, proof.BatchID)
+	require.Equal(t, "0xleaf", proof.Proof.Hash)
+	require.Equal(t, []string{"0xsibling", "0xsibling"}, proof.Proof.Siblings)
+	require.Equal(t, "0x3031", proof.ABIEncoded)
+
+	// proven against the state root of the batch
+	w = serveProofs(store, "/proofs/account/1?batch=1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	proof = AccountProofResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &proof))
+	require.Equal(t, "0xbatch1", proof.Root)
+	require.Equal(t, uint64(1), *proof.BatchID)
+	require.Equal(t, "0xold", proof.Proof.Hash)
+	require.Equal(t, []string{"0xoldsibling"}, proof.Proof.Siblings)
+
+	for path, status := range map[string]int{
+		// pending deposits aren't in the tree yet
+		"/proofs/account/2":         http.StatusNotFound,
+		"/proofs/account/5":         http.StatusNotFound,
+		"/proofs/account/abc":       http.StatusBadRequest,
+		"/proofs/account/1?batch=x": http.StatusBadRequest,
+		"/proofs/account/1?batch=9": http.StatusNotFound,
+		"/proofs/account/1?batch=2": http.StatusBadRequest,
+		"/proofs/account/1?batch=3": http.StatusNotFound,
+		"/proofs/account/1?batch=4": http.StatusInternalServerError,
+	} {
+		require.Equal(t, status, serveProofs(store, path).Code, path)
+	}
+
+	// the journal isn't kept for older batches
+	store.lastBatchID = 2 + core.JournalHistoryBatches
+	require.Equal(t, http.StatusBadRequest, serveProofs(store, "/proofs/account/1?batch=1").Code)
+}
+
+func TestGetPubkeyProofHandler(t *testing.T) {
+	store := newProofStore()
+
+	w := serveProofs(store, "/proofs/pubkey/1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var proof PubkeyProofResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &proof))
+	require.Equal(t, "0xpdaroot", proof.Root)
+	require.Equal(t, "abcd", proof.Proof.PublicKey)
+	require.Equal(t, []string{"0xpdasibling", "0xpdasibling"}, proof.Proof.Siblings)
+	require.Equal(t, "0x3031", proof.ABIEncoded)
+
+	for path, status := range map[string]int{
+		"/proofs/pubkey/2":         http.StatusNotFound,
+		"/proofs/pubkey/3":         http.StatusNotFound,
+		"/proofs/pubkey/4":         http.StatusInternalServerError,
+		"/proofs/pubkey/abc":       http.StatusBadRequest,
+		"/proofs/pubkey/1?batch=1": http.StatusBadRequest,
+	} {
+		require.Equal(t, status, serveProofs(store, path).Code, path)
+	}
+
+	// failing to read the tree isn't reported as a missing pubkey
+	store.pdaErr = errors.New("connection lost")
+	require.Equal(t, http.StatusInternalServerError, serveProofs(store, "/proofs/pubkey/1").Code)
+}
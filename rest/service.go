@@ -0,0 +1,318 @@
+package rest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/BOPR/core"
+	"github.com/BOPR/fraudproof"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/jinzhu/gorm"
+)
+
+// The functions below are what the REST handlers serve, they are shared with the JSON-RPC server
+// Failed requests return an *Error carrying the HTTP status, anything else is an internal error
+
+// Codec decodes account leaf data and the txs of batches and encodes proofs, the rollup contracts define the encodings
+type Codec interface {
+	DecodeAccount(data []byte) (ID, balance, nonce, token *big.Int, err error)
+	DecompressTransferTx(compressedTx []byte) (from, to, amount *big.Int, sig []byte, err error)
+	EncodeAccountProof(proof core.AccountMerkleProof) ([]byte, error)
+	EncodePubkeyProof(proof core.PDAMerkleProof) ([]byte, error)
+}
+
+// Service reads the node state through the stores it was created with
+type Service struct {
+	accounts AccountStore
+	tokens   TokenStore
+	batches  BatchStore
+	proofs   ProofStore
+	codec    Codec
+}
+
+// NewService creates a service reading accounts, tokens, batches and proofs from the stores
+func NewService(accounts AccountStore, tokens TokenStore, batches BatchStore, proofs ProofStore, codec Codec) *Service {
+	return &Service{accounts: accounts, tokens: tokens, batches: batches, proofs: proofs, codec: codec}
+}
+
+// dbProofStore reads proofs from the node database, historical ones are rebuilt from its journal
+type dbProofStore struct {
+	*core.DB
+}
+
+// NewDBProofStore returns a proof store reading the node database
+func NewDBProofStore(db *core.DB) ProofStore {
+	return dbProofStore{DB: db}
+}
+
+func (s dbProofStore) AccountProofAt(batchID uint64, path string) (core.AccountMerkleProof, error) {
+	return fraudproof.AccountProofAtFromDB(*s.DB, batchID, path)
+}
+
+// SendTx adds the transfer to the pool of txs waiting to be batched
+func (s *Service) SendTx(tx TxReceiver) (core.Tx, error) {
+	// only transfers of registered tokens can be batched
+	_, _, token, _, _, _, err := core.LoadedBazooka.DecodeTransferTx(tx.Message)
+	if err != nil {
+		return core.Tx{}, NewError(http.StatusBadRequest, "Unable to decode transaction")
+	}
+	if err := s.checkTokenRegistered(token.Uint64()); err != nil {
+		return core.Tx{}, err
+	}
+
+	// create a new pending transaction
+	userTx := core.NewPendingTx(tx.From, tx.To, core.TX_TRANSFER_TYPE, tx.Signature, tx.Message)
+
+	// reject txs that have already been received
+	_, err = core.DBInstance.GetTxByHash(userTx.TxHash)
+	if err == nil {
+		return core.Tx{}, NewError(http.StatusConflict, fmt.Sprintf("Transaction with hash %v already exists", userTx.TxHash))
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return core.Tx{}, NewError(http.StatusInternalServerError, "Unable to check for duplicate transaction")
+	}
+
+	// add the transaction to pool
+	if err := core.DBInstance.InsertTx(&userTx); err != nil {
+		return core.Tx{}, NewError(http.StatusBadRequest, "Cannot read request")
+	}
+	return userTx, nil
+}
+
+// checkTokenRegistered returns an error unless the token is registered
+func (s *Service) checkTokenRegistered(tokenID uint64) error {
+	if _, err := s.tokens.GetTokenByID(tokenID); gorm.IsRecordNotFoundError(err) {
+		return NewError(http.StatusBadRequest, fmt.Sprintf("Token %v is not registered", tokenID))
+	} else if err != nil {
+		return NewError(http.StatusInternalServerError, "Unable to fetch token")
+	}
+	return nil
+}
+
+// GetTx returns the tx with the hash, pending or batched
+func GetTx(hash string) (core.Tx, error) {
+	tx, err := core.DBInstance.GetTxByHash(hash)
+	if gorm.IsRecordNotFoundError(err) {
+		return tx, NewError(http.StatusNotFound, fmt.Sprintf("Transaction with hash %v not found", hash))
+	} else if err != nil {
+		return tx, NewError(http.StatusInternalServerError, "Unable to fetch transaction")
+	}
+	return tx, nil
+}
+
+// GetAccount returns the decoded state of the account, withProof includes the merkle siblings of the account leaf
+func (s *Service) GetAccount(ID uint64, withProof bool) (AccountResponse, error) {
+	account, err := s.accounts.GetAccountLeafByID(ID)
+	if gorm.IsRecordNotFoundError(err) {
+		return AccountResponse{}, NewError(http.StatusNotFound, fmt.Sprintf("Account with ID %v not found", ID))
+	} else if err != nil {
+		return AccountResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch account")
+	}
+	_, balance, nonce, token, err := s.codec.DecodeAccount(account.Data)
+	if err != nil {
+		return AccountResponse{}, NewError(http.StatusInternalServerError, "Unable to decode account")
+	}
+	pendingTxs, err := s.accounts.GetPendingTxCountFrom(ID)
+	if err != nil {
+		return AccountResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch pending transactions")
+	}
+	response := AccountResponse{
+		ID:           account.AccountID,
+		Balance:      core.NewBigInt(balance),
+		Nonce:        nonce.Uint64(),
+		TokenType:    token.Uint64(),
+		PendingNonce: nonce.Uint64() + pendingTxs + 1,
+		Path:         account.Path,
+		Status:       account.Status,
+		Hash:         account.Hash,
+	}
+
+	// the PDA tree only holds a key once it was registered
+	if pda, err := s.accounts.GetPDALeafByID(ID); err == nil && pda.PublicKey != "" {
+		response.PublicKey = pda.PublicKey
+	}
+
+	// pending deposits aren't in the tree yet
+	if withProof && account.Status == core.STATUS_ACTIVE {
+		siblings, err := s.accounts.GetSiblings(account.Path)
+		if err != nil {
+			return AccountResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch account siblings")
+		}
+		response.Proof = []string{}
+		for _, sibling := range siblings {
+			response.Proof = append(response.Proof, sibling.Hash)
+		}
+	}
+	return response, nil
+}
+
+// GetSyncStatus returns the sync state of the node
+func GetSyncStatus() (SyncStatusResponse, error) {
+	syncStatus, err := core.DBInstance.GetSyncStatus()
+	if err != nil {
+		return SyncStatusResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch sync status")
+	}
+	return SyncStatusResponse{
+		State:           core.SyncStateName(syncStatus.State),
+		Synced:          syncStatus.State == core.SYNC_STATE_SYNCED,
+		HeadBlock:       syncStatus.HeadBlock,
+		LastSyncedBlock: syncStatus.LastEthBlockRecorded,
+		LastBatch:       syncStatus.LastBatchRecorded,
+	}, nil
+}
+
+// GetBatch returns the batch with the ID along with the txs it includes
+func (s *Service) GetBatch(ID uint64) (BatchResponse, error) {
+	batch, err := s.batches.GetBatchByIndex(ID)
+	if gorm.IsRecordNotFoundError(err) {
+		return BatchResponse{}, NewError(http.StatusNotFound, fmt.Sprintf("Batch with ID %v not found", ID))
+	} else if err != nil {
+		return BatchResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch batch")
+	}
+	syncStatus, err := s.batches.GetSyncStatus()
+	if err != nil {
+		return BatchResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch sync status")
+	}
+	response := NewBatchResponse(batch, syncStatus.LastEthBlockRecorded)
+	if !response.TxsAvailable {
+		return response, nil
+	}
+
+	// only transfer batches carry compressed txs
+	var compressedTxs [][]byte
+	if batch.BatchType == core.TX_TRANSFER_TYPE {
+		compressedTxs, err = core.DecodeBatchTxs(batch.TransactionsIncluded)
+		if err != nil {
+			return BatchResponse{}, NewError(http.StatusInternalServerError, "Unable to decode batch transactions")
+		}
+	}
+	response.Txs = []BatchTx{}
+	for _, compressedTx := range compressedTxs {
+		from, to, amount, sig, err := s.codec.DecompressTransferTx(compressedTx)
+		if err != nil {
+			return BatchResponse{}, NewError(http.StatusInternalServerError, "Unable to decode batch transactions")
+		}
+		response.Txs = append(response.Txs, BatchTx{
+			From:      from.Uint64(),
+			To:        to.Uint64(),
+			Amount:    core.NewBigInt(amount),
+			Signature: hex.EncodeToString(sig),
+		})
+	}
+	return response, nil
+}
+
+// GetAccountProof returns the merkle proof of the account with the ID against the current balance root,
+// or against the state root of the batch if one is given
+func (s *Service) GetAccountProof(ID uint64, batchID *uint64) (AccountProofResponse, error) {
+	// an account keeps its path, pending ones are looked up as they may have been deposited by then
+	account, err := s.proofs.GetAccountLeafByID(ID)
+	if gorm.IsRecordNotFoundError(err) {
+		return AccountProofResponse{}, NewError(http.StatusNotFound, fmt.Sprintf("Account with ID %v not found", ID))
+	} else if err != nil {
+		return AccountProofResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch account")
+	}
+
+	var proof core.AccountMerkleProof
+	var root string
+	if batchID == nil {
+		rootNode, err := s.proofs.GetRoot()
+		if err != nil {
+			return AccountProofResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch balance root")
+		}
+		root = rootNode.Hash
+		siblings, err := s.proofs.GetSiblings(account.Path)
+		if err != nil {
+			return AccountProofResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch account siblings")
+		}
+		proof = core.NewAccountMerkleProof(account, siblings)
+	} else {
+		batch, err := s.proofs.GetBatchByIndex(*batchID)
+		if gorm.IsRecordNotFoundError(err) {
+			return AccountProofResponse{}, NewError(http.StatusNotFound, fmt.Sprintf("Batch with ID %v not found", *batchID))
+		} else if err != nil {
+			return AccountProofResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch batch")
+		}
+		lastBatchID, err := s.proofs.GetLastCommittedBatchID()
+		if err != nil {
+			return AccountProofResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch latest batch")
+		}
+		if lastBatchID > *batchID+core.JournalHistoryBatches {
+			return AccountProofResponse{}, NewError(http.StatusBadRequest, fmt.Sprintf("Proofs only go back %v batches", core.JournalHistoryBatches))
+		}
+		root = batch.StateRoot
+		proof, err = s.proofs.AccountProofAt(*batchID, account.Path)
+		switch err {
+		case nil:
+		case fraudproof.ErrBatchNotApplied:
+			return AccountProofResponse{}, NewError(http.StatusBadRequest, fmt.Sprintf("Batch %v was disputed or rolled back", *batchID))
+		case fraudproof.ErrPreStateNotFound:
+			return AccountProofResponse{}, NewError(http.StatusNotFound, fmt.Sprintf("State after batch %v is no longer available", *batchID))
+		default:
+			return AccountProofResponse{}, NewError(http.StatusInternalServerError, "Unable to build historical proof")
+		}
+	}
+	if proof.Account.Status != core.STATUS_ACTIVE {
+		return AccountProofResponse{}, NewError(http.StatusNotFound, fmt.Sprintf("Account with ID %v is not in the balance tree", ID))
+	}
+
+	encoded, err := s.codec.EncodeAccountProof(proof)
+	if err != nil {
+		return AccountProofResponse{}, NewError(http.StatusInternalServerError, "Unable to encode proof")
+	}
+	response := AccountProofResponse{
+		Root:    root,
+		BatchID: batchID,
+		Proof: AccountProofJSON{
+			AccountID: proof.Account.AccountID,
+			Path:      proof.Account.Path,
+			Data:      hex.EncodeToString(proof.Account.Data),
+			Hash:      proof.Account.Hash,
+			Siblings:  []string{},
+		},
+		ABIEncoded: hexutil.Encode(encoded),
+	}
+	for _, sibling := range proof.Siblings {
+		response.Proof.Siblings = append(response.Proof.Siblings, sibling.Hash)
+	}
+	return response, nil
+}
+
+// GetPubkeyProof returns the merkle proof of the pubkey registered for the account with the ID against the current PDA root
+// Batches don't commit to a PDA root, disputes check pubkeys against the latest one and registered
+// pubkeys never change, so a proof against an older root would be of no use
+func (s *Service) GetPubkeyProof(ID uint64) (PubkeyProofResponse, error) {
+	pda, err := s.proofs.GetPDALeafByID(ID)
+	if gorm.IsRecordNotFoundError(err) || (err == nil && pda.PublicKey == "") {
+		return PubkeyProofResponse{}, NewError(http.StatusNotFound, fmt.Sprintf("No pubkey registered for account with ID %v", ID))
+	} else if err != nil {
+		return PubkeyProofResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch pubkey")
+	}
+	rootNode, err := s.proofs.GetPDARoot()
+	if err != nil {
+		return PubkeyProofResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch PDA root")
+	}
+	siblings, err := s.proofs.GetPDASiblings(pda.Path)
+	if err != nil {
+		return PubkeyProofResponse{}, NewError(http.StatusInternalServerError, "Unable to fetch pubkey siblings")
+	}
+	proof := core.NewPDAProof(pda.Path, pda.PublicKey, siblings)
+	encoded, err := s.codec.EncodePubkeyProof(proof)
+	if err != nil {
+		return PubkeyProofResponse{}, NewError(http.StatusInternalServerError, "Unable to encode proof")
+	}
+	response := PubkeyProofResponse{
+		Root: rootNode.Hash,
+		Proof: PubkeyProofJSON{
+			Path:      proof.Path,
+			PublicKey: proof.PublicKey,
+			Siblings:  []string{},
+		},
+		ABIEncoded: hexutil.Encode(encoded),
+	}
+	for _, sibling := range proof.Siblings {
+		response.Proof.Siblings = append(response.Proof.Siblings, sibling.Hash)
+	}
+	return response, nil
+}
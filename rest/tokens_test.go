@@ -0,0 +1,100 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BOPR/core"
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/require"
+)
+
+type tokenStore struct {
+	tokens  []core.Token
+	pending []core.TokenRegistrationRequest
+	err     error
+}
+
+func (s *tokenStore) GetTokenByID(id uint64) (core.Token, error) {
+	if s.err != nil {
+		return core.Token{}, s.err
+	}
+	for _, token := range s.tokens {
+		if token.TokenID == id {
+			return token, nil
+		}
+	}
+	return core.Token{}, gorm.ErrRecordNotFound
+}
+
+func (s *tokenStore) GetTokens() ([]core.Token, error) {
+	return s.tokens, s.err
+}
+
+func (s *tokenStore) GetTokenRegistrationRequests() ([]core.TokenRegistrationRequest, error) {
+	return s.pending, s.err
+}
+
+func serveTokens(store TokenStore, path string) *httptest.ResponseRecorder {
+	service := NewService(nil, store, nil, nil, nil)
+	r := mux.NewRouter()
+	r.HandleFunc("/tokens", service.GetTokensHandler).Methods("GET")
+	r.HandleFunc("/tokens/{id}", service.GetTokenHandler).Methods("GET")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestCheckTokenRegistered(t *testing.T) {
+	store := &tokenStore{tokens: []core.Token{{TokenID: 1, Symbol: "TT"}}}
+	service := NewService(nil, store, nil, nil, nil)
+	require.NoError(t, service.checkTokenRegistered(1))
+
+	err := service.checkTokenRegistered(2)
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, err.(*Error).Status)
+
+	store.err = errors.New("connection lost")
+	err = service.checkTokenRegistered(1)
+	require.Error(t, err)
+	require.Equal(t, http.StatusInternalServerError, err.(*Error).Status)
+}
+
+func TestGetTokensHandler(t *testing.T) {
+	w := serveTokens(&tokenStore{}, "/tokens")
+	require.Equal(t, http.StatusOK, w.Code)
+	// empty lists rather than nulls
+	require.JSONEq(t, `{"tokens":[],"pending":[]}`, w.Body.String())
+
+	store := &tokenStore{
+		tokens:  []core.Token{{TokenID: 1, Address: "0x01"}},
+		pending: []core.TokenRegistrationRequest{{Address: "0x02"}},
+	}
+	w = serveTokens(store, "/tokens")
+	require.Equal(t, http.StatusOK, w.Code)
+	var response TokensResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Tokens, 1)
+	require.Len(t, response.Pending, 1)
+	require.Equal(t, "0x02", response.Pending[0].Address)
+
+	store = &tokenStore{err: errors.New("connection lost")}
+	require.Equal(t, http.StatusInternalServerError, serveTokens(store, "/tokens").Code)
+}
+
+func TestGetTokenHandler(t *testing.T) {
+	store := &tokenStore{tokens: []core.Token{{TokenID: 1, Address: "0x01", Symbol: "TT", Decimals: 18}}}
+	w := serveTokens(store, "/tokens/1")
+	require.Equal(t, http.StatusOK, w.Code)
+	var token core.Token
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+	require.Equal(t, "TT", token.Symbol)
+	require.Equal(t, uint64(18), token.Decimals)
+
+	require.Equal(t, http.StatusNotFound, serveTokens(store, "/tokens/2").Code)
+	require.Equal(t, http.StatusBadRequest, serveTokens(store, "/tokens/abc").Code)
+}
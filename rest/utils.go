@@ -51,3 +51,27 @@ func MustMarshallJSON(o interface{}) []byte {
 	}
 	return bz
 }
+
+// Error is a failed request along with the HTTP status it is served with
+type Error struct {
+	Status  int
+	Message string
+}
+
+// NewError creates a new Error with the status and message
+func NewError(status int, message string) *Error {
+	return &Error{Status: status, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WriteError writes the error with its status, errors which aren't an *Error are internal errors
+func WriteError(w http.ResponseWriter, err error) {
+	if restErr, ok := err.(*Error); ok {
+		WriteErrorResponse(w, restErr.Status, restErr.Message)
+		return
+	}
+	WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+}
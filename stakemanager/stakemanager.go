@@ -0,0 +1,163 @@
+package stakemanager
+
+import (
+	"context"
+	"time"
+
+	"github.com/BOPR/common"
+	"github.com/BOPR/config"
+	"github.com/BOPR/core"
+)
+
+const (
+	StakeManagerService = "stake-manager"
+)
+
+// StakeManager is the service which withdraws the stake locked by the batches this node committed
+// It has the following tasks:
+// 1. Fetch the block each committed batch finalises on from the rollup contract
+// 2. Call WithdrawStake once a batch is final and wasn't rolled back
+// The withdrawal itself is recorded when the syncer sees the StakeWithdraw event
+type StakeManager struct {
+	// Base service
+	core.BaseService
+
+	// contract caller to interact with contracts
+	LoadedBazooka core.Bazooka
+
+	// DB instance
+	DB core.DB
+
+	// where the batches are read from and the stake withdrawn, the DB and bazooka above unless faked in tests
+	stakes StakeStore
+	rollup StakeRollup
+
+	// withdrawal loop cancel
+	cancelWithdrawing context.CancelFunc
+}
+
+// StakeStore reads the batches with locked stake and their withdrawals
+type StakeStore interface {
+	IsSynced() (bool, error)
+	GetSyncStatus() (core.SyncStatus, error)
+	GetBatchesWithLockedStake(committer string) ([]core.Batch, error)
+	UpdateBatchFinalisesOn(batchID uint64, finalisesOn uint64) error
+	GetStakeWithdrawal(batchID uint64) (core.StakeWithdrawal, error)
+}
+
+// StakeRollup reads batch finalisation from the rollup contract and withdraws stake
+type StakeRollup interface {
+	FetchBatchFinalisesOn(batchID uint64) (uint64, error)
+	WithdrawStake(batchID uint64) error
+}
+
+// NewStakeManager returns new stake manager object
+func NewStakeManager() *StakeManager {
+	// create logger
+	logger := common.Logger.With("module", StakeManagerService)
+	LoadedBazooka, err := core.NewPreLoadedBazooka()
+	if err != nil {
+		panic(err)
+	}
+	stakeManager := &StakeManager{}
+	stakeManager.BaseService = *core.NewBaseService(logger, StakeManagerService, stakeManager)
+	DB, err := core.NewDB()
+	if err != nil {
+		panic(err)
+	}
+	stakeManager.DB = DB
+	stakeManager.LoadedBazooka = LoadedBazooka
+	stakeManager.stakes = &stakeManager.DB
+	stakeManager.rollup = &stakeManager.LoadedBazooka
+	return stakeManager
+}
+
+// OnStart starts the withdrawal loop
+func (m *StakeManager) OnStart() error {
+	m.BaseService.OnStart() // Always call the overridden method.
+
+	ctx, cancelWithdrawing := context.WithCancel(context.Background())
+	m.cancelWithdrawing = cancelWithdrawing
+
+	go m.startWithdrawing(ctx, config.GlobalCfg.PollingInterval)
+	return nil
+}
+
+// OnStop stops all necessary go routines
+func (m *StakeManager) OnStop() {
+	m.BaseService.OnStop() // Always call the overridden method.
+	m.DB.Close()
+	m.cancelWithdrawing()
+}
+
+func (m *StakeManager) startWithdrawing(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	// stop ticker when everything done
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.withdrawFinalisedStakes()
+		case <-ctx.Done():
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+// withdrawFinalisedStakes withdraws the stake of every committed batch that is final
+// batches that were disputed or rolled back are no longer committed and are skipped
+// finality is judged as of the last synced block, a batch might have been rolled back in the blocks we haven't synced yet
+func (m *StakeManager) withdrawFinalisedStakes() {
+	synced, err := m.stakes.IsSynced()
+	if err != nil {
+		m.Logger.Error("Error while fetching sync state", "error", err)
+		return
+	}
+	if !synced {
+		m.Logger.Info("Syncer isn't synced, not withdrawing stake")
+		return
+	}
+	syncStatus, err := m.stakes.GetSyncStatus()
+	if err != nil {
+		m.Logger.Error("Error while fetching sync status", "error", err)
+		return
+	}
+	currentBlock := syncStatus.LastEthBlockRecorded
+
+	batches, err := m.stakes.GetBatchesWithLockedStake(config.OperatorAddress.String())
+	if err != nil {
+		m.Logger.Error("Error while fetching batches with locked stake", "error", err)
+		return
+	}
+	for _, batch := range batches {
+		if batch.FinalisesOn == 0 {
+			batch.FinalisesOn, err = m.rollup.FetchBatchFinalisesOn(batch.BatchID)
+			if err != nil {
+				m.Logger.Error("Unable to fetch batch finalisation", "batchID", batch.BatchID, "error", err)
+				continue
+			}
+			if err := m.stakes.UpdateBatchFinalisesOn(batch.BatchID, batch.FinalisesOn); err != nil {
+				m.Logger.Error("Unable to store batch finalisation", "batchID", batch.BatchID, "error", err)
+				continue
+			}
+		}
+		if !batch.IsFinal(currentBlock) {
+			continue
+		}
+
+		withdrawal, err := m.stakes.GetStakeWithdrawal(batch.BatchID)
+		if err != nil {
+			m.Logger.Error("Unable to fetch stake withdrawal", "batchID", batch.BatchID, "error", err)
+			continue
+		}
+		// wait for pending and confirmed withdrawals to show up as StakeWithdraw events
+		if withdrawal.Withdrawn || withdrawal.SubmissionStatus == core.SUBMISSION_PENDING || withdrawal.SubmissionStatus == core.SUBMISSION_CONFIRMED {
+			continue
+		}
+		if err := m.rollup.WithdrawStake(batch.BatchID); err != nil {
+			m.Logger.Error("Unable to withdraw stake", "batchID", batch.BatchID, "error", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package stakemanager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BOPR/core"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStakeStore struct {
+	synced      bool
+	lastBlock   uint64
+	batches     []core.Batch
+	withdrawals map[uint64]core.StakeWithdrawal
+}
+
+func (s *fakeStakeStore) IsSynced() (bool, error) {
+	return s.synced, nil
+}
+
+func (s *fakeStakeStore) GetSyncStatus() (core.SyncStatus, error) {
+	return core.SyncStatus{LastEthBlockRecorded: s.lastBlock}, nil
+}
+
+// GetBatchesWithLockedStake returns the committed and finalised batches like the DB does
+func (s *fakeStakeStore) GetBatchesWithLockedStake(committer string) (batches []core.Batch, err error) {
+	for _, batch := range s.batches {
+		if batch.Status == core.BATCH_COMMITTED || batch.Status == core.BATCH_FINALISED {
+			batches = append(batches, batch)
+		}
+	}
+	return batches, nil
+}
+
+func (s *fakeStakeStore) UpdateBatchFinalisesOn(batchID uint64, finalisesOn uint64) error {
+	for i := range s.batches {
+		if s.batches[i].BatchID == batchID {
+			s.batches[i].FinalisesOn = finalisesOn
+			return nil
+		}
+	}
+	return errors.New("batch not found")
+}
+
+func (s *fakeStakeStore) GetStakeWithdrawal(batchID uint64) (core.StakeWithdrawal, error) {
+	return s.withdrawals[batchID], nil
+}
+
+type fakeRollup struct {
+	finalisesOn map[uint64]uint64
+	withdrawn   []uint64
+}
+
+func (r *fakeRollup) FetchBatchFinalisesOn(batchID uint64) (uint64, error) {
+	finalisesOn, ok := r.finalisesOn[batchID]
+	if !ok {
+		return 0, errors.New("execution reverted")
+	}
+	return finalisesOn, nil
+}
+
+func (r *fakeRollup) WithdrawStake(batchID uint64) error {
+	r.withdrawn = append(r.withdrawn, batchID)
+	return nil
+}
+
+func newTestStakeManager(store StakeStore, rollup StakeRollup) *StakeManager {
+	stakeManager := &StakeManager{stakes: store, rollup: rollup}
+	stakeManager.BaseService = *core.NewBaseService(nil, StakeManagerService, stakeManager)
+	return stakeManager
+}
+
+func TestWithdrawFinalisedStakes(t *testing.T) {
+	store := &fakeStakeStore{
+		lastBlock: 100,
+		batches: []core.Batch{
+			{BatchID: 1, Status: core.BATCH_COMMITTED, FinalisesOn: 90},
+			{BatchID: 2, Status: core.BATCH_FINALISED, FinalisesOn: 100},
+			// final only in blocks we haven't synced yet
+			{BatchID: 3, Status: core.BATCH_COMMITTED, FinalisesOn: 101},
+			{BatchID: 4, Status: core.BATCH_ROLLED_BACK, FinalisesOn: 90},
+			{BatchID: 5, Status: core.BATCH_DISPUTED, FinalisesOn: 90},
+			{BatchID: 6, Status: core.BATCH_STAKE_WITHDRAWN, FinalisesOn: 90},
+			{BatchID: 7, Status: core.BATCH_COMMITTED, FinalisesOn: 90},
+			{BatchID: 8, Status: core.BATCH_COMMITTED, FinalisesOn: 90},
+			{BatchID: 9, Status: core.BATCH_COMMITTED, FinalisesOn: 90},
+			// finalisation not fetched yet
+			{BatchID: 10, Status: core.BATCH_COMMITTED},
+			{BatchID: 11, Status: core.BATCH_COMMITTED},
+		},
+		withdrawals: map[uint64]core.StakeWithdrawal{
+			7: {BatchID: 7, SubmissionStatus: core.SUBMISSION_PENDING},
+			8: {BatchID: 8, SubmissionStatus: core.SUBMISSION_CONFIRMED},
+			// failed withdrawals are retried
+			9: {BatchID: 9, SubmissionStatus: core.SUBMISSION_FAILED},
+		},
+	}
+	rollup := &fakeRollup{finalisesOn: map[uint64]uint64{10: 95, 11: 120}}
+	stakeManager := newTestStakeManager(store, rollup)
+
+	// a batch might have been rolled back in the blocks we haven't synced yet
+	stakeManager.withdrawFinalisedStakes()
+	require.Empty(t, rollup.withdrawn)
+	require.Zero(t, store.batches[9].FinalisesOn)
+
+	store.synced = true
+	stakeManager.withdrawFinalisedStakes()
+	require.Equal(t, []uint64{1, 2, 9, 10}, rollup.withdrawn)
+	require.Equal(t, uint64(95), store.batches[9].FinalisesOn)
+	require.Equal(t, uint64(120), store.batches[10].FinalisesOn)
+
+	// a batch whose finalisation can't be fetched doesn't hold up the others
+	store.batches[9].FinalisesOn = 0
+	delete(rollup.finalisesOn, 10)
+	rollup.withdrawn = nil
+	stakeManager.withdrawFinalisedStakes()
+	require.Equal(t, []uint64{1, 2, 9}, rollup.withdrawn)
+}
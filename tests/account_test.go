@@ -86,7 +86,7 @@ func TestTxProcessing(t *testing.T) {
 	for i := 0; i < 5; i++ {
 		txs = append(txs, tx1)
 	}
-	err = agg.ProcessTx(txs)
+	_, err = agg.ProcessTx(txs)
 	fmt.Println("err", err)
 
 	require.Equal(t, err, nil, "error processing tx")
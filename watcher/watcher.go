@@ -0,0 +1,134 @@
+package watcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/BOPR/common"
+	"github.com/BOPR/config"
+	"github.com/BOPR/core"
+)
+
+const (
+	WatcherService = "watcher"
+)
+
+// Watcher is the service which disputes invalid batches
+// The syncer re-executes every batch it sees and records a dispute for invalid ones,
+// the watcher picks the disputes which haven't been sent yet and submits them on-chain
+type Watcher struct {
+	// Base service
+	core.BaseService
+
+	// contract caller to interact with contracts
+	LoadedBazooka core.Bazooka
+
+	// DB instance
+	DB core.DB
+
+	// where the disputes are read from and sent to, the DB and bazooka above unless faked in tests
+	disputes DisputeStore
+	sender   DisputeSender
+
+	// dispute loop cancel
+	cancelWatching context.CancelFunc
+}
+
+// DisputeStore reads the disputes to send
+type DisputeStore interface {
+	IsSynced() (bool, error)
+	GetSyncStatus() (core.SyncStatus, error)
+	GetUnsentDisputes(currentBlock uint64) ([]core.Dispute, error)
+}
+
+// DisputeSender submits a dispute on-chain
+type DisputeSender interface {
+	SendDispute(dispute core.Dispute) error
+}
+
+// NewWatcher returns new watcher object
+func NewWatcher() *Watcher {
+	// create logger
+	logger := common.Logger.With("module", WatcherService)
+	LoadedBazooka, err := core.NewPreLoadedBazooka()
+	if err != nil {
+		panic(err)
+	}
+	watcher := &Watcher{}
+	watcher.BaseService = *core.NewBaseService(logger, WatcherService, watcher)
+	DB, err := core.NewDB()
+	if err != nil {
+		panic(err)
+	}
+	watcher.DB = DB
+	watcher.LoadedBazooka = LoadedBazooka
+	watcher.disputes = &watcher.DB
+	watcher.sender = &watcher.LoadedBazooka
+	return watcher
+}
+
+// OnStart starts the dispute loop
+func (w *Watcher) OnStart() error {
+	w.BaseService.OnStart() // Always call the overridden method.
+
+	ctx, cancelWatching := context.WithCancel(context.Background())
+	w.cancelWatching = cancelWatching
+
+	go w.startWatching(ctx, config.GlobalCfg.PollingInterval)
+	return nil
+}
+
+// OnStop stops all necessary go routines
+func (w *Watcher) OnStop() {
+	w.BaseService.OnStop() // Always call the overridden method.
+	w.DB.Close()
+	w.cancelWatching()
+}
+
+func (w *Watcher) startWatching(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	// stop ticker when everything done
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.sendDisputes()
+		case <-ctx.Done():
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+// sendDisputes submits the unsent disputes, oldest batch first, along with the ones whose submission failed
+// once sent the tx manager tracks the dispute until it is confirmed
+func (w *Watcher) sendDisputes() {
+	// the batch might have been rolled back already in the blocks we haven't synced yet
+	synced, err := w.disputes.IsSynced()
+	if err != nil {
+		w.Logger.Error("Error while fetching sync state", "error", err)
+		return
+	}
+	if !synced {
+		w.Logger.Info("Syncer isn't synced, not sending disputes")
+		return
+	}
+
+	syncStatus, err := w.disputes.GetSyncStatus()
+	if err != nil {
+		w.Logger.Error("Error while fetching sync status", "error", err)
+		return
+	}
+	disputes, err := w.disputes.GetUnsentDisputes(syncStatus.LastEthBlockRecorded)
+	if err != nil {
+		w.Logger.Error("Error while fetching unsent disputes", "error", err)
+		return
+	}
+	for _, dispute := range disputes {
+		w.Logger.Info("Disputing batch", "batchID", dispute.BatchID, "reason", dispute.Reason)
+		if err := w.sender.SendDispute(dispute); err != nil {
+			w.Logger.Error("Unable to send dispute", "batchID", dispute.BatchID, "error", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package watcher
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BOPR/core"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDisputeStore struct {
+	synced    bool
+	lastBlock uint64
+	disputes  []core.Dispute
+}
+
+func (s *fakeDisputeStore) IsSynced() (bool, error) {
+	return s.synced, nil
+}
+
+func (s *fakeDisputeStore) GetSyncStatus() (core.SyncStatus, error) {
+	return core.SyncStatus{LastEthBlockRecorded: s.lastBlock}, nil
+}
+
+func (s *fakeDisputeStore) GetUnsentDisputes(currentBlock uint64) ([]core.Dispute, error) {
+	if currentBlock != s.lastBlock {
+		return nil, errors.New("unsent disputes not read as of the synced block")
+	}
+	return s.disputes, nil
+}
+
+type fakeDisputeSender struct {
+	sent []uint64
+	// sending the dispute of this batch fails
+	failOn uint64
+}
+
+func (s *fakeDisputeSender) SendDispute(dispute core.Dispute) error {
+	if dispute.BatchID == s.failOn {
+		return errors.New("nonce too low")
+	}
+	s.sent = append(s.sent, dispute.BatchID)
+	return nil
+}
+
+func newTestWatcher(store DisputeStore, sender DisputeSender) *Watcher {
+	watcher := &Watcher{disputes: store, sender: sender}
+	watcher.BaseService = *core.NewBaseService(nil, WatcherService, watcher)
+	return watcher
+}
+
+func TestSendDisputes(t *testing.T) {
+	store := &fakeDisputeStore{lastBlock: 50, disputes: []core.Dispute{{BatchID: 2}, {BatchID: 3}, {BatchID: 5}}}
+	sender := &fakeDisputeSender{}
+	watcher := newTestWatcher(store, sender)
+
+	// the batches might have been rolled back in the blocks we haven't synced yet
+	watcher.sendDisputes()
+	require.Empty(t, sender.sent)
+
+	store.synced = true
+	watcher.sendDisputes()
+	require.Equal(t, []uint64{2, 3, 5}, sender.sent)
+
+	// the disputes after a failed one are left for the next round
+	sender = &fakeDisputeSender{failOn: 3}
+	newTestWatcher(store, sender).sendDisputes()
+	require.Equal(t, []uint64{2}, sender.sent)
+}